@@ -1,12 +1,24 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"go-security/security/application"
+	"os"
 )
 
 func main() {
+	configPath := flag.String("config", "cmd/api/config.yaml", "path of the YAML config, GOSEC_* environment variables override it")
+	flag.Parse()
 
-	config := application.MustNewAppConfigFromFile("/Users/william_w_chen/Desktop/tofu-erp/cmd/api/config.yaml")
+	config, err := application.NewAppConfigFromFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if flag.Arg(0) == application.VerifyAuditCommand {
+		os.Exit(application.RunAuditVerification(config, os.Stdout))
+	}
 	app := application.MustNewApplication(config)
 	context := application.MustNewSecurityApplicationContext(app)
 	app.InjectContextCollection(context)
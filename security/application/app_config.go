@@ -1,12 +1,18 @@
 package application
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"go-security/security"
 	"go-security/security/repository"
 	"go-security/security/service"
 	"go-security/security/service/oauth"
+	"go-security/security/tracing"
 	"go-security/security/web/controller"
+	web "go-security/security/web/middleware"
+	"net"
+	"slices"
+	"strings"
 )
 
 type Config struct {
@@ -14,17 +20,135 @@ type Config struct {
 	Security           *service.SecurityConfig              `yaml:"security"`
 	PostgresDataSource *repository.PostgresDataSourceConfig `yaml:"postgres_data_source"`
 	Smtp               *service.SmtpConfig                  `yaml:"smtp"`
+	EmailOutbox        *service.EmailOutboxConfig           `yaml:"email_outbox"`
+	Templates          *service.TemplateConfig              `yaml:"templates"`
+	Sms                *service.SmsConfig                   `yaml:"sms"`
+	Line               *service.LineConfig                  `yaml:"line"`
+	Audit              *service.AuditConfig                 `yaml:"audit"`
 	GoogleAuthConfig   *oauth.GoogleAuthConfig              `yaml:"google_auth"`
+	Cors               *web.CorsConfig                      `yaml:"cors"`
+	SecurityHeaders    *web.SecurityHeadersConfig           `yaml:"security_headers"`
+	RequestLog         *web.RequestLogConfig                `yaml:"request_log"`
+	Tracing            *tracing.Config                      `yaml:"tracing"`
 }
 
-func (config *Config) AsJson() string {
-	_json, err := json.MarshalIndent(config, "", "   ")
-	if err != nil {
-		return ""
+// SetDefaults allocates the sections the application cannot start without, the YAML file and the
+// environment fill them in.
+func (config *Config) SetDefaults() {
+	config.Server = &controller.ServerConfig{
+		Port:               8080,
+		ShutdownTimeout:    controller.DefaultShutdownTimeout,
+		HealthCheckTimeout: service.DefaultHealthCheckTimeout,
+	}
+	config.Security = &service.SecurityConfig{}
+	config.PostgresDataSource = &repository.PostgresDataSourceConfig{
+		Host: "localhost",
+		Port: 5432,
+	}
+	config.Smtp = &service.SmtpConfig{
+		Port: 587,
+	}
+}
+
+// MinSecretLength is the minimum length in bytes of security.secret, it signs the tokens and derives the
+// encryption keys.
+const MinSecretLength = 32
+
+// placeholderSecrets are the sample values of secrets, rejected so a copied config never reaches production.
+var placeholderSecrets = []string{"secret", "pwd", "admin", "password", "changeme"}
+
+func isPlaceholderSecret(value string) bool {
+	return slices.Contains(placeholderSecrets, strings.ToLower(strings.TrimSpace(value)))
+}
+
+// Validate reports every problem of the config at once, each naming the setting and how to provide it.
+func (config *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, format string, args ...any) {
+		envName := configEnvName(key)
+		errs = append(errs, fmt.Errorf("%w: %s %s, set it in the config file, %s or %s%s",
+			security.ConfigInvalid, key, fmt.Sprintf(format, args...), envName, envName, security.ConfigFileSuffix))
+	}
+	if config.Server == nil || config.Server.Port <= 0 || config.Server.Port > 65535 {
+		port := 0
+		if config.Server != nil {
+			port = config.Server.Port
+		}
+		invalid("server.port", "must be between 1 and 65535, got %d", port)
+	}
+	if config.Server != nil {
+		for _, proxy := range config.Server.TrustedProxies {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				invalid("server.trusted_proxies", "has %q which is not a CIDR range", proxy)
+			}
+		}
+	}
+	switch {
+	case config.Security == nil || len(config.Security.Secret) == 0:
+		invalid("security.secret", "is empty, it signs the login tokens")
+	case isPlaceholderSecret(config.Security.Secret):
+		invalid("security.secret", "is a placeholder, it signs the login tokens")
+	case len(config.Security.Secret) < MinSecretLength:
+		invalid("security.secret", "must be at least %d bytes, got %d", MinSecretLength, len(config.Security.Secret))
 	}
-	return string(_json)
+	if config.Server != nil && isPlaceholderSecret(config.Server.MetricsToken) {
+		invalid("server.metrics_token", "is a placeholder")
+	}
+	if config.PostgresDataSource != nil && isPlaceholderSecret(config.PostgresDataSource.Password) {
+		invalid("postgres_data_source.password", "is a placeholder")
+	}
+	if config.Smtp != nil && isPlaceholderSecret(config.Smtp.SenderPassword) {
+		invalid("smtp.sender_password", "is a placeholder")
+	}
+	if config.PostgresDataSource == nil || len(config.PostgresDataSource.Host) == 0 {
+		invalid("postgres_data_source.host", "is empty")
+	}
+	if config.PostgresDataSource == nil || len(config.PostgresDataSource.DatabaseName) == 0 {
+		invalid("postgres_data_source.db_name", "is empty")
+	}
+	if config.Smtp == nil {
+		invalid("smtp.transport", "is missing")
+	} else {
+		switch strings.ToLower(config.Smtp.Transport) {
+		case "", service.EmailTransportSmtp:
+			if len(config.Smtp.Host) == 0 {
+				invalid("smtp.host", "is empty, the smtp transport needs it")
+			}
+			if config.Smtp.Port <= 0 || config.Smtp.Port > 65535 {
+				invalid("smtp.port", "must be between 1 and 65535, got %d", config.Smtp.Port)
+			}
+		case service.EmailTransportFile:
+			if len(config.Smtp.OutputDirectory) == 0 {
+				invalid("smtp.output_directory", "is empty, the file transport needs it")
+			}
+		case service.EmailTransportSes:
+			if config.Smtp.Ses == nil || len(config.Smtp.Ses.Region) == 0 {
+				invalid("smtp.ses.region", "is empty, the ses transport needs it")
+			}
+		case service.EmailTransportConsole, service.EmailTransportMemory:
+		default:
+			invalid("smtp.transport", "%q is not one of smtp, file, console, memory or ses", config.Smtp.Transport)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configEnvName is the environment variable overriding a setting, such as GOSEC_SECURITY_SECRET for security.secret.
+func configEnvName(key string) string {
+	return security.ConfigEnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// AsJson renders the config for the startup log, secrets are masked.
+func (config *Config) AsJson() string {
+	return security.MaskedConfigJson(config)
+}
+
+// NewAppConfigFromFile layers the defaults, the YAML file, the GOSEC_* variables and the GOSEC_*_FILE secret
+// files, then validates the result.
+func NewAppConfigFromFile(configPath string) (*Config, error) {
+	return security.LoadConfig[Config](configPath, security.ConfigEnvPrefix)
 }
 
 func MustNewAppConfigFromFile(configPath string) *Config {
-	return security.MustNewConfigFromFile[Config](configPath)
+	return security.MustLoadConfig[Config](configPath, security.ConfigEnvPrefix)
 }
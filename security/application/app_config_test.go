@@ -0,0 +1,101 @@
+package application
+
+import (
+	"errors"
+	"go-security/security"
+	"go-security/security/service"
+	"strings"
+	"testing"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func newValidConfig() *Config {
+	config := &Config{}
+	config.SetDefaults()
+	config.Security.Secret = testSecret
+	config.PostgresDataSource.DatabaseName = "security"
+	config.Smtp.Host = "smtp.example.com"
+	return config
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(config *Config)
+		problems []string
+	}{
+		{"valid", func(config *Config) {}, nil},
+		{"empty secret", func(config *Config) { config.Security.Secret = "" }, []string{"security.secret is empty"}},
+		{"placeholder secret", func(config *Config) { config.Security.Secret = " Secret " }, []string{"security.secret is a placeholder"}},
+		{"short secret", func(config *Config) { config.Security.Secret = testSecret[:31] }, []string{"security.secret must be at least 32 bytes, got 31"}},
+		{"placeholder passwords", func(config *Config) {
+			config.PostgresDataSource.Password = "pwd"
+			config.Smtp.SenderPassword = "changeme"
+			config.Server.MetricsToken = "admin"
+		}, []string{"postgres_data_source.password is a placeholder", "smtp.sender_password is a placeholder", "server.metrics_token is a placeholder"}},
+		{"port", func(config *Config) { config.Server.Port = 70000 }, []string{"server.port must be between 1 and 65535, got 70000"}},
+		{"transport", func(config *Config) { config.Smtp.Transport = "pigeon" }, []string{`smtp.transport "pigeon" is not one of`}},
+		{"trusted proxies", func(config *Config) { config.Server.TrustedProxies = []string{"10.0.0.0/8", "10.0.0.1"} }, []string{`server.trusted_proxies has "10.0.0.1" which is not a CIDR range`}},
+		{"ses region", func(config *Config) { config.Smtp.Transport = service.EmailTransportSes }, []string{"smtp.ses.region is empty"}},
+		{"every problem at once", func(config *Config) {
+			config.Server = nil
+			config.Security.Secret = "password"
+			config.PostgresDataSource.DatabaseName = ""
+			config.Smtp.Host = ""
+		}, []string{"server.port", "security.secret is a placeholder", "postgres_data_source.db_name is empty", "smtp.host is empty"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newValidConfig()
+			test.modify(config)
+			err := config.Validate()
+			if len(test.problems) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, security.ConfigInvalid) {
+				t.Fatalf("expected ConfigInvalid, got %v", err)
+			}
+			if count := len(err.(interface{ Unwrap() []error }).Unwrap()); count != len(test.problems) {
+				t.Fatalf("expected %d problems, got %d: %v", len(test.problems), count, err)
+			}
+			for _, problem := range test.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("expected %q in %v", problem, err)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidateNamesTheVariables(t *testing.T) {
+	config := newValidConfig()
+	config.Security.Secret = ""
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "GOSEC_SECURITY_SECRET or GOSEC_SECURITY_SECRET_FILE") {
+		t.Fatalf("expected the variables to be named, got %v", err)
+	}
+}
+
+func TestConfigFromEnvironment(t *testing.T) {
+	t.Setenv("GOSEC_SECURITY_SECRET", testSecret)
+	t.Setenv("GOSEC_POSTGRES_DATA_SOURCE_DB_NAME", "security")
+	t.Setenv("GOSEC_SMTP_HOST", "smtp.example.com")
+	t.Setenv("GOSEC_SERVER_PORT", "9090")
+	config, err := NewAppConfigFromFile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Port != 9090 || config.Server.ShutdownTimeout == 0 {
+		t.Fatalf("expected the environment over the kept defaults, got %+v", config.Server)
+	}
+	if config.Tracing != nil || config.Cors != nil {
+		t.Fatal("sections no variable targets must stay nil")
+	}
+	if strings.Contains(config.AsJson(), testSecret) {
+		t.Fatal("the secret must be masked in the startup log")
+	}
+}
@@ -1,15 +1,23 @@
 package application
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go-security/security/service"
+	"go-security/security/tracing"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"syscall"
 )
 
 type Application struct {
@@ -17,6 +25,7 @@ type Application struct {
 	AppConfig         *Config
 	Engine            *echo.Echo
 	SqlEngine         *gorm.DB
+	Tracing           *tracing.Provider
 }
 
 type Runnable interface {
@@ -46,14 +55,24 @@ func (app *Application) InjectContextCollection(appContextCollection ...*Applica
 
 func MustNewApplication(config *Config) *Application {
 	engine := echo.New()
+	ipExtractor, err := config.Server.IPExtractor()
+	if err != nil {
+		panic(err)
+	}
+	engine.IPExtractor = ipExtractor
+	tracingProvider := tracing.MustNewProviderFromConfig(config.Tracing)
 	sqlEngine, err := gorm.Open(postgres.Open(config.PostgresDataSource.AsDSN()), &gorm.Config{})
 	if err != nil {
 		panic(err)
 	}
+	if err := sqlEngine.Use(tracing.NewGormPlugin("postgresql")); err != nil {
+		panic(err)
+	}
 	return &Application{
 		AppConfig: config,
 		Engine:    engine,
 		SqlEngine: sqlEngine,
+		Tracing:   tracingProvider,
 	}
 }
 
@@ -77,15 +96,89 @@ func (app *Application) postConstructServices() {
 	log.Info().Msg("PostConstruct for all services completed")
 }
 
+// setReady tells the readiness aware services whether the application accepts traffic.
+func (app *Application) setReady(ready bool) {
+	for _, _context := range app.ContextCollection {
+		for _, _service := range _context.Services {
+			if readinessAware, ok := _service.(service.IReadinessAware); ok {
+				readinessAware.SetReady(ready)
+			}
+		}
+	}
+}
+
+// preDestroyServices runs the PreDestroy hooks in the reverse order of the PostConstruct ones.
+func (app *Application) preDestroyServices(ctx context.Context) error {
+	var errs []error
+	for i := len(app.ContextCollection) - 1; i >= 0; i-- {
+		services := app.ContextCollection[i].Services
+		for j := len(services) - 1; j >= 0; j-- {
+			preDestroyer, ok := services[j].(service.IPreDestroyer)
+			if !ok {
+				continue
+			}
+			log.Info().Msgf("PreDestroy for service: %s", reflect.TypeOf(services[j]).String())
+			if err := preDestroyer.PreDestroy(ctx); err != nil {
+				log.Error().Err(err).Msgf("PreDestroy failed for service: %s", reflect.TypeOf(services[j]).String())
+				errs = append(errs, err)
+			}
+		}
+	}
+	log.Info().Msg("PreDestroy for all services completed")
+	return errors.Join(errs...)
+}
+
+// Run serves until SIGINT or SIGTERM is received, then shuts down gracefully.
 func (app *Application) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.RunContext(ctx); err != nil {
+		panic(err)
+	}
+}
+
+// RunContext serves until the context is done or the server fails, then shuts down gracefully.
+func (app *Application) RunContext(ctx context.Context) error {
 	log.Printf("Starting application...")
 	fmt.Printf("%s\n", app.AppConfig.AsJson())
 	app.migrateDatabase()
 	app.postConstructServices()
 	app.registerControllerRoutes()
-	err := app.Engine.Start(fmt.Sprintf(":%d", app.AppConfig.Server.Port))
-	if err != nil {
-		panic(err)
+	app.setReady(true)
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- app.Engine.Start(fmt.Sprintf(":%d", app.AppConfig.Server.Port))
+	}()
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("Shutting down application...")
+	case err = <-serverErrors:
+		log.Error().Err(err).Msg("Server stopped unexpectedly, shutting down application...")
 	}
+	return errors.Join(err, app.Shutdown())
+}
 
+// Shutdown drains the in-flight requests, runs the PreDestroy hooks, flushes the spans and closes the database pool,
+// all within the shutdown timeout of the server config.
+func (app *Application) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.AppConfig.Server.GetShutdownTimeout())
+	defer cancel()
+
+	app.setReady(false)
+	var errs []error
+	if err := app.Engine.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	errs = append(errs, app.preDestroyServices(ctx))
+	// The spans of the PreDestroy hooks are exported too.
+	errs = append(errs, app.Tracing.PreDestroy(ctx))
+	sqlDB, err := app.SqlEngine.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	errs = append(errs, err)
+	log.Info().Msg("Application stopped")
+	return errors.Join(errs...)
 }
@@ -0,0 +1,111 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"go-security/security/web/controller"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"sync"
+	"testing"
+	"time"
+)
+
+type shutdownRecorder struct {
+	order []string
+	lock  sync.Mutex
+}
+
+func (recorder *shutdownRecorder) record(name string) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.order = append(recorder.order, name)
+}
+
+type recordingService struct {
+	name     string
+	recorder *shutdownRecorder
+	// block makes PreDestroy wait for the shutdown deadline.
+	block bool
+}
+
+func (service *recordingService) PostConstruct() {}
+
+func (service *recordingService) PreDestroy(ctx context.Context) error {
+	if service.block {
+		<-ctx.Done()
+		service.recorder.record(service.name)
+		return ctx.Err()
+	}
+	service.recorder.record(service.name)
+	return nil
+}
+
+// passiveService has no PreDestroy hook.
+type passiveService struct{}
+
+func (service *passiveService) PostConstruct() {}
+
+func newTestApplication(t *testing.T, shutdownTimeout time.Duration, contexts ...*ApplicationContext) *Application {
+	t.Helper()
+	sqlEngine, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &Application{
+		AppConfig: &Config{Server: &controller.ServerConfig{ShutdownTimeout: shutdownTimeout}},
+		Engine:    echo.New(),
+		SqlEngine: sqlEngine,
+	}
+	app.InjectContextCollection(contexts...)
+	return app
+}
+
+func TestShutdownRunsPreDestroyInReverseOrder(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	app := newTestApplication(t, time.Second,
+		&ApplicationContext{Services: []service.IService{
+			&recordingService{name: "security.first", recorder: recorder},
+			&passiveService{},
+			&recordingService{name: "security.second", recorder: recorder},
+		}},
+		&ApplicationContext{Services: []service.IService{
+			&recordingService{name: "app.first", recorder: recorder},
+			&recordingService{name: "app.second", recorder: recorder},
+		}},
+	)
+	if err := app.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"app.second", "app.first", "security.second", "security.first"}
+	if len(recorder.order) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, recorder.order)
+	}
+	for i := range expected {
+		if recorder.order[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, recorder.order)
+		}
+	}
+}
+
+func TestShutdownRespectsTimeout(t *testing.T) {
+	recorder := &shutdownRecorder{}
+	app := newTestApplication(t, 50*time.Millisecond, &ApplicationContext{Services: []service.IService{
+		&recordingService{name: "first", recorder: recorder},
+		&recordingService{name: "stuck", recorder: recorder, block: true},
+	}})
+	startedAt := time.Now()
+	err := app.Shutdown()
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Fatalf("the shutdown must give up after its timeout, took %v", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the stuck hook to report the deadline, got %v", err)
+	}
+	if len(recorder.order) != 2 || recorder.order[1] != "first" {
+		t.Fatalf("the hooks after a stuck one must still run, got %v", recorder.order)
+	}
+}
@@ -0,0 +1,33 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rs/zerolog/log"
+	"go-security/security/repository"
+	"go-security/security/service"
+	"io"
+)
+
+const VerifyAuditCommand = "verify-audit"
+
+// RunAuditVerification walks the audit chain of the configured database and prints the report as JSON.
+// It returns the process exit code: 0 when the chain is intact, 1 when it is broken and 2 on errors.
+func RunAuditVerification(config *Config, output io.Writer) int {
+	app := MustNewApplication(config)
+	auditService := service.NewAuditService(config.Audit, repository.NewAuditEventRepository(app.SqlEngine), config.Security.Secret)
+	report, err := auditService.VerifyChain(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to verify audit chain")
+		return 2
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "   ")
+	if err := encoder.Encode(report); err != nil {
+		return 2
+	}
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
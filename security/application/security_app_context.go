@@ -1,10 +1,10 @@
 package application
 
 import (
-	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/rs/zerolog/log"
+	"go-security/security/metrics"
 	"go-security/security/repository"
 	"go-security/security/service"
 	"go-security/security/service/oauth"
@@ -17,19 +17,65 @@ func MustNewSecurityApplicationContext(app *Application) *ApplicationContext {
 	sqlEngine := app.SqlEngine
 	engine := app.Engine
 
-	log.Printf("Starting application...")
-	fmt.Printf("%s\n", config.AsJson())
 	log.Info().Msgf("Connected to database: %s", config.PostgresDataSource.DatabaseName)
+	sqlDB, err := sqlEngine.DB()
+	if err != nil {
+		panic(err)
+	}
+	if err := metrics.RegisterDatabase(sqlDB, config.PostgresDataSource.DatabaseName); err != nil {
+		panic(err)
+	}
 
 	otpService := service.NewOtpService(service.GenerateOtpCode)
 	userRepo := repository.NewUserRepository(sqlEngine)
 	userService := service.NewUserService(userRepo)
-	authService := service.NewAuthService(userService, config.Security.Secret)
-	authMiddleware := web.NewAuthMiddleware(authService, config.Security.ExcludedRoutePrefixes)
+	notificationPreferenceRepo := repository.NewNotificationPreferenceRepository(sqlEngine)
+	auditEventRepo := repository.NewAuditEventRepository(sqlEngine)
+	auditService := service.NewAuditService(config.Audit, auditEventRepo, config.Security.Secret)
+	accountActionRepo := repository.NewAccountActionRequestRepository(sqlEngine)
+	accountActionService := service.NewAccountActionService(accountActionRepo, userService, auditService)
+	emailOutboxRepo := repository.NewEmailOutboxRepository(sqlEngine)
+	emailTransport := service.MustNewEmailSenderFromConfig(config.Smtp)
+	healthService := service.NewHealthService(config.Server.HealthCheckTimeout)
+	healthService.Register("database", true, repository.NewDatabaseHealthChecker(sqlEngine))
+	healthService.Register("otp_store", true, otpService)
+	if checker, ok := emailTransport.(service.HealthChecker); ok {
+		healthService.Register("email_transport", false, checker)
+	}
+	instrumentedEmailTransport := service.NewInstrumentedEmailSender(emailTransport)
+	emailOutboxService := service.NewEmailOutboxService(config.EmailOutbox, emailOutboxRepo, instrumentedEmailTransport, auditService, config.Security.Secret)
+	var emailSender service.EmailSender = instrumentedEmailTransport
+	if emailOutboxService.Config.Enabled {
+		emailSender = emailOutboxService
+	}
+	smtpService := service.NewSmtpService(config.Smtp, emailSender)
+	templateRegistry := service.MustNewTemplateRegistry(config.Templates)
+	smsSender := service.MustNewSmsSenderFromConfig(config.Sms)
+	lineClient := service.MustNewLineClientFromConfig(config.Line)
+	notificationService := service.NewNotificationService(userService, notificationPreferenceRepo,
+		service.NewEmailNotifier(smtpService, templateRegistry),
+		service.NewSmsNotifier(smsSender, config.Smtp.CompanyName),
+		service.NewLineNotifier(lineClient),
+	)
+	sessionRepo := repository.NewSessionRepository(sqlEngine)
+	knownDeviceRepo := repository.NewKnownDeviceRepository(sqlEngine)
+	sessionService := service.NewSessionService(sessionRepo, knownDeviceRepo, auditService)
+	usedTokenService := service.NewUsedTokenService(repository.NewUsedTokenRepository(sqlEngine))
+	authService := service.NewAuthService(userService, notificationService, auditService, accountActionService, sessionService, usedTokenService, config.Security.GetLoginAlertConfig(), config.Security.Secret)
+	tokenExtractors := web.MustNewTokenExtractorsFromConfig(config.Security)
+	authMiddleware := web.NewAuthMiddleware(authService, config.Security.ExcludedRoutePrefixes, tokenExtractors...)
 	log.Info().Msgf("Security excluded routes: %v", config.Security.ExcludedRoutePrefixes)
-	smtpService := service.NewSmtpService(config.Smtp)
-	resetPasswordService := service.NewUserResetPasswordService(smtpService, userService, authService, otpService)
-	verificationService := service.NewUserVerificationService(smtpService, userService, authService, otpService)
+	csrfService := service.NewCsrfService(config.Security.Secret)
+	csrfMiddleware := web.NewCsrfMiddleware(csrfService, config.Security.GetCsrfConfig())
+	corsMiddleware := web.MustNewCorsMiddleware(config.Cors)
+	securityHeadersMiddleware := web.NewSecurityHeadersMiddleware(config.SecurityHeaders)
+	requestLogger := web.NewRequestLogger(config.RequestLog)
+	otpDeliveryService := service.NewOtpDeliveryService(smtpService, smsSender, templateRegistry, otpService)
+	resetPasswordService := service.NewUserResetPasswordService(userService, authService, otpService, otpDeliveryService, auditService, accountActionService)
+	verificationService := service.NewUserVerificationService(userService, authService, otpService, otpDeliveryService, auditService, accountActionService)
+	phoneVerificationService := service.NewUserPhoneVerificationService(userService, otpService, otpDeliveryService)
+	mfaService := service.NewUserMfaService(userService, authService, otpService, otpDeliveryService, auditService, accountActionService)
+	magicLinkService := service.NewMagicLinkService(config.Security.GetMagicLinkConfig(), userService, authService, otpService, smtpService, templateRegistry, usedTokenService)
 
 	googleAuthService := oauth.NewGoogleAuthService(config.GoogleAuthConfig, authService, userService)
 
@@ -37,24 +83,51 @@ func MustNewSecurityApplicationContext(app *Application) *ApplicationContext {
 	rateLimitedRouterGroup := engine.Group("/api")
 
 	mainController := controller.NewMainController(engine)
-	authController := controller.NewAuthController(baseRouterGroup, authService, resetPasswordService, verificationService, userService, config.Security)
+	healthController := controller.NewHealthController(engine, healthService, config.Server.HealthCheckDetails)
+	metricsController := controller.NewMetricsController(engine, config.Server.MetricsToken)
+	authController := controller.NewAuthController(baseRouterGroup, authService, resetPasswordService, verificationService, userService, csrfService, config.Security)
 	userController := controller.NewUserController(baseRouterGroup, userService, resetPasswordService, verificationService)
-	googleAuthController := controller.NewGoogleAuthController(baseRouterGroup, googleAuthService, config.Security)
+	googleAuthController := controller.NewGoogleAuthController(baseRouterGroup, googleAuthService, csrfService, config.Security)
 	emailRateLimitedController := controller.NewEmailRateLimitedController(rateLimitedRouterGroup, userService, authController)
+	emailOutboxController := controller.NewEmailOutboxController(baseRouterGroup, userService, emailOutboxService)
+	phoneController := controller.NewPhoneController(baseRouterGroup, phoneVerificationService)
+	mfaController := controller.NewMfaController(baseRouterGroup, mfaService, csrfService, config.Security)
+	notificationController := controller.NewNotificationController(baseRouterGroup, notificationService)
+	auditController := controller.NewAuditController(baseRouterGroup, userService, auditService)
+	accountActionController := controller.NewAccountActionController(baseRouterGroup, userService, accountActionService, verificationService)
+	sessionController := controller.NewSessionController(baseRouterGroup, userService, sessionService)
+	impersonationController := controller.NewImpersonationController(baseRouterGroup, authService, userService, csrfService, config.Security)
+	magicLinkController := controller.NewMagicLinkController(rateLimitedRouterGroup, magicLinkService, csrfService, config.Security)
 	controllers := []controller.Controller{
 		mainController,
+		healthController,
+		metricsController,
 		authController,
 		userController,
 		googleAuthController,
 		emailRateLimitedController,
+		emailOutboxController,
+		phoneController,
+		mfaController,
+		notificationController,
+		magicLinkController,
+		auditController,
+		accountActionController,
+		sessionController,
+		impersonationController,
 	}
 	middlewares := []echo.MiddlewareFunc{
+		web.RequestIDMiddlewareFunc,
 		middleware.Recover(),
-		middleware.Logger(),
+		web.TracingMiddlewareFunc,
+		web.MetricsMiddlewareFunc,
+		requestLogger.RequestLoggerMiddlewareFunc,
 		web.ErrorMiddlewareFunc,
+		web.RequestMetadataMiddlewareFunc,
+		securityHeadersMiddleware.SecurityHeadersMiddlewareFunc,
+		corsMiddleware.CorsMiddlewareFunc,
 		authMiddleware.AuthMiddlewareFunc,
-		web.CORSMiddlewareFunc,
-		web.RequestLoggerMiddleware,
+		csrfMiddleware.CsrfMiddlewareFunc,
 	}
 
 	engine.Use(middlewares...)
@@ -64,6 +137,15 @@ func MustNewSecurityApplicationContext(app *Application) *ApplicationContext {
 		userService,
 		authService,
 		smtpService,
+		csrfService,
+		emailOutboxService,
+		notificationService,
+		usedTokenService,
+		magicLinkService,
+		auditService,
+		accountActionService,
+		sessionService,
+		healthService,
 	}
 
 	appContext := &ApplicationContext{
@@ -0,0 +1,276 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ConfigEnvPrefix prefixes the environment variables overriding the config, GOSEC_SECURITY_SECRET sets security.secret.
+	ConfigEnvPrefix = "GOSEC"
+	// ConfigFileSuffix marks a variable naming a file that holds the value, such as a mounted secret.
+	ConfigFileSuffix = "_FILE"
+	MaskedValue      = "******"
+)
+
+// ConfigDefaulter fills the defaults of a config before the YAML file is applied.
+type ConfigDefaulter interface {
+	SetDefaults()
+}
+
+// ConfigValidator rejects a config the application cannot start with.
+type ConfigValidator interface {
+	Validate() error
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadConfig layers the config: defaults, then the YAML file, then the environment variables named after the
+// YAML keys, then the *_FILE variables. The result is validated when the config implements ConfigValidator.
+// An empty path skips the file, the config then comes from the defaults and the environment only.
+func LoadConfig[T any](configPath string, envPrefix string) (*T, error) {
+	config := new(T)
+	if defaulter, ok := any(config).(ConfigDefaulter); ok {
+		defaulter.SetDefaults()
+	}
+	if len(configPath) > 0 {
+		file, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(file, config); err != nil {
+			return nil, fmt.Errorf("%s: %w", configPath, err)
+		}
+	}
+	if err := ApplyConfigEnv(config, envPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if validator, ok := any(config).(ConfigValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func MustLoadConfig[T any](configPath string, envPrefix string) *T {
+	config, err := LoadConfig[T](configPath, envPrefix)
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// ApplyConfigEnv overrides the scalar and string list fields of the config from the variables found by lookup.
+// Nested sections are only allocated when a variable targets one of their fields, maps are not overridable.
+func ApplyConfigEnv(config any, prefix string, lookup func(string) (string, bool)) error {
+	value := reflect.ValueOf(config)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is not a pointer to a struct", ConfigInvalid, config)
+	}
+	_, err := applyConfigEnv(value.Elem(), prefix, lookup)
+	return err
+}
+
+func applyConfigEnv(section reflect.Value, prefix string, lookup func(string) (string, bool)) (bool, error) {
+	applied := false
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Type().Field(i)
+		name, ok := configKey(field)
+		if !ok {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		target := section.Field(i)
+		switch {
+		case target.Kind() == reflect.Struct:
+			fieldApplied, err := applyConfigEnv(target, key, lookup)
+			if err != nil {
+				return false, err
+			}
+			applied = applied || fieldApplied
+		case target.Kind() == reflect.Pointer && target.Type().Elem().Kind() == reflect.Struct:
+			nested := target
+			if target.IsNil() {
+				nested = reflect.New(target.Type().Elem())
+			}
+			fieldApplied, err := applyConfigEnv(nested.Elem(), key, lookup)
+			if err != nil {
+				return false, err
+			}
+			if fieldApplied && target.IsNil() {
+				target.Set(nested)
+			}
+			applied = applied || fieldApplied
+		default:
+			raw, found, err := lookupConfigValue(key, lookup)
+			if err != nil {
+				return false, err
+			}
+			if !found {
+				continue
+			}
+			if err := setConfigValue(target, raw); err != nil {
+				return false, fmt.Errorf("%w: %s: %v", ConfigInvalid, key, err)
+			}
+			applied = true
+		}
+	}
+	return applied, nil
+}
+
+// lookupConfigValue reads KEY, then KEY_FILE which wins when both are set.
+func lookupConfigValue(key string, lookup func(string) (string, bool)) (string, bool, error) {
+	raw, found := lookup(key)
+	if path, ok := lookup(key + ConfigFileSuffix); ok && len(path) > 0 {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%w: %s%s: %v", ConfigInvalid, key, ConfigFileSuffix, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	}
+	return raw, found, nil
+}
+
+func setConfigValue(target reflect.Value, raw string) error {
+	if target.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		target.SetInt(int64(duration))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		target.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetFloat(parsed)
+	case reflect.Slice:
+		if target.Type().Elem().Kind() != reflect.String {
+			return errors.New("only lists of strings can be overridden")
+		}
+		items := make([]string, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		target.Set(list)
+	default:
+		return fmt.Errorf("%s fields cannot be overridden", target.Kind())
+	}
+	return nil
+}
+
+// configKey is the YAML key of the field, unexported and ignored fields have none.
+func configKey(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if len(name) == 0 {
+		name = strings.ToLower(field.Name)
+	}
+	return name, true
+}
+
+// MaskedConfigJson renders the config under its YAML keys for the startup log, the fields tagged
+// secret:"true" are masked when set.
+func MaskedConfigJson(config any) string {
+	masked, err := json.MarshalIndent(maskConfigValue(reflect.ValueOf(config)), "", "   ")
+	if err != nil {
+		return ""
+	}
+	return string(masked)
+}
+
+func maskConfigValue(value reflect.Value) any {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return maskConfigValue(value.Elem())
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(time.Time{}) {
+			return value.Interface()
+		}
+		section := make(map[string]any, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name, ok := configKey(field)
+			if !ok {
+				continue
+			}
+			if field.Tag.Get("secret") == "true" {
+				section[name] = ""
+				if !value.Field(i).IsZero() {
+					section[name] = MaskedValue
+				}
+				continue
+			}
+			section[name] = maskConfigValue(value.Field(i))
+		}
+		return section
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		entries := make(map[string]any, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			entries[fmt.Sprint(iterator.Key().Interface())] = maskConfigValue(iterator.Value())
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		items := make([]any, value.Len())
+		for i := range items {
+			items[i] = maskConfigValue(value.Index(i))
+		}
+		return items
+	}
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+	return value.Interface()
+}
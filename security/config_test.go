@@ -0,0 +1,225 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Password string `yaml:"password" secret:"true"`
+}
+
+type testTracingConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	Ratio   float64 `yaml:"ratio"`
+}
+
+type testConfig struct {
+	Port     int                 `yaml:"port"`
+	Timeout  time.Duration       `yaml:"timeout"`
+	Origins  []string            `yaml:"origins"`
+	Secret   string              `yaml:"secret" secret:"true"`
+	Ignored  string              `yaml:"-"`
+	Database testDatabaseConfig  `yaml:"database"`
+	Tracing  *testTracingConfig  `yaml:"tracing"`
+	Routes   map[string][]string `yaml:"routes"`
+}
+
+func (config *testConfig) SetDefaults() {
+	config.Port = 8080
+	config.Timeout = time.Second
+}
+
+func (config *testConfig) Validate() error {
+	if config.Port == 0 {
+		return ConfigInvalid
+	}
+	return nil
+}
+
+func lookupOf(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfigEnv(t *testing.T) {
+	secretFile := writeConfigFile(t, "secret", "from-file\n")
+	tests := []struct {
+		name   string
+		env    map[string]string
+		expect testConfig
+		err    error
+	}{
+		{
+			name:   "nothing set",
+			env:    map[string]string{},
+			expect: testConfig{},
+		},
+		{
+			name:   "scalars and nested struct",
+			env:    map[string]string{"APP_PORT": "9090", "APP_DATABASE_HOST": "db", "APP_IGNORED": "x"},
+			expect: testConfig{Port: 9090, Database: testDatabaseConfig{Host: "db"}},
+		},
+		{
+			name:   "duration",
+			env:    map[string]string{"APP_TIMEOUT": "1m30s"},
+			expect: testConfig{Timeout: 90 * time.Second},
+		},
+		{
+			name:   "comma separated list",
+			env:    map[string]string{"APP_ORIGINS": " https://a.example.com, ,https://b.example.com "},
+			expect: testConfig{Origins: []string{"https://a.example.com", "https://b.example.com"}},
+		},
+		{
+			name:   "empty list",
+			env:    map[string]string{"APP_ORIGINS": ""},
+			expect: testConfig{Origins: []string{}},
+		},
+		{
+			name:   "file wins over the value",
+			env:    map[string]string{"APP_SECRET": "from-env", "APP_SECRET_FILE": secretFile},
+			expect: testConfig{Secret: "from-file"},
+		},
+		{
+			name:   "empty file variable is ignored",
+			env:    map[string]string{"APP_SECRET": "from-env", "APP_SECRET_FILE": ""},
+			expect: testConfig{Secret: "from-env"},
+		},
+		{
+			name:   "pointer section allocated when targeted",
+			env:    map[string]string{"APP_TRACING_RATIO": "0.5"},
+			expect: testConfig{Tracing: &testTracingConfig{Ratio: 0.5}},
+		},
+		{
+			name: "missing file",
+			env:  map[string]string{"APP_SECRET_FILE": filepath.Join(t.TempDir(), "missing")},
+			err:  ConfigInvalid,
+		},
+		{
+			name: "invalid duration",
+			env:  map[string]string{"APP_TIMEOUT": "soon"},
+			err:  ConfigInvalid,
+		},
+		{
+			name: "invalid bool",
+			env:  map[string]string{"APP_TRACING_ENABLED": "maybe"},
+			err:  ConfigInvalid,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config testConfig
+			err := ApplyConfigEnv(&config, "APP", lookupOf(test.env))
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config, test.expect) {
+				t.Fatalf("expected %+v, got %+v", test.expect, config)
+			}
+		})
+	}
+}
+
+func TestApplyConfigEnvKeepsUntargetedSections(t *testing.T) {
+	config := testConfig{Tracing: &testTracingConfig{Enabled: true}}
+	if err := ApplyConfigEnv(&config, "APP", lookupOf(map[string]string{"APP_TRACING_RATIO": "0.25"})); err != nil {
+		t.Fatal(err)
+	}
+	if !config.Tracing.Enabled || config.Tracing.Ratio != 0.25 {
+		t.Fatalf("an allocated section must be overridden in place, got %+v", config.Tracing)
+	}
+	if err := ApplyConfigEnv(config, "APP", lookupOf(nil)); !errors.Is(err, ConfigInvalid) {
+		t.Fatalf("a struct value must be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "port: 7070\ntimeout: 5s\ndatabase:\n  host: yaml-db\n")
+	t.Setenv("APP_DATABASE_HOST", "env-db")
+	config, err := LoadConfig[testConfig](path, "APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != 7070 || config.Timeout != 5*time.Second || config.Database.Host != "env-db" {
+		t.Fatalf("expected the file over the defaults and the environment over the file, got %+v", config)
+	}
+
+	config, err = LoadConfig[testConfig]("", "APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != 8080 || config.Timeout != time.Second {
+		t.Fatalf("expected the defaults without a file, got %+v", config)
+	}
+
+	t.Setenv("APP_PORT", "0")
+	if _, err := LoadConfig[testConfig]("", "APP"); !errors.Is(err, ConfigInvalid) {
+		t.Fatalf("expected the config to be validated, got %v", err)
+	}
+	if _, err := LoadConfig[testConfig](writeConfigFile(t, "broken.yaml", "port: [\n"), "APP"); err == nil {
+		t.Fatal("expected a malformed file to be rejected")
+	}
+}
+
+func TestMaskedConfigJson(t *testing.T) {
+	config := &testConfig{
+		Port:     8080,
+		Timeout:  time.Minute,
+		Secret:   "super-secret-value",
+		Database: testDatabaseConfig{Host: "db"},
+		Routes:   map[string][]string{"/api": {"GET"}},
+	}
+	rendered := MaskedConfigJson(config)
+	if strings.Contains(rendered, "super-secret-value") {
+		t.Fatalf("the secret must be masked, got %s", rendered)
+	}
+	var masked map[string]any
+	if err := json.Unmarshal([]byte(rendered), &masked); err != nil {
+		t.Fatal(err)
+	}
+	database := masked["database"].(map[string]any)
+	tests := []struct {
+		key    string
+		value  any
+		actual any
+	}{
+		{"secret", MaskedValue, masked["secret"]},
+		{"unset secret", "", database["password"]},
+		{"timeout", "1m0s", masked["timeout"]},
+		{"port", float64(8080), masked["port"]},
+		{"nil section", nil, masked["tracing"]},
+		{"nested", "db", database["host"]},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.actual, test.value) {
+			t.Errorf("%s: expected %v, got %v", test.key, test.value, test.actual)
+		}
+	}
+	if _, ok := masked["-"]; ok {
+		t.Error("fields without a YAML key must be left out")
+	}
+}
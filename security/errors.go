@@ -15,9 +15,47 @@ var (
 	UserRoleNotFound                     = errors.New("UserRoleNotFound")
 	TokenExpired                         = errors.New("TokenExpired")
 	TokenInvalid                         = errors.New("TokenInvalid")
+	TokenAlreadyUsed                     = errors.New("TokenAlreadyUsed")
 	OtpNotFound                          = errors.New("OtpNotFound")
 	OtpIncorrect                         = errors.New("OtpIncorrect")
 	OtpExpired                           = errors.New("OtpExpired")
+	OtpAttemptsExceeded                  = errors.New("OtpAttemptsExceeded")
 	ResetPasswordNotMatched              = errors.New("ResetPasswordNotMatched")
+	PasswordResetRequired                = errors.New("PasswordResetRequired")
 	SelfPlatformRequiredForPasswordReset = errors.New("SelfPlatformRequiredForPasswordReset")
+	CsrfTokenInvalid                     = errors.New("CsrfTokenInvalid")
+	EmailOutboxMessageNotFound           = errors.New("EmailOutboxMessageNotFound")
+	EmailOutboxMessageNotRetryable       = errors.New("EmailOutboxMessageNotRetryable")
+	EmailOutboxBodyUnreadable            = errors.New("EmailOutboxBodyUnreadable")
+	TemplateNotFound                     = errors.New("TemplateNotFound")
+	PhoneNumberNotAllowed                = errors.New("PhoneNumberNotAllowed")
+	PhoneNumberNotVerified               = errors.New("PhoneNumberNotVerified")
+	PhoneNumberAlreadyUsed               = errors.New("PhoneNumberAlreadyUsed")
+	PhoneNumberAlreadyVerified           = errors.New("PhoneNumberAlreadyVerified")
+	SmsRateLimitExceeded                 = errors.New("SmsRateLimitExceeded")
+	SmsTransportNotSupported             = errors.New("SmsTransportNotSupported")
+	DeliveryChannelNotSupported          = errors.New("DeliveryChannelNotSupported")
+	NotificationChannelUnavailable       = errors.New("NotificationChannelUnavailable")
+	MagicLinkDisabled                    = errors.New("MagicLinkDisabled")
+	MagicLinkAlreadyUsed                 = errors.New("MagicLinkAlreadyUsed")
+	MagicLinkBrowserMismatch             = errors.New("MagicLinkBrowserMismatch")
+	AuditEventImmutable                  = errors.New("AuditEventImmutable")
+	AuditExportFormatNotSupported        = errors.New("AuditExportFormatNotSupported")
+	AccountActionNotSupported            = errors.New("AccountActionNotSupported")
+	MfaAlreadyEnabled                    = errors.New("MfaAlreadyEnabled")
+	MfaNotEnabled                        = errors.New("MfaNotEnabled")
+	MfaChallengePending                  = errors.New("MfaChallengePending")
+	MfaChallengeNotPending               = errors.New("MfaChallengeNotPending")
+	SessionNotFound                      = errors.New("SessionNotFound")
+	SessionExpired                       = errors.New("SessionExpired")
+	SessionRevoked                       = errors.New("SessionRevoked")
+	SessionLimitReached                  = errors.New("SessionLimitReached")
+	SessionLimitPolicyNotSupported       = errors.New("SessionLimitPolicyNotSupported")
+	ImpersonationNotAllowed              = errors.New("ImpersonationNotAllowed")
+	ImpersonationActionForbidden         = errors.New("ImpersonationActionForbidden")
+	NotImpersonating                     = errors.New("NotImpersonating")
+	ImpersonatorSessionEnded             = errors.New("ImpersonatorSessionEnded")
+	ConfigInvalid                        = errors.New("ConfigInvalid")
+	CorsWildcardWithCredentials          = errors.New("CorsWildcardWithCredentials")
+	CorsWildcardOriginInvalid            = errors.New("CorsWildcardOriginInvalid")
 )
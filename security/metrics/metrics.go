@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const (
+	Namespace = "security"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Registry holds the metrics of the application, it is served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the HTTP requests per route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "auth",
+		Name:      "login_attempts_total",
+		Help:      "Login attempts per method and outcome.",
+	}, []string{"method", "outcome"})
+
+	TokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "auth",
+		Name:      "token_validation_failures_total",
+		Help:      "Login tokens rejected by the auth middleware per reason.",
+	}, []string{"reason"})
+
+	OtpIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "otp",
+		Name:      "issued_total",
+		Help:      "One-time passwords issued per purpose.",
+	}, []string{"purpose"})
+
+	OtpVerified = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "otp",
+		Name:      "verified_total",
+		Help:      "One-time password verifications per purpose and outcome.",
+	}, []string{"purpose", "outcome"})
+
+	EmailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "email",
+		Name:      "sent_total",
+		Help:      "Emails handed to the transport per outcome.",
+	}, []string{"outcome"})
+
+	RateLimitDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "rate_limit",
+		Name:      "denials_total",
+		Help:      "Requests denied by a rate limiter per limiter and route.",
+	}, []string{"limiter", "route"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequestDuration,
+		LoginAttempts,
+		TokenValidationFailures,
+		OtpIssued,
+		OtpVerified,
+		EmailsSent,
+		RateLimitDenials,
+	)
+}
+
+// Outcome labels an operation by its error.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// RegisterDatabase exposes the connection pool stats of the database, registering the same name twice is a no-op.
+func RegisterDatabase(db *sql.DB, name string) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+type IAccountActionRequestRepository interface {
+	Save(ctx context.Context, request *AccountActionRequest) error
+	FindPending(ctx context.Context, userID uint, action AccountAction) (*AccountActionRequest, error)
+	FindPendingByUserID(ctx context.Context, userID uint) ([]*AccountActionRequest, error)
+	CompletePending(ctx context.Context, userID uint, action AccountAction, completedAt time.Time) (int64, error)
+}
+
+type AccountActionRequestRepository struct {
+	Engine *gorm.DB
+}
+
+func NewAccountActionRequestRepository(engine *gorm.DB) *AccountActionRequestRepository {
+	return &AccountActionRequestRepository{
+		Engine: engine,
+	}
+}
+
+func (repo *AccountActionRequestRepository) Save(ctx context.Context, request *AccountActionRequest) error {
+	return repo.Engine.WithContext(ctx).Save(request).Error
+}
+
+// FindPending returns the pending request of the action, or nil when there is none.
+func (repo *AccountActionRequestRepository) FindPending(ctx context.Context, userID uint, action AccountAction) (*AccountActionRequest, error) {
+	var request AccountActionRequest
+	err := repo.Engine.WithContext(ctx).
+		Where("user_id = ? AND action = ? AND completed_at IS NULL", userID, action).
+		Take(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (repo *AccountActionRequestRepository) FindPendingByUserID(ctx context.Context, userID uint) ([]*AccountActionRequest, error) {
+	var requests []*AccountActionRequest
+	err := repo.Engine.WithContext(ctx).
+		Where("user_id = ? AND completed_at IS NULL", userID).
+		Order("id").
+		Find(&requests).Error
+	return requests, err
+}
+
+// CompletePending marks the pending request of the action as completed and returns the number of completed requests.
+func (repo *AccountActionRequestRepository) CompletePending(ctx context.Context, userID uint, action AccountAction, completedAt time.Time) (int64, error) {
+	result := repo.Engine.WithContext(ctx).Model(&AccountActionRequest{}).
+		Where("user_id = ? AND action = ? AND completed_at IS NULL", userID, action).
+		Update("completed_at", completedAt)
+	return result.RowsAffected, result.Error
+}
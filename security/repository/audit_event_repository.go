@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// auditChainLockKey is the Postgres advisory lock serializing appends, so replicas extend a single chain.
+const auditChainLockKey = 7_204_119_003
+
+type AuditEventFilter struct {
+	ActorID   *uint
+	SubjectID *uint
+	Action    string
+	Outcome   AuditOutcome
+	IPAddress string
+	From      *time.Time
+	To        *time.Time
+}
+
+// IAuditEventRepository only appends and reads, audit events are never updated or deleted.
+type IAuditEventRepository interface {
+	Append(ctx context.Context, event *AuditEvent, seal func(previous *AuditEvent) error) error
+	FindLast(ctx context.Context) (*AuditEvent, error)
+	FindAll(ctx context.Context, filter *AuditEventFilter, limit int, offset int) ([]*AuditEvent, int64, error)
+	FindInBatches(ctx context.Context, filter *AuditEventFilter, batchSize int, handle func(events []*AuditEvent) error) error
+	SaveCheckpoint(ctx context.Context, checkpoint *AuditCheckpoint) error
+	FindLatestCheckpoint(ctx context.Context) (*AuditCheckpoint, error)
+	FindCheckpoints(ctx context.Context) ([]*AuditCheckpoint, error)
+}
+
+type AuditEventRepository struct {
+	Engine *gorm.DB
+}
+
+func NewAuditEventRepository(engine *gorm.DB) *AuditEventRepository {
+	return &AuditEventRepository{
+		Engine: engine,
+	}
+}
+
+// Append inserts the event after the last one, seal is called with the last event (nil for the first
+// one) while the chain is locked, so it can link the new event to it.
+func (repo *AuditEventRepository) Append(ctx context.Context, event *AuditEvent, seal func(previous *AuditEvent) error) error {
+	return repo.Engine.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey).Error; err != nil {
+			return err
+		}
+		previous, err := findLast(tx)
+		if err != nil {
+			return err
+		}
+		if err := seal(previous); err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+}
+
+func findLast(tx *gorm.DB) (*AuditEvent, error) {
+	var event AuditEvent
+	err := tx.Order("id DESC").Take(&event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// FindLast returns the most recent event, or nil when the log is empty.
+func (repo *AuditEventRepository) FindLast(ctx context.Context) (*AuditEvent, error) {
+	return findLast(repo.Engine.WithContext(ctx))
+}
+
+func (repo *AuditEventRepository) applyFilter(tx *gorm.DB, filter *AuditEventFilter) *gorm.DB {
+	if filter == nil {
+		return tx
+	}
+	if filter.ActorID != nil {
+		tx = tx.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.SubjectID != nil {
+		tx = tx.Where("subject_id = ?", *filter.SubjectID)
+	}
+	if len(filter.Action) > 0 {
+		tx = tx.Where("action = ?", filter.Action)
+	}
+	if len(filter.Outcome) > 0 {
+		tx = tx.Where("outcome = ?", filter.Outcome)
+	}
+	if len(filter.IPAddress) > 0 {
+		tx = tx.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.From != nil {
+		tx = tx.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		tx = tx.Where("created_at < ?", *filter.To)
+	}
+	return tx
+}
+
+func (repo *AuditEventRepository) FindAll(ctx context.Context, filter *AuditEventFilter, limit int, offset int) ([]*AuditEvent, int64, error) {
+	var events []*AuditEvent
+	var total int64
+	tx := repo.applyFilter(repo.Engine.WithContext(ctx).Model(&AuditEvent{}), filter)
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := tx.Order("id DESC").Limit(limit).Offset(offset).Find(&events).Error
+	return events, total, err
+}
+
+// FindInBatches walks the matching events in id order, so exports do not hold the whole table in memory.
+func (repo *AuditEventRepository) FindInBatches(ctx context.Context, filter *AuditEventFilter, batchSize int, handle func(events []*AuditEvent) error) error {
+	var events []*AuditEvent
+	tx := repo.applyFilter(repo.Engine.WithContext(ctx).Model(&AuditEvent{}), filter)
+	return tx.FindInBatches(&events, batchSize, func(_ *gorm.DB, _ int) error {
+		return handle(events)
+	}).Error
+}
+
+func (repo *AuditEventRepository) SaveCheckpoint(ctx context.Context, checkpoint *AuditCheckpoint) error {
+	return repo.Engine.WithContext(ctx).Create(checkpoint).Error
+}
+
+// FindLatestCheckpoint returns the checkpoint of the most recent event, or nil when none was taken yet.
+func (repo *AuditEventRepository) FindLatestCheckpoint(ctx context.Context) (*AuditCheckpoint, error) {
+	var checkpoint AuditCheckpoint
+	err := repo.Engine.WithContext(ctx).Order("event_id DESC").Take(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+func (repo *AuditEventRepository) FindCheckpoints(ctx context.Context) ([]*AuditCheckpoint, error) {
+	var checkpoints []*AuditCheckpoint
+	err := repo.Engine.WithContext(ctx).Order("event_id").Find(&checkpoints).Error
+	return checkpoints, err
+}
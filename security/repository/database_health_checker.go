@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// DatabaseHealthChecker pings the connection pool of the engine.
+type DatabaseHealthChecker struct {
+	Engine *gorm.DB
+}
+
+func NewDatabaseHealthChecker(engine *gorm.DB) *DatabaseHealthChecker {
+	return &DatabaseHealthChecker{
+		Engine: engine,
+	}
+}
+
+func (checker *DatabaseHealthChecker) CheckHealth(ctx context.Context) error {
+	sqlDB, err := checker.Engine.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
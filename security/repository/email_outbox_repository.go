@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type IEmailOutboxRepository interface {
+	Save(ctx context.Context, message *EmailOutboxMessage) error
+	FindByID(ctx context.Context, id uint) (*EmailOutboxMessage, error)
+	FindAll(ctx context.Context, status EmailOutboxStatus, limit int, offset int) ([]*EmailOutboxMessage, int64, error)
+	ClaimDueMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*EmailOutboxMessage, error)
+	CountByStatus(ctx context.Context, status EmailOutboxStatus) (int64, error)
+}
+
+type EmailOutboxRepository struct {
+	Engine *gorm.DB
+}
+
+func NewEmailOutboxRepository(engine *gorm.DB) *EmailOutboxRepository {
+	return &EmailOutboxRepository{
+		Engine: engine,
+	}
+}
+
+// Save joins the transaction carried by the context, see ContextWithTransaction.
+func (repo *EmailOutboxRepository) Save(ctx context.Context, message *EmailOutboxMessage) error {
+	return engineFromContext(ctx, repo.Engine).Save(message).Error
+}
+
+func (repo *EmailOutboxRepository) FindByID(ctx context.Context, id uint) (*EmailOutboxMessage, error) {
+	var message EmailOutboxMessage
+	err := repo.Engine.WithContext(ctx).First(&message, id).Error
+	return &message, err
+}
+
+func (repo *EmailOutboxRepository) FindAll(ctx context.Context, status EmailOutboxStatus, limit int, offset int) ([]*EmailOutboxMessage, int64, error) {
+	var messages []*EmailOutboxMessage
+	var total int64
+	tx := repo.Engine.WithContext(ctx).Model(&EmailOutboxMessage{})
+	if len(status) > 0 {
+		tx = tx.Where("status = ?", status)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := tx.Order("id DESC").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, total, err
+}
+
+func (repo *EmailOutboxRepository) CountByStatus(ctx context.Context, status EmailOutboxStatus) (int64, error) {
+	var total int64
+	err := repo.Engine.WithContext(ctx).Model(&EmailOutboxMessage{}).Where("status = ?", status).Count(&total).Error
+	return total, err
+}
+
+// ClaimDueMessages locks due messages with SKIP LOCKED so several replicas can poll the same table,
+// and leases them by pushing NextAttemptAt forward. A message whose worker died is picked up again
+// once its lease expires.
+func (repo *EmailOutboxRepository) ClaimDueMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*EmailOutboxMessage, error) {
+	var messages []*EmailOutboxMessage
+	err := repo.Engine.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []EmailOutboxStatus{EmailOutboxStatusPending, EmailOutboxStatusSending}, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]uint, 0, len(messages))
+		for _, message := range messages {
+			ids = append(ids, message.ID)
+			message.Status = EmailOutboxStatusSending
+			message.NextAttemptAt = now.Add(lease)
+		}
+		return tx.Model(&EmailOutboxMessage{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":          EmailOutboxStatusSending,
+			"next_attempt_at": now.Add(lease),
+		}).Error
+	})
+	return messages, err
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IKnownDeviceRepository interface {
+	CountByUserID(ctx context.Context, userID uint) (int64, error)
+	Register(ctx context.Context, device *KnownDevice) (bool, error)
+	Delete(ctx context.Context, userID uint, fingerprint string) error
+}
+
+type KnownDeviceRepository struct {
+	Engine *gorm.DB
+}
+
+func NewKnownDeviceRepository(engine *gorm.DB) *KnownDeviceRepository {
+	return &KnownDeviceRepository{
+		Engine: engine,
+	}
+}
+
+func (repo *KnownDeviceRepository) CountByUserID(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := repo.Engine.WithContext(ctx).Model(&KnownDevice{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// Register stores the device, or refreshes its last-seen timestamp if the user already used it.
+// It reports whether the device was new.
+func (repo *KnownDeviceRepository) Register(ctx context.Context, device *KnownDevice) (bool, error) {
+	result := repo.Engine.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "fingerprint"}},
+		DoNothing: true,
+	}).Create(device)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+	err := repo.Engine.WithContext(ctx).Model(&KnownDevice{}).
+		Where("user_id = ? AND fingerprint = ?", device.UserID, device.Fingerprint).
+		Updates(map[string]any{"last_seen_at": device.LastSeenAt, "updated_at": device.LastSeenAt}).Error
+	return false, err
+}
+
+func (repo *KnownDeviceRepository) Delete(ctx context.Context, userID uint, fingerprint string) error {
+	return repo.Engine.WithContext(ctx).Where("user_id = ? AND fingerprint = ?", userID, fingerprint).Delete(&KnownDevice{}).Error
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"fmt"
 	"go-security/security"
+	"gorm.io/gorm"
 	"net/mail"
 	"time"
 )
@@ -11,7 +12,7 @@ type PostgresDataSourceConfig struct {
 	Host         string `yaml:"host"`
 	Port         int    `yaml:"port"`
 	User         string `yaml:"user"`
-	Password     string `yaml:"password"`
+	Password     string `yaml:"password" json:"-" secret:"true"`
 	DatabaseName string `yaml:"db_name"`
 }
 
@@ -24,10 +25,20 @@ func (config *PostgresDataSourceConfig) AsDSN() string {
 
 type RoleIndex uint
 
+type SessionLimitPolicy string
+
+const (
+	SessionLimitPolicyEvictOldest SessionLimitPolicy = "evict_oldest"
+	SessionLimitPolicyReject      SessionLimitPolicy = "reject"
+)
+
 type UserRole struct {
 	Name      string `gorm:"type:varchar(50);not null" json:"name"`
 	RoleIndex uint   `gorm:"type:int;unique;not null" json:"role_index"`
 	Users     []User `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"users"`
+	// MaxSessions caps the active sessions of each user of the role, zero means unlimited.
+	MaxSessions        uint               `gorm:"not null;default:0" json:"max_sessions"`
+	SessionLimitPolicy SessionLimitPolicy `gorm:"type:varchar(20);not null;default:'evict_oldest'" json:"session_limit_policy"`
 
 	ID        uint       `gorm:"primaryKey" json:"id"` // Auto-increment primary key
 	CreatedAt time.Time  `json:"created_at"`
@@ -45,6 +56,13 @@ type User struct {
 	PlatformID uint     `gorm:"not null" json:"platform_id"` // Foreign key
 	Platform   Platform `gorm:"foreignKey:PlatformID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"platform"`
 	ExternalID *string  `gorm:"type:varchar(100);unique" json:"external_id"`
+	Locale     string   `gorm:"type:varchar(20)" json:"locale"`
+
+	PhoneNumber     *string `gorm:"type:varchar(20);unique" json:"phone_number"` // E.164 format
+	IsPhoneVerified bool    `gorm:"default:false" json:"is_phone_verified"`
+
+	IsMfaEnabled bool   `gorm:"default:false" json:"is_mfa_enabled"`
+	MfaChannel   string `gorm:"type:varchar(20)" json:"mfa_channel"` // Delivery channel of the sign-in codes
 
 	ID        uint       `gorm:"primaryKey" json:"id"` // Auto-increment primary key
 	CreatedAt time.Time  `json:"created_at"`
@@ -74,3 +92,170 @@ func (user *User) Validate() error {
 	}
 	return nil
 }
+
+type EmailOutboxStatus string
+
+const (
+	EmailOutboxStatusPending EmailOutboxStatus = "pending"
+	EmailOutboxStatusSending EmailOutboxStatus = "sending"
+	EmailOutboxStatusSent    EmailOutboxStatus = "sent"
+	EmailOutboxStatusDead    EmailOutboxStatus = "dead"
+)
+
+type EmailOutboxMessage struct {
+	Sender        string            `gorm:"type:varchar(255);not null" json:"sender"`
+	Recipient     string            `gorm:"type:varchar(255);not null;index" json:"recipient"`
+	Subject       string            `gorm:"type:varchar(255);not null" json:"subject"`
+	Body          string            `gorm:"type:text;not null" json:"-"` // Encrypted, it may carry OTPs, reset tokens or magic links
+	ContentType   string            `gorm:"type:varchar(50);not null" json:"content_type"`
+	TextBody      string            `gorm:"type:text" json:"-"`           // Encrypted plain-text alternative of Body
+	Attachments   string            `gorm:"type:text" json:"attachments"` // Newline separated file paths
+	Status        EmailOutboxStatus `gorm:"type:varchar(20);not null;index:idx_email_outbox_due,priority:1" json:"status"`
+	Attempts      int               `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts   int               `gorm:"not null" json:"max_attempts"`
+	NextAttemptAt time.Time         `gorm:"not null;index:idx_email_outbox_due,priority:2" json:"next_attempt_at"`
+	LastError     string            `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time        `json:"sent_at"`
+	RequestID     string            `gorm:"type:varchar(128)" json:"request_id"` // Request the email originates from, carried into its delivery logs
+
+	ID        uint      `gorm:"primaryKey" json:"id"` // Auto-increment primary key
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NotificationPreference struct {
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_notification_preference_user_channel" json:"user_id"`
+	Channel string `gorm:"type:varchar(20);not null;uniqueIndex:idx_notification_preference_user_channel" json:"channel"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+
+	ID        uint      `gorm:"primaryKey" json:"id"` // Auto-increment primary key
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// AuditEvent is an append-only record of a security-relevant action. The actor performed the action,
+// the subject is the user it was performed on, both are empty when unknown (e.g. a login with an unknown email).
+type AuditEvent struct {
+	ActorID   *uint          `gorm:"index" json:"actor_id"`
+	SubjectID *uint          `gorm:"index" json:"subject_id"`
+	Action    string         `gorm:"type:varchar(100);not null;index" json:"action"`
+	IPAddress string         `gorm:"type:varchar(64)" json:"ip_address"`
+	UserAgent string         `gorm:"type:text" json:"user_agent"`
+	Outcome   AuditOutcome   `gorm:"type:varchar(20);not null;index" json:"outcome"`
+	Metadata  map[string]any `gorm:"serializer:json;type:jsonb" json:"metadata"`
+	// PreviousHash links the event to the one before it, Hash covers the event including PreviousHash,
+	// so editing or deleting an event breaks the chain from there on.
+	PreviousHash string `gorm:"type:varchar(64)" json:"previous_hash"`
+	Hash         string `gorm:"type:varchar(64);index" json:"hash"`
+
+	ID        uint      `gorm:"primaryKey" json:"id"` // Auto-increment primary key
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (event *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return security.AuditEventImmutable
+}
+
+func (event *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return security.AuditEventImmutable
+}
+
+// AuditCheckpoint signs the hash of an audit event with the service key, attesting the chain up to that event.
+type AuditCheckpoint struct {
+	EventID   uint   `gorm:"not null;uniqueIndex" json:"event_id"`
+	EventHash string `gorm:"type:varchar(64);not null" json:"event_hash"`
+	Signature string `gorm:"type:varchar(100);not null" json:"signature"`
+
+	ID        uint      `gorm:"primaryKey" json:"id"` // Auto-increment primary key
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (checkpoint *AuditCheckpoint) BeforeUpdate(tx *gorm.DB) error {
+	return security.AuditEventImmutable
+}
+
+func (checkpoint *AuditCheckpoint) BeforeDelete(tx *gorm.DB) error {
+	return security.AuditEventImmutable
+}
+
+type AccountAction string
+
+const (
+	AccountActionVerifyEmail   AccountAction = "verify_email"
+	AccountActionResetPassword AccountAction = "reset_password"
+	AccountActionAcceptTerms   AccountAction = "accept_terms"
+	AccountActionEnrollMfa     AccountAction = "enroll_mfa"
+)
+
+// AccountActionRequest asks a user to perform an action on their account, usually on behalf of an admin.
+// A user has at most one pending request per action, a request stays in the table once completed.
+type AccountActionRequest struct {
+	UserID        uint          `gorm:"not null;index;uniqueIndex:idx_account_action_request_pending,where:completed_at IS NULL" json:"user_id"`
+	Action        AccountAction `gorm:"type:varchar(50);not null;uniqueIndex:idx_account_action_request_pending,where:completed_at IS NULL" json:"action"`
+	RequestedByID *uint         `json:"requested_by_id"` // Empty when requested by the system
+	DueAt         *time.Time    `json:"due_at"`
+	Required      bool          `gorm:"not null;default:false" json:"required"` // Restricts login to the required-action endpoints until completed
+	CompletedAt   *time.Time    `gorm:"index" json:"completed_at"`
+
+	ID        uint      `gorm:"primaryKey" json:"id"` // Auto-increment primary key
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (request *AccountActionRequest) IsOverdue(now time.Time) bool {
+	return request.CompletedAt == nil && request.DueAt != nil && request.DueAt.Before(now)
+}
+
+// Session is a login of a user on a device, its ID is embedded in the login token so it can be revoked.
+type Session struct {
+	ID         string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Platform   string     `gorm:"type:varchar(20);not null" json:"platform"`
+	Device     string     `gorm:"type:varchar(100)" json:"device"`
+	UserAgent  string     `gorm:"type:text" json:"user_agent"`
+	IPAddress  string     `gorm:"type:varchar(64)" json:"ip_address"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	// ImpersonatorID is the admin who opened the session to act as the user.
+	ImpersonatorID *uint `gorm:"index" json:"impersonator_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (session *Session) IsActive(now time.Time) bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(now)
+}
+
+// KnownDevice is a user agent and IP pair a user signed in from, logins from unknown pairs are reported to the user.
+type KnownDevice struct {
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_known_device_user_fingerprint" json:"user_id"`
+	Fingerprint string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_known_device_user_fingerprint" json:"fingerprint"`
+	Device      string    `gorm:"type:varchar(100)" json:"device"`
+	UserAgent   string    `gorm:"type:text" json:"user_agent"`
+	IPAddress   string    `gorm:"type:varchar(64)" json:"ip_address"`
+	LastSeenAt  time.Time `gorm:"not null" json:"last_seen_at"`
+
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UsedToken records the ID of a single-use token once redeemed, the unique index rejects a second
+// redemption across instances. Rows are pruned once the token expired, as it is rejected anyway.
+type UsedToken struct {
+	TokenID   string    `gorm:"type:varchar(128);not null;uniqueIndex" json:"token_id"`
+	Purpose   string    `gorm:"type:varchar(32);not null" json:"purpose"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type INotificationPreferenceRepository interface {
+	FindByUserID(ctx context.Context, userID uint) ([]*NotificationPreference, error)
+	Upsert(ctx context.Context, preference *NotificationPreference) error
+}
+
+type NotificationPreferenceRepository struct {
+	Engine *gorm.DB
+}
+
+func NewNotificationPreferenceRepository(engine *gorm.DB) *NotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{
+		Engine: engine,
+	}
+}
+
+func (repo *NotificationPreferenceRepository) FindByUserID(ctx context.Context, userID uint) ([]*NotificationPreference, error) {
+	var preferences []*NotificationPreference
+	err := repo.Engine.WithContext(ctx).Find(&preferences, "user_id = ?", userID).Error
+	return preferences, err
+}
+
+func (repo *NotificationPreferenceRepository) Upsert(ctx context.Context, preference *NotificationPreference) error {
+	return repo.Engine.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(preference).Error
+}
@@ -9,5 +9,13 @@ func NewSecurityModelProvider() *SecurityModelProvider {
 func (provider *SecurityModelProvider) ProvideModels() []any {
 	return []any{
 		&User{},
+		&EmailOutboxMessage{},
+		&NotificationPreference{},
+		&AuditEvent{},
+		&AuditCheckpoint{},
+		&AccountActionRequest{},
+		&Session{},
+		&KnownDevice{},
+		&UsedToken{},
 	}
 }
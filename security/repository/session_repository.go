@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"gorm.io/gorm"
+	"time"
+)
+
+// sessionLimitLockClass namespaces the per-user advisory locks of LockUser, the user ID is the second key.
+const sessionLimitLockClass = 7_204_119
+
+type ISessionRepository interface {
+	LockUser(ctx context.Context, userID uint, fn func(repo ISessionRepository) error) error
+	Save(ctx context.Context, session *Session) error
+	FindByID(ctx context.Context, id string) (*Session, error)
+	FindActiveByUserID(ctx context.Context, userID uint, now time.Time) ([]*Session, error)
+	UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) error
+	Extend(ctx context.Context, id string, expiresAt time.Time) error
+	Revoke(ctx context.Context, id string, revokedAt time.Time) error
+}
+
+type SessionRepository struct {
+	Engine *gorm.DB
+}
+
+func NewSessionRepository(engine *gorm.DB) *SessionRepository {
+	return &SessionRepository{
+		Engine: engine,
+	}
+}
+
+// LockUser runs fn in a transaction holding a Postgres advisory lock on the user, so concurrent logins of
+// the user count and insert their sessions one at a time. fn gets a repository bound to the transaction.
+func (repo *SessionRepository) LockUser(ctx context.Context, userID uint, fn func(repo ISessionRepository) error) error {
+	return repo.Engine.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", sessionLimitLockClass, int32(userID)).Error; err != nil {
+			return err
+		}
+		return fn(NewSessionRepository(tx))
+	})
+}
+
+func (repo *SessionRepository) Save(ctx context.Context, session *Session) error {
+	return repo.Engine.WithContext(ctx).Save(session).Error
+}
+
+func (repo *SessionRepository) FindByID(ctx context.Context, id string) (*Session, error) {
+	var session Session
+	err := repo.Engine.WithContext(ctx).Where("id = ?", id).Take(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, security.SessionNotFound
+	}
+	return &session, err
+}
+
+func (repo *SessionRepository) FindActiveByUserID(ctx context.Context, userID uint, now time.Time) ([]*Session, error) {
+	var sessions []*Session
+	err := repo.Engine.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (repo *SessionRepository) UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) error {
+	return repo.Engine.WithContext(ctx).Model(&Session{}).Where("id = ?", id).Update("last_seen_at", lastSeenAt).Error
+}
+
+// Extend moves the expiration of the session, a revoked session is left as is and reported as SessionRevoked.
+func (repo *SessionRepository) Extend(ctx context.Context, id string, expiresAt time.Time) error {
+	result := repo.Engine.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("expires_at", expiresAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return security.SessionRevoked
+	}
+	return nil
+}
+
+func (repo *SessionRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	return repo.Engine.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt).Error
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type transactionKey struct{}
+
+// ContextWithTransaction carries tx so the repositories saving through the context join the transaction
+// of the caller instead of running their own statement.
+func ContextWithTransaction(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, transactionKey{}, tx)
+}
+
+// RunInTransaction runs fn with a context carrying a transaction of engine, committed when fn returns nil.
+func RunInTransaction(ctx context.Context, engine *gorm.DB, fn func(ctx context.Context) error) error {
+	return engine.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(ContextWithTransaction(ctx, tx))
+	})
+}
+
+// engineFromContext returns the transaction carried by the context, or engine outside of one.
+func engineFromContext(ctx context.Context, engine *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(transactionKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return engine.WithContext(ctx)
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type IUsedTokenRepository interface {
+	MarkUsed(ctx context.Context, token *UsedToken) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type UsedTokenRepository struct {
+	Engine *gorm.DB
+}
+
+func NewUsedTokenRepository(engine *gorm.DB) *UsedTokenRepository {
+	return &UsedTokenRepository{
+		Engine: engine,
+	}
+}
+
+// MarkUsed stores the token ID and reports whether it was stored, false means it was already used.
+func (repo *UsedTokenRepository) MarkUsed(ctx context.Context, token *UsedToken) (bool, error) {
+	result := repo.Engine.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token_id"}},
+		DoNothing: true,
+	}).Create(token)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repo *UsedTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := repo.Engine.WithContext(ctx).Where("expires_at < ?", now).Delete(&UsedToken{})
+	return result.RowsAffected, result.Error
+}
@@ -18,8 +18,14 @@ type IUserRepository interface {
 	FindPlatformByID(ctx context.Context, platformID uint) (*Platform, error)
 	FindAllRoles(ctx context.Context) ([]*UserRole, error)
 	FindRoleByName(ctx context.Context, name string) (*UserRole, error)
+	UpdateRoleSessionLimit(ctx context.Context, role *UserRole) error
 	UpdateUserPassword(ctx context.Context, user *User, password string) error
 	ActivateUser(ctx context.Context, user *User) error
+	UpdateUserLocale(ctx context.Context, user *User, locale string) error
+	FindByPhoneNumber(ctx context.Context, phoneNumber string) (*User, error)
+	UpdateUserPhoneNumber(ctx context.Context, user *User, phoneNumber *string) error
+	VerifyUserPhoneNumber(ctx context.Context, user *User) error
+	UpdateUserMfa(ctx context.Context, user *User, enabled bool, channel string) error
 }
 
 type UserRepository struct {
@@ -62,6 +68,10 @@ func (repo *UserRepository) FindRoleByName(ctx context.Context, name string) (*U
 	return &role, err
 }
 
+func (repo *UserRepository) UpdateRoleSessionLimit(ctx context.Context, role *UserRole) error {
+	return repo.Engine.WithContext(ctx).Model(role).Select("max_sessions", "session_limit_policy").Updates(role).Error
+}
+
 func (repo *UserRepository) createPreloadTx(ctx context.Context) *gorm.DB {
 	return repo.Engine.WithContext(ctx).Preload("Platform").Preload("Role")
 }
@@ -102,6 +112,34 @@ func (repo *UserRepository) UpdateUserPassword(ctx context.Context, user *User,
 	return repo.Engine.WithContext(ctx).Model(user).Update("password", password).Error
 }
 
+func (repo *UserRepository) UpdateUserLocale(ctx context.Context, user *User, locale string) error {
+	return repo.Engine.WithContext(ctx).Model(user).Update("locale", locale).Error
+}
+
+func (repo *UserRepository) FindByPhoneNumber(ctx context.Context, phoneNumber string) (*User, error) {
+	var user User
+	tx := repo.createPreloadTx(ctx).First(&user, "phone_number = ?", phoneNumber)
+	return &user, tx.Error
+}
+
+func (repo *UserRepository) UpdateUserPhoneNumber(ctx context.Context, user *User, phoneNumber *string) error {
+	return repo.Engine.WithContext(ctx).Model(user).Updates(map[string]any{
+		"phone_number":      phoneNumber,
+		"is_phone_verified": false,
+	}).Error
+}
+
+func (repo *UserRepository) VerifyUserPhoneNumber(ctx context.Context, user *User) error {
+	return repo.Engine.WithContext(ctx).Model(user).Update("is_phone_verified", true).Error
+}
+
+func (repo *UserRepository) UpdateUserMfa(ctx context.Context, user *User, enabled bool, channel string) error {
+	return repo.Engine.WithContext(ctx).Model(user).Updates(map[string]any{
+		"is_mfa_enabled": enabled,
+		"mfa_channel":    channel,
+	}).Error
+}
+
 func NewUserRepository(engine *gorm.DB) *UserRepository {
 	return &UserRepository{
 		Engine: engine,
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"go-security/security"
+	. "go-security/security/repository"
+	"time"
+)
+
+const (
+	AuditActionAccountActionRequested AuditAction = "account_action_requested"
+	AuditActionAccountActionCompleted AuditAction = "account_action_completed"
+)
+
+func IsAccountActionSupported(action AccountAction) bool {
+	switch action {
+	case AccountActionVerifyEmail, AccountActionResetPassword, AccountActionAcceptTerms, AccountActionEnrollMfa:
+		return true
+	}
+	return false
+}
+
+// AccountActionService tracks the actions users were asked to perform on their account. Requests
+// are persisted, so they survive restarts and are shared by every replica.
+type AccountActionService struct {
+	Repository   IAccountActionRequestRepository
+	UserService  *UserService
+	AuditService *AuditService
+}
+
+func NewAccountActionService(repository IAccountActionRequestRepository, userService *UserService, auditService *AuditService) *AccountActionService {
+	return &AccountActionService{
+		Repository:   repository,
+		UserService:  userService,
+		AuditService: auditService,
+	}
+}
+
+func (service *AccountActionService) PostConstruct() {}
+
+// RequestAction asks the user to perform the action, the requester is the authenticated user of the
+// request in the context. A required action restricts the logins of the user until it is completed.
+// An already pending request is returned as is, only being made required when asked to. A password reset
+// can only be requested from users signing in with a password.
+func (service *AccountActionService) RequestAction(ctx context.Context, userID uint, action AccountAction, dueAt *time.Time, required bool) (*AccountActionRequest, error) {
+	if !IsAccountActionSupported(action) {
+		return nil, security.AccountActionNotSupported
+	}
+	if action == AccountActionResetPassword {
+		user, err := service.UserService.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Platform.Name != string(PlatformSelf) {
+			return nil, security.SelfPlatformRequiredForPasswordReset
+		}
+	}
+	pending, err := service.Repository.FindPending(ctx, userID, action)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil && (pending.Required || !required) {
+		return pending, nil
+	}
+	request := pending
+	if request == nil {
+		request = &AccountActionRequest{
+			UserID:        userID,
+			Action:        action,
+			RequestedByID: RequestMetadataFromContext(ctx).ActorID,
+			DueAt:         dueAt,
+		}
+	}
+	request.Required = required
+	err = service.Repository.Save(ctx, request)
+	event := NewAuditEvent(AuditActionAccountActionRequested, err)
+	event.SubjectID = AuditUserID(userID)
+	event.Metadata["action"] = string(action)
+	event.Metadata["required"] = required
+	if dueAt != nil {
+		event.Metadata["due_at"] = dueAt.UTC().Format(time.RFC3339)
+	}
+	service.AuditService.Record(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (service *AccountActionService) GetPendingActions(ctx context.Context, userID uint) ([]*AccountActionRequest, error) {
+	return service.Repository.FindPendingByUserID(ctx, userID)
+}
+
+// GetRequiredActions returns the pending actions the user must complete before getting an unrestricted login.
+func (service *AccountActionService) GetRequiredActions(ctx context.Context, userID uint) ([]AccountAction, error) {
+	requests, err := service.Repository.FindPendingByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var actions []AccountAction
+	for _, request := range requests {
+		if request.Required {
+			actions = append(actions, request.Action)
+		}
+	}
+	return actions, nil
+}
+
+func (service *AccountActionService) HasPendingAction(ctx context.Context, userID uint, action AccountAction) (bool, error) {
+	pending, err := service.Repository.FindPending(ctx, userID, action)
+	if err != nil {
+		return false, err
+	}
+	return pending != nil, nil
+}
+
+// CompleteAction marks the pending request of the action as completed, it is a no-op when nothing was requested.
+func (service *AccountActionService) CompleteAction(ctx context.Context, userID uint, action AccountAction) error {
+	completed, err := service.Repository.CompletePending(ctx, userID, action, time.Now())
+	if err != nil {
+		return err
+	}
+	if completed > 0 {
+		event := NewAuditEvent(AuditActionAccountActionCompleted, nil)
+		event.SubjectID = AuditUserID(userID)
+		event.Metadata["action"] = string(action)
+		service.AuditService.Record(ctx, event)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"go-security/security/repository"
+	"slices"
+	"testing"
+	"time"
+)
+
+type memoryUserRepository struct {
+	repository.IUserRepository
+	users map[uint]*repository.User
+}
+
+func (repo *memoryUserRepository) FindByID(ctx context.Context, id uint) (*repository.User, error) {
+	user, ok := repo.users[id]
+	if !ok {
+		return nil, security.UserNotFound
+	}
+	return user, nil
+}
+
+func (repo *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*repository.User, error) {
+	for _, user := range repo.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, security.UserNotFound
+}
+
+func (repo *memoryUserRepository) UpdateUserMfa(ctx context.Context, user *repository.User, enabled bool, channel string) error {
+	repo.users[user.ID].IsMfaEnabled = enabled
+	repo.users[user.ID].MfaChannel = channel
+	return nil
+}
+
+type memoryAccountActionRequestRepository struct {
+	requests []*repository.AccountActionRequest
+}
+
+func (repo *memoryAccountActionRequestRepository) Save(ctx context.Context, request *repository.AccountActionRequest) error {
+	if request.ID == 0 {
+		request.ID = uint(len(repo.requests) + 1)
+		repo.requests = append(repo.requests, request)
+	}
+	return nil
+}
+
+func (repo *memoryAccountActionRequestRepository) FindPending(ctx context.Context, userID uint, action repository.AccountAction) (*repository.AccountActionRequest, error) {
+	for _, request := range repo.requests {
+		if request.UserID == userID && request.Action == action && request.CompletedAt == nil {
+			return request, nil
+		}
+	}
+	return nil, nil
+}
+
+func (repo *memoryAccountActionRequestRepository) FindPendingByUserID(ctx context.Context, userID uint) ([]*repository.AccountActionRequest, error) {
+	var pending []*repository.AccountActionRequest
+	for _, request := range repo.requests {
+		if request.UserID == userID && request.CompletedAt == nil {
+			pending = append(pending, request)
+		}
+	}
+	return pending, nil
+}
+
+func (repo *memoryAccountActionRequestRepository) CompletePending(ctx context.Context, userID uint, action repository.AccountAction, completedAt time.Time) (int64, error) {
+	var completed int64
+	for _, request := range repo.requests {
+		if request.UserID == userID && request.Action == action && request.CompletedAt == nil {
+			request.CompletedAt = &completedAt
+			completed++
+		}
+	}
+	return completed, nil
+}
+
+const (
+	testSelfUserID   uint = 1
+	testGoogleUserID uint = 2
+)
+
+func newTestAccountActionService() *AccountActionService {
+	users := &memoryUserRepository{users: map[uint]*repository.User{
+		testSelfUserID:   {ID: testSelfUserID, Name: "self", Platform: repository.Platform{Name: string(PlatformSelf)}, Role: repository.UserRole{Name: RoleGuest}},
+		testGoogleUserID: {ID: testGoogleUserID, Name: "google", Platform: repository.Platform{Name: string(PlatformGoogle)}, Role: repository.UserRole{Name: RoleGuest}},
+	}}
+	return NewAccountActionService(&memoryAccountActionRequestRepository{}, NewUserService(users), nil)
+}
+
+func TestRequestActionRejectsUnsupportedActions(t *testing.T) {
+	service := newTestAccountActionService()
+	for _, action := range []repository.AccountAction{MfaChallengeAction, "unknown"} {
+		if _, err := service.RequestAction(context.Background(), testSelfUserID, action, nil, true); !errors.Is(err, security.AccountActionNotSupported) {
+			t.Errorf("%s: got %v", action, err)
+		}
+	}
+}
+
+func TestRequestActionRejectsPasswordResetWithoutPassword(t *testing.T) {
+	service := newTestAccountActionService()
+	_, err := service.RequestAction(context.Background(), testGoogleUserID, repository.AccountActionResetPassword, nil, true)
+	if !errors.Is(err, security.SelfPlatformRequiredForPasswordReset) {
+		t.Fatalf("got %v", err)
+	}
+	if actions, _ := service.GetRequiredActions(context.Background(), testGoogleUserID); len(actions) > 0 {
+		t.Fatalf("no action must be stored, got %v", actions)
+	}
+	if _, err := service.RequestAction(context.Background(), testSelfUserID, repository.AccountActionResetPassword, nil, true); err != nil {
+		t.Fatalf("self user: %v", err)
+	}
+}
+
+func TestRequestActionUpgradesPendingRequestToRequired(t *testing.T) {
+	service := newTestAccountActionService()
+	ctx := context.Background()
+	optional, err := service.RequestAction(ctx, testSelfUserID, repository.AccountActionAcceptTerms, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions, _ := service.GetRequiredActions(ctx, testSelfUserID); len(actions) > 0 {
+		t.Fatalf("an optional action must not be required, got %v", actions)
+	}
+	required, err := service.RequestAction(ctx, testSelfUserID, repository.AccountActionAcceptTerms, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if required.ID != optional.ID || !required.Required {
+		t.Fatalf("expected the pending request to become required, got %+v", required)
+	}
+	again, err := service.RequestAction(ctx, testSelfUserID, repository.AccountActionAcceptTerms, nil, false)
+	if err != nil || !again.Required {
+		t.Fatalf("an optional request must not lift a required one: %+v %v", again, err)
+	}
+}
+
+func TestRequiredActionsRestrictLoginToken(t *testing.T) {
+	accountActionService := newTestAccountActionService()
+	authService := NewAuthService(accountActionService.UserService, nil, nil, accountActionService, nil, nil, nil, "test-secret")
+	ctx := context.Background()
+	user, _ := accountActionService.UserService.GetUserByID(ctx, testSelfUserID)
+	if _, err := accountActionService.RequestAction(ctx, user.ID, repository.AccountActionAcceptTerms, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := authService.IssueLoginToken(ctx, user, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := authService.ParseUserClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.IsRestricted() || !slices.Equal(claims.RequiredActions, []string{string(repository.AccountActionAcceptTerms)}) {
+		t.Fatalf("expected a token restricted to accept_terms, got %v", claims.RequiredActions)
+	}
+	if limit := float64(time.Now().Add(RestrictedLoginTokenExpiration).Unix()); claims.ExpirationDuration > limit {
+		t.Fatalf("a restricted token must expire within %v", RestrictedLoginTokenExpiration)
+	}
+
+	if err := accountActionService.CompleteAction(ctx, user.ID, repository.AccountActionAcceptTerms); err != nil {
+		t.Fatal(err)
+	}
+	token, err = authService.IssueLoginToken(ctx, user, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err = authService.ParseUserClaims(token); err != nil || claims.IsRestricted() {
+		t.Fatalf("expected an unrestricted token once completed, got %v %v", claims, err)
+	}
+}
+
+func TestAdminPushedActionIsConsumedOnce(t *testing.T) {
+	requests := &memoryAccountActionRequestRepository{}
+	users := newTestAccountActionService().UserService
+	auditService, auditRepository := newTestAuditService(t, 0)
+	adminID := uint(99)
+	adminCtx := ContextWithRequestMetadata(context.Background(), &RequestMetadata{ActorID: &adminID})
+	pushed, err := NewAccountActionService(requests, users, auditService).RequestAction(adminCtx, testSelfUserID, repository.AccountActionResetPassword, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed.RequestedByID == nil || *pushed.RequestedByID != adminID {
+		t.Fatalf("expected the admin to be recorded as the requester, got %v", pushed.RequestedByID)
+	}
+
+	// Another replica, or the service after a restart, reads the same persisted request.
+	replica := NewAccountActionService(requests, users, auditService)
+	ctx := context.Background()
+	if pending, _ := replica.HasPendingAction(ctx, testSelfUserID, repository.AccountActionResetPassword); !pending {
+		t.Fatal("the pushed action must survive a restart")
+	}
+	before := len(auditRepository.events)
+	for i := 0; i < 2; i++ {
+		if err := replica.CompleteAction(ctx, testSelfUserID, repository.AccountActionResetPassword); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if completed := len(auditRepository.events) - before; completed != 1 {
+		t.Fatalf("expected the action to be completed once, got %d completions", completed)
+	}
+	if pending, _ := replica.HasPendingAction(ctx, testSelfUserID, repository.AccountActionResetPassword); pending {
+		t.Fatal("the completed action must not be pending anymore")
+	}
+	if actions, _ := replica.GetRequiredActions(ctx, testSelfUserID); len(actions) > 0 {
+		t.Fatalf("the completed action must not restrict the logins anymore, got %v", actions)
+	}
+	if pushed.CompletedAt == nil {
+		t.Fatal("the persisted request must record its completion")
+	}
+}
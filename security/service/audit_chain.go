@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	. "go-security/security/repository"
+	"time"
+)
+
+const DefaultAuditCheckpointInterval = time.Hour
+
+type AuditConfig struct {
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+}
+
+func (config *AuditConfig) GetCheckpointInterval() time.Duration {
+	if config.CheckpointInterval <= 0 {
+		return DefaultAuditCheckpointInterval
+	}
+	return config.CheckpointInterval
+}
+
+type AuditChainBreakReason string
+
+const (
+	AuditChainPreviousHashMismatch     AuditChainBreakReason = "previous_hash_mismatch"
+	AuditChainHashMismatch             AuditChainBreakReason = "hash_mismatch"
+	AuditChainCheckpointMismatch       AuditChainBreakReason = "checkpoint_mismatch"
+	AuditChainCheckpointSignatureWrong AuditChainBreakReason = "checkpoint_signature_invalid"
+	AuditChainCheckpointedEventMissing AuditChainBreakReason = "checkpointed_event_missing"
+)
+
+type AuditChainBreak struct {
+	EventID  uint                  `json:"event_id"`
+	Reason   AuditChainBreakReason `json:"reason"`
+	Expected string                `json:"expected"`
+	Actual   string                `json:"actual"`
+}
+
+// AuditChainReport is the outcome of a chain verification. Events recorded before the chain existed
+// have no hash, they are counted as unsealed as long as they precede the first sealed event.
+type AuditChainReport struct {
+	Valid              bool             `json:"valid"`
+	EventsChecked      int64            `json:"events_checked"`
+	UnsealedEvents     int64            `json:"unsealed_events"`
+	CheckpointsChecked int              `json:"checkpoints_checked"`
+	LastEventID        uint             `json:"last_event_id"`
+	FirstBreak         *AuditChainBreak `json:"first_break"`
+	VerifiedAt         time.Time        `json:"verified_at"`
+}
+
+var errAuditChainBroken = errors.New("audit chain broken")
+
+// auditEventContent is the hashed part of an event, the id is left out as it is only known after the insert.
+type auditEventContent struct {
+	PreviousHash string         `json:"previous_hash"`
+	ActorID      *uint          `json:"actor_id"`
+	SubjectID    *uint          `json:"subject_id"`
+	Action       string         `json:"action"`
+	IPAddress    string         `json:"ip_address"`
+	UserAgent    string         `json:"user_agent"`
+	Outcome      AuditOutcome   `json:"outcome"`
+	Metadata     map[string]any `json:"metadata"`
+	CreatedAt    string         `json:"created_at"`
+}
+
+// HashAuditEvent computes the hash of the event, maps are marshalled with sorted keys so the
+// hash is stable across a round trip through the database.
+func HashAuditEvent(event *AuditEvent) (string, error) {
+	content, err := json.Marshal(&auditEventContent{
+		PreviousHash: event.PreviousHash,
+		ActorID:      event.ActorID,
+		SubjectID:    event.SubjectID,
+		Action:       event.Action,
+		IPAddress:    event.IPAddress,
+		UserAgent:    event.UserAgent,
+		Outcome:      event.Outcome,
+		Metadata:     event.Metadata,
+		CreatedAt:    event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// normalizeAuditMetadata converts the metadata to the shape it has once read back from the database,
+// e.g. structs become maps and numbers become float64.
+func normalizeAuditMetadata(metadata map[string]any) (map[string]any, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func sealAuditEvent(event *AuditEvent) func(previous *AuditEvent) error {
+	return func(previous *AuditEvent) error {
+		event.PreviousHash = ""
+		if previous != nil {
+			event.PreviousHash = previous.Hash
+		}
+		// Postgres keeps microseconds, the hash must cover the timestamp as it is stored.
+		event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		metadata, err := normalizeAuditMetadata(event.Metadata)
+		if err != nil {
+			return err
+		}
+		event.Metadata = metadata
+		event.Hash, err = HashAuditEvent(event)
+		return err
+	}
+}
+
+func (service *AuditService) signCheckpoint(eventID uint, eventHash string) string {
+	mac := hmac.New(sha256.New, []byte(service.SigningKey))
+	mac.Write([]byte(fmt.Sprintf("audit-checkpoint:%d:%s", eventID, eventHash)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Checkpoint signs the hash of the last event, it is a no-op when the last event is already checkpointed.
+func (service *AuditService) Checkpoint(ctx context.Context) (*AuditCheckpoint, error) {
+	last, err := service.Repository.FindLast(ctx)
+	if err != nil || last == nil || len(last.Hash) == 0 {
+		return nil, err
+	}
+	latest, err := service.Repository.FindLatestCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.EventID >= last.ID {
+		return latest, nil
+	}
+	checkpoint := &AuditCheckpoint{
+		EventID:   last.ID,
+		EventHash: last.Hash,
+		Signature: service.signCheckpoint(last.ID, last.Hash),
+	}
+	if err := service.Repository.SaveCheckpoint(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// Start takes a checkpoint every CheckpointInterval until Stop is called.
+func (service *AuditService) Start(ctx context.Context) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	if service.cancel != nil {
+		return
+	}
+	ctx, service.cancel = context.WithCancel(ctx)
+	service.waitGroup.Add(1)
+	go func() {
+		defer service.waitGroup.Done()
+		ticker := time.NewTicker(service.Config.GetCheckpointInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := service.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to checkpoint audit chain")
+				}
+			}
+		}
+	}()
+}
+
+func (service *AuditService) Stop() {
+	service.lock.Lock()
+	cancel := service.cancel
+	service.cancel = nil
+	service.lock.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	service.waitGroup.Wait()
+}
+
+// VerifyChain walks the events in order, checking each link, each hash and each checkpoint, and
+// reports the first break. Deleting the most recent events is only detected up to the last checkpoint.
+func (service *AuditService) VerifyChain(ctx context.Context) (*AuditChainReport, error) {
+	report := &AuditChainReport{VerifiedAt: time.Now()}
+	checkpoints, err := service.Repository.FindCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pendingCheckpoints := make(map[uint]*AuditCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		pendingCheckpoints[checkpoint.EventID] = checkpoint
+	}
+
+	previousHash := ""
+	sealed := false
+	fail := func(eventID uint, reason AuditChainBreakReason, expected string, actual string) error {
+		report.FirstBreak = &AuditChainBreak{EventID: eventID, Reason: reason, Expected: expected, Actual: actual}
+		return errAuditChainBroken
+	}
+	err = service.Repository.FindInBatches(ctx, nil, auditExportBatchSize, func(events []*AuditEvent) error {
+		for _, event := range events {
+			if !sealed && len(event.Hash) == 0 && len(event.PreviousHash) == 0 {
+				report.UnsealedEvents++
+				continue
+			}
+			sealed = true
+			if event.PreviousHash != previousHash {
+				return fail(event.ID, AuditChainPreviousHashMismatch, previousHash, event.PreviousHash)
+			}
+			hash, err := HashAuditEvent(event)
+			if err != nil {
+				return err
+			}
+			if hash != event.Hash {
+				return fail(event.ID, AuditChainHashMismatch, hash, event.Hash)
+			}
+			if checkpoint, ok := pendingCheckpoints[event.ID]; ok {
+				signature := service.signCheckpoint(checkpoint.EventID, checkpoint.EventHash)
+				if !hmac.Equal([]byte(signature), []byte(checkpoint.Signature)) {
+					return fail(event.ID, AuditChainCheckpointSignatureWrong, signature, checkpoint.Signature)
+				}
+				if checkpoint.EventHash != event.Hash {
+					return fail(event.ID, AuditChainCheckpointMismatch, checkpoint.EventHash, event.Hash)
+				}
+				delete(pendingCheckpoints, event.ID)
+				report.CheckpointsChecked++
+			}
+			previousHash = event.Hash
+			report.LastEventID = event.ID
+			report.EventsChecked++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errAuditChainBroken) {
+		return nil, err
+	}
+	if report.FirstBreak == nil {
+		for _, checkpoint := range checkpoints {
+			if _, missing := pendingCheckpoints[checkpoint.EventID]; missing {
+				fail(checkpoint.EventID, AuditChainCheckpointedEventMissing, checkpoint.EventHash, "")
+				break
+			}
+		}
+	}
+	report.Valid = report.FirstBreak == nil
+	return report, nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-security/security/repository"
+	"strings"
+	"testing"
+)
+
+// memoryAuditEventRepository keeps the events as they would be read back from the database.
+type memoryAuditEventRepository struct {
+	events      []*repository.AuditEvent
+	checkpoints []*repository.AuditCheckpoint
+}
+
+func (repo *memoryAuditEventRepository) last() *repository.AuditEvent {
+	if len(repo.events) == 0 {
+		return nil
+	}
+	return repo.events[len(repo.events)-1]
+}
+
+func (repo *memoryAuditEventRepository) Append(ctx context.Context, event *repository.AuditEvent, seal func(previous *repository.AuditEvent) error) error {
+	if err := seal(repo.last()); err != nil {
+		return err
+	}
+	event.ID = uint(len(repo.events) + 1)
+	if previous := repo.last(); previous != nil {
+		event.ID = previous.ID + 1
+	}
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var stored repository.AuditEvent
+	if err := json.Unmarshal(content, &stored); err != nil {
+		return err
+	}
+	repo.events = append(repo.events, &stored)
+	return nil
+}
+
+func (repo *memoryAuditEventRepository) FindLast(ctx context.Context) (*repository.AuditEvent, error) {
+	return repo.last(), nil
+}
+
+// FindAll applies the subject and action filters, most recent first like the database.
+func (repo *memoryAuditEventRepository) FindAll(ctx context.Context, filter *repository.AuditEventFilter, limit int, offset int) ([]*repository.AuditEvent, int64, error) {
+	var events []*repository.AuditEvent
+	for i := len(repo.events) - 1; i >= 0; i-- {
+		event := repo.events[i]
+		if filter != nil && filter.SubjectID != nil && (event.SubjectID == nil || *event.SubjectID != *filter.SubjectID) {
+			continue
+		}
+		if filter != nil && len(filter.Action) > 0 && event.Action != filter.Action {
+			continue
+		}
+		events = append(events, event)
+	}
+	total := int64(len(events))
+	events = events[min(offset, len(events)):]
+	return events[:min(limit, len(events))], total, nil
+}
+
+func (repo *memoryAuditEventRepository) FindInBatches(ctx context.Context, filter *repository.AuditEventFilter, batchSize int, handle func(events []*repository.AuditEvent) error) error {
+	for start := 0; start < len(repo.events); start += batchSize {
+		end := min(start+batchSize, len(repo.events))
+		if err := handle(repo.events[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *memoryAuditEventRepository) SaveCheckpoint(ctx context.Context, checkpoint *repository.AuditCheckpoint) error {
+	repo.checkpoints = append(repo.checkpoints, checkpoint)
+	return nil
+}
+
+func (repo *memoryAuditEventRepository) FindLatestCheckpoint(ctx context.Context) (*repository.AuditCheckpoint, error) {
+	if len(repo.checkpoints) == 0 {
+		return nil, nil
+	}
+	return repo.checkpoints[len(repo.checkpoints)-1], nil
+}
+
+func (repo *memoryAuditEventRepository) FindCheckpoints(ctx context.Context) ([]*repository.AuditCheckpoint, error) {
+	return repo.checkpoints, nil
+}
+
+func newTestAuditService(t *testing.T, events int) (*AuditService, *memoryAuditEventRepository) {
+	t.Helper()
+	repo := &memoryAuditEventRepository{}
+	service := NewAuditService(nil, repo, "audit-test-key")
+	for i := 0; i < events; i++ {
+		event := NewAuditEvent(AuditActionLogin, nil)
+		event.SubjectID = AuditUserID(uint(i + 1))
+		// Structs and ints only survive the database as maps and float64, the hash must still match.
+		event.Metadata["attempt"] = i
+		event.Metadata["filter"] = struct {
+			Action string `json:"action"`
+		}{Action: "login"}
+		service.Record(context.Background(), event)
+	}
+	return service, repo
+}
+
+func verifyTestChain(t *testing.T, service *AuditService) *AuditChainReport {
+	t.Helper()
+	report, err := service.VerifyChain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func expectChainBreak(t *testing.T, report *AuditChainReport, eventID uint, reason AuditChainBreakReason) {
+	t.Helper()
+	if report.Valid || report.FirstBreak == nil {
+		t.Fatalf("expected the chain to break at %d with %s, it is valid", eventID, reason)
+	}
+	if report.FirstBreak.EventID != eventID || report.FirstBreak.Reason != reason {
+		t.Fatalf("expected a break at %d with %s, got %d with %s", eventID, reason, report.FirstBreak.EventID, report.FirstBreak.Reason)
+	}
+}
+
+func TestAuditChainVerifies(t *testing.T) {
+	service, repo := newTestAuditService(t, 5)
+	if _, err := service.Checkpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	report := verifyTestChain(t, service)
+	if !report.Valid || report.EventsChecked != 5 || report.CheckpointsChecked != 1 || report.LastEventID != 5 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if repo.events[0].PreviousHash != "" || repo.events[1].PreviousHash != repo.events[0].Hash {
+		t.Fatal("events are not linked")
+	}
+}
+
+func TestAuditChainDetectsEditedEvent(t *testing.T) {
+	service, repo := newTestAuditService(t, 5)
+	repo.events[2].IPAddress = "203.0.113.9"
+	expectChainBreak(t, verifyTestChain(t, service), 3, AuditChainHashMismatch)
+}
+
+func TestAuditChainDetectsDeletedEvent(t *testing.T) {
+	service, repo := newTestAuditService(t, 5)
+	repo.events = append(repo.events[:2], repo.events[3:]...)
+	expectChainBreak(t, verifyTestChain(t, service), 4, AuditChainPreviousHashMismatch)
+}
+
+func TestAuditChainDetectsTruncationUpToCheckpoint(t *testing.T) {
+	service, repo := newTestAuditService(t, 5)
+	if _, err := service.Checkpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	repo.events = repo.events[:4]
+	expectChainBreak(t, verifyTestChain(t, service), 5, AuditChainCheckpointedEventMissing)
+}
+
+func TestAuditChainDetectsForgedCheckpoint(t *testing.T) {
+	service, repo := newTestAuditService(t, 3)
+	forger := NewAuditService(nil, repo, "another-key")
+	if _, err := forger.Checkpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectChainBreak(t, verifyTestChain(t, service), 3, AuditChainCheckpointSignatureWrong)
+}
+
+func TestAuditChainCountsUnsealedLegacyEvents(t *testing.T) {
+	repo := &memoryAuditEventRepository{events: []*repository.AuditEvent{
+		{ID: 1, Action: "login", Outcome: repository.AuditOutcomeSuccess},
+		{ID: 2, Action: "login", Outcome: repository.AuditOutcomeSuccess},
+	}}
+	service := NewAuditService(nil, repo, "audit-test-key")
+	service.Record(context.Background(), NewAuditEvent(AuditActionLogin, nil))
+	report := verifyTestChain(t, service)
+	if !report.Valid || report.UnsealedEvents != 2 || report.EventsChecked != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if repo.events[2].PreviousHash != "" {
+		t.Fatal("the first sealed event must start the chain")
+	}
+}
+
+func TestAuditChainCheckpointIsIdempotent(t *testing.T) {
+	service, repo := newTestAuditService(t, 2)
+	first, err := service.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || len(repo.checkpoints) != 1 {
+		t.Fatalf("expected a single checkpoint, got %d", len(repo.checkpoints))
+	}
+}
+
+func TestAuditExportCsv(t *testing.T) {
+	service, repo := newTestAuditService(t, 2)
+	var output bytes.Buffer
+	if err := service.Export(context.Background(), nil, AuditExportFormatCsv, &output); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(auditCsvHeader, ",") {
+		t.Fatalf("unexpected export %q", output.String())
+	}
+	if exported := repo.last(); exported.Action != string(AuditActionAuditExported) || exported.Outcome != repository.AuditOutcomeSuccess {
+		t.Fatalf("expected the export to be audited, got %s", exported.Action)
+	}
+	if report := verifyTestChain(t, service); !report.Valid {
+		t.Fatalf("the export event must extend the chain: %+v", report.FirstBreak)
+	}
+}
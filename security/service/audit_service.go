@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go-security/security"
+	. "go-security/security/repository"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionRegister                AuditAction = "register"
+	AuditActionLogin                   AuditAction = "login"
+	AuditActionMagicLinkRequested      AuditAction = "magic_link_requested"
+	AuditActionPasswordResetRequested  AuditAction = "password_reset_requested"
+	AuditActionPasswordReset           AuditAction = "password_reset"
+	AuditActionEmailVerificationSent   AuditAction = "email_verification_sent"
+	AuditActionEmailVerificationPushed AuditAction = "email_verification_pushed"
+	AuditActionEmailVerified           AuditAction = "email_verified"
+	AuditActionEmailOutboxRetried      AuditAction = "email_outbox_retried"
+	AuditActionAuditExported           AuditAction = "audit_exported"
+)
+
+type AuditExportFormat string
+
+const (
+	AuditExportFormatNdjson AuditExportFormat = "ndjson"
+	AuditExportFormatCsv    AuditExportFormat = "csv"
+)
+
+const auditExportBatchSize = 500
+
+// NewAuditEvent starts an event, failures carry the error in their metadata.
+func NewAuditEvent(action AuditAction, err error) *AuditEvent {
+	event := &AuditEvent{
+		Action:   string(action),
+		Outcome:  AuditOutcomeSuccess,
+		Metadata: make(map[string]any),
+	}
+	if err != nil {
+		event.Outcome = AuditOutcomeFailure
+		event.Metadata["error"] = err.Error()
+	}
+	return event
+}
+
+func AuditUserID(id uint) *uint {
+	return &id
+}
+
+// AuditService records security-relevant events into a hash chain. Recording never fails the audited
+// operation, a failed write is logged instead.
+type AuditService struct {
+	Config     *AuditConfig
+	Repository IAuditEventRepository
+	SigningKey string
+	cancel     context.CancelFunc
+	waitGroup  sync.WaitGroup
+	lock       sync.Mutex
+}
+
+func NewAuditService(config *AuditConfig, repository IAuditEventRepository, signingKey string) *AuditService {
+	if config == nil {
+		config = &AuditConfig{}
+	}
+	return &AuditService{
+		Config:     config,
+		Repository: repository,
+		SigningKey: signingKey,
+	}
+}
+
+func (service *AuditService) PostConstruct() {
+	service.Start(context.Background())
+}
+
+// PreDestroy stops the checkpoint ticker and checkpoints the events recorded since the last one.
+func (service *AuditService) PreDestroy(ctx context.Context) error {
+	if err := waitUntilDone(ctx, service.Stop); err != nil {
+		return err
+	}
+	_, err := service.Checkpoint(ctx)
+	return err
+}
+
+// Record stores the event, the client and the actor default to the ones of the request in the context.
+func (service *AuditService) Record(ctx context.Context, event *AuditEvent) {
+	if service == nil {
+		return
+	}
+	metadata := RequestMetadataFromContext(ctx)
+	if len(event.IPAddress) == 0 {
+		event.IPAddress = metadata.IPAddress
+	}
+	if len(event.UserAgent) == 0 {
+		event.UserAgent = metadata.UserAgent
+	}
+	if event.ActorID == nil {
+		event.ActorID = metadata.ActorID
+	}
+	if requestID := RequestIDFromContext(ctx); len(requestID) > 0 {
+		if _, ok := event.Metadata["request_id"]; !ok {
+			event.Metadata["request_id"] = requestID
+		}
+	}
+	if event.ActorID == nil && event.Outcome == AuditOutcomeSuccess {
+		event.ActorID = event.SubjectID
+	}
+	if err := service.Repository.Append(context.WithoutCancel(ctx), event, sealAuditEvent(event)); err != nil {
+		LoggerFromContext(ctx).Error().Err(err).Msgf("Failed to record audit event %s", event.Action)
+	}
+}
+
+// RecordUserAction records an action the subject performed or that was performed on them.
+func (service *AuditService) RecordUserAction(ctx context.Context, action AuditAction, subjectID uint, err error) {
+	event := NewAuditEvent(action, err)
+	event.SubjectID = AuditUserID(subjectID)
+	service.Record(ctx, event)
+}
+
+func (service *AuditService) GetEvents(ctx context.Context, filter *AuditEventFilter, page int, pageSize int) (*Page[*AuditEvent], error) {
+	page, pageSize, limit, offset := NormalizePagination(page, pageSize)
+	events, total, err := service.Repository.FindAll(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*AuditEvent]{Items: events, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// Export streams the matching events to the writer, one JSON object per line or as CSV with a header row.
+func (service *AuditService) Export(ctx context.Context, filter *AuditEventFilter, format AuditExportFormat, writer io.Writer) error {
+	var write func(events []*AuditEvent) error
+	var flush func() error
+	switch format {
+	case AuditExportFormatNdjson, "":
+		encoder := json.NewEncoder(writer)
+		write = func(events []*AuditEvent) error {
+			for _, event := range events {
+				if err := encoder.Encode(event); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		flush = func() error { return nil }
+	case AuditExportFormatCsv:
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.Write(auditCsvHeader); err != nil {
+			return err
+		}
+		write = func(events []*AuditEvent) error {
+			for _, event := range events {
+				if err := csvWriter.Write(auditCsvRecord(event)); err != nil {
+					return err
+				}
+			}
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	default:
+		return security.AuditExportFormatNotSupported
+	}
+
+	exportEvent := NewAuditEvent(AuditActionAuditExported, nil)
+	exportEvent.Metadata["format"] = string(format)
+	exportEvent.Metadata["filter"] = filter
+	err := service.Repository.FindInBatches(ctx, filter, auditExportBatchSize, write)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		exportEvent.Outcome = AuditOutcomeFailure
+		exportEvent.Metadata["error"] = err.Error()
+	}
+	service.Record(ctx, exportEvent)
+	return err
+}
+
+var auditCsvHeader = []string{"id", "created_at", "action", "outcome", "actor_id", "subject_id", "ip_address", "user_agent", "metadata"}
+
+// csvSafeCell prefixes the cells a spreadsheet would evaluate as a formula, such as a user agent
+// starting with "=", with a quote so they are displayed as text.
+func csvSafeCell(cell string) string {
+	if len(cell) > 0 && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func auditCsvRecord(event *AuditEvent) []string {
+	formatID := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		metadata = []byte(fmt.Sprintf("%q", err.Error()))
+	}
+	record := []string{
+		strconv.FormatUint(uint64(event.ID), 10),
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.Action,
+		string(event.Outcome),
+		formatID(event.ActorID),
+		formatID(event.SubjectID),
+		event.IPAddress,
+		event.UserAgent,
+		string(metadata),
+	}
+	for i, cell := range record {
+		record[i] = csvSafeCell(cell)
+	}
+	return record
+}
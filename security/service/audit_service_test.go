@@ -0,0 +1,29 @@
+package service
+
+import (
+	"go-security/security/repository"
+	"testing"
+)
+
+func TestAuditCsvRecordEscapesFormulas(t *testing.T) {
+	record := auditCsvRecord(&repository.AuditEvent{
+		Action:    "login",
+		IPAddress: "@SUM(1+1)",
+		UserAgent: "=HYPERLINK(\"http://evil.example\")",
+		Metadata:  map[string]any{},
+	})
+	if record[6] != "'@SUM(1+1)" {
+		t.Errorf("ip address not escaped: %q", record[6])
+	}
+	if record[7] != "'=HYPERLINK(\"http://evil.example\")" {
+		t.Errorf("user agent not escaped: %q", record[7])
+	}
+	if record[2] != "login" || record[8] != "{}" {
+		t.Errorf("plain cells must be unchanged: %q %q", record[2], record[8])
+	}
+	for _, cell := range []string{"+1", "-1", "\tx", "\rx"} {
+		if escaped := csvSafeCell(cell); escaped != "'"+cell {
+			t.Errorf("%q not escaped: %q", cell, escaped)
+		}
+	}
+}
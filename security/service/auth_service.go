@@ -4,9 +4,10 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/rs/zerolog/log"
 	"go-security/security"
+	"go-security/security/metrics"
 	. "go-security/security/repository"
+	"go-security/security/tracing"
 	"golang.org/x/crypto/bcrypt"
 	"slices"
 	"time"
@@ -14,13 +15,23 @@ import (
 
 const (
 	CookieName = "jwt"
+	// RestrictedLoginTokenExpiration caps the lifetime of login tokens carrying required actions.
+	RestrictedLoginTokenExpiration = 15 * time.Minute
+	// MfaChallengeExpiration bounds the time to enter the sign-in code, matching the lifetime of the code.
+	MfaChallengeExpiration = 5 * time.Minute
 )
 
 type SecurityConfig struct {
-	Secret                string   `yaml:"secret" json:"-"`
-	ExcludedRoutePrefixes []string `yaml:"excluded_routes_prefixes"`
-	AdminRedirectUrl      string   `yaml:"admin_redirect_url"`
-	ClientRedirectUrl     string   `yaml:"client_redirect_url"`
+	Secret                string            `yaml:"secret" json:"-" secret:"true"`
+	ExcludedRoutePrefixes []string          `yaml:"excluded_routes_prefixes"`
+	AdminRedirectUrl      string            `yaml:"admin_redirect_url"`
+	ClientRedirectUrl     string            `yaml:"client_redirect_url"`
+	TokenExtractors       []string          `yaml:"token_extractors"`
+	TokenQueryParameter   string            `yaml:"token_query_parameter"`
+	Cookie                *CookieConfig     `yaml:"cookie"`
+	Csrf                  *CsrfConfig       `yaml:"csrf"`
+	MagicLink             *MagicLinkConfig  `yaml:"magic_link"`
+	LoginAlert            *LoginAlertConfig `yaml:"login_alert"`
 }
 
 type UserClaims struct {
@@ -30,6 +41,17 @@ type UserClaims struct {
 	RoleIndex          uint    `json:"role_index"`
 	ExpirationDuration float64 `json:"exp"`
 	IsVerified         bool    `json:"is_verified"`
+	// RequiredActions restrict the token to the endpoints completing them, see AuthMiddleware.
+	RequiredActions []string `json:"required_actions,omitempty"`
+	SessionID       string   `json:"session_id,omitempty"`
+	// ImpersonatorID is the admin acting as the user, set on impersonation tokens only.
+	ImpersonatorID *uint `json:"impersonator_id,omitempty"`
+	// ImpersonatorSessionID is the session the admin started the impersonation from, resumed once it stops.
+	ImpersonatorSessionID string `json:"-"`
+}
+
+func (claims *UserClaims) IsImpersonated() bool {
+	return claims.ImpersonatorID != nil
 }
 
 func NewUserClaims(userID uint, userName string, roleName string, roleIndex uint, expiration float64, isVerified bool) *UserClaims {
@@ -43,6 +65,15 @@ func NewUserClaims(userID uint, userName string, roleName string, roleIndex uint
 	}
 }
 
+func (claims *UserClaims) IsRestricted() bool {
+	return len(claims.RequiredActions) > 0
+}
+
+// IsMfaChallenge tells whether the token was issued before the sign-in code of the user was verified.
+func (claims *UserClaims) IsMfaChallenge() bool {
+	return slices.Contains(claims.RequiredActions, string(MfaChallengeAction))
+}
+
 func (claims *UserClaims) Validate() error {
 	if claims.ExpirationDuration < float64(time.Now().Unix()) {
 		return security.TokenExpired
@@ -51,14 +82,26 @@ func (claims *UserClaims) Validate() error {
 }
 
 type AuthService struct {
-	Secret      string
-	UserService *UserService
+	Secret               string
+	UserService          *UserService
+	NotificationService  *NotificationService
+	AuditService         *AuditService
+	AccountActionService *AccountActionService
+	SessionService       *SessionService
+	UsedTokenService     *UsedTokenService
+	LoginAlertConfig     *LoginAlertConfig
 }
 
-func NewAuthService(userService *UserService, secret string) *AuthService {
+func NewAuthService(userService *UserService, notificationService *NotificationService, auditService *AuditService, accountActionService *AccountActionService, sessionService *SessionService, usedTokenService *UsedTokenService, loginAlertConfig *LoginAlertConfig, secret string) *AuthService {
 	authService := &AuthService{
-		Secret:      secret,
-		UserService: userService,
+		Secret:               secret,
+		UserService:          userService,
+		NotificationService:  notificationService,
+		AuditService:         auditService,
+		AccountActionService: accountActionService,
+		SessionService:       sessionService,
+		UsedTokenService:     usedTokenService,
+		LoginAlertConfig:     loginAlertConfig,
 	}
 
 	return authService
@@ -69,21 +112,55 @@ func (service *AuthService) PostConstruct() {
 }
 
 func (service *AuthService) Login(ctx context.Context, email string, password string) (string, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	token, err := service.login(ctx, email, password)
+	tracing.End(span, err)
+	return token, err
+}
+
+func (service *AuthService) login(ctx context.Context, email string, password string) (string, error) {
 	user, err := service.UserService.GetUserByEmail(ctx, email)
 	if err != nil {
+		event := NewAuditEvent(AuditActionLogin, security.UserNotFound)
+		event.Metadata["email"] = email
+		event.Metadata["method"] = "password"
+		service.AuditService.Record(ctx, event)
+		metrics.LoginAttempts.WithLabelValues("password", metrics.OutcomeFailure).Inc()
 		return "", security.UserNotFound
 	}
 	err = service.VerifyPassword(password, user.Password)
 	if err != nil {
+		service.RecordLogin(ctx, user.ID, "password", security.UserPasswordNotMatched)
 		return "", security.UserPasswordNotMatched
 	}
-	return service.IssueLoginToken(user, time.Hour)
+	token, err := service.IssueLoginToken(ctx, user, time.Hour)
+	service.RecordLogin(ctx, user.ID, "password", err)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RecordLogin audits a login attempt of the user, the method tells how they authenticated.
+func (service *AuthService) RecordLogin(ctx context.Context, userID uint, method string, err error) {
+	event := NewAuditEvent(AuditActionLogin, err)
+	event.SubjectID = AuditUserID(userID)
+	event.Metadata["method"] = method
+	service.AuditService.Record(ctx, event)
+	metrics.LoginAttempts.WithLabelValues(method, metrics.Outcome(err)).Inc()
+}
+
+// NotifySecurityEvent notifies the user of an event on their account, the origin of the request is read from the context.
+func (service *AuthService) NotifySecurityEvent(ctx context.Context, userID uint, event SecurityEvent) {
+	if service.NotificationService == nil {
+		return
+	}
+	service.NotificationService.NotifyUser(ctx, userID, NewSecurityNotification(event, RequestMetadataFromContext(ctx)))
 }
 
 func (service *AuthService) IssueJsonWebToken(claims *jwt.MapClaims) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, _ := token.SignedString([]byte(service.Secret))
-	log.Info().Msgf("Issue Token: %v", tokenString)
 	return tokenString
 }
 
@@ -95,8 +172,93 @@ func (service *AuthService) IsUserAdmin(userRoleName string) bool {
 	return false
 }
 
-func (service *AuthService) IssueLoginToken(user *User, expiration time.Duration) (string, error) {
+// IssueLoginToken issues the login token of a user who proved their first factor. A user with MFA enabled
+// gets an MFA challenge token instead, see IssueSessionToken.
+func (service *AuthService) IssueLoginToken(ctx context.Context, user *User, expiration time.Duration) (string, error) {
+	if user.IsMfaEnabled {
+		return service.issueMfaChallengeToken(user), nil
+	}
+	return service.IssueSessionToken(ctx, user, expiration)
+}
+
+// IssueSessionToken starts a new session of the user and issues its login token. While the user has
+// required actions pending, the token is restricted to them and expires after RestrictedLoginTokenExpiration at the latest.
+func (service *AuthService) IssueSessionToken(ctx context.Context, user *User, expiration time.Duration) (string, error) {
+	requiredActions, expiration, err := service.loginTokenRestrictions(ctx, user, expiration)
+	if err != nil {
+		return "", err
+	}
+	sessionID := ""
+	if service.SessionService != nil {
+		session, err := service.SessionService.CreateSession(ctx, user, expiration)
+		if err != nil {
+			return "", err
+		}
+		sessionID = session.ID
+		service.alertNewDevice(ctx, user, session)
+	}
+	return service.issueLoginToken(user, sessionID, requiredActions, expiration), nil
+}
+
+// issueMfaChallengeToken restricts the token to entering the sign-in code. It starts no session, so a
+// leaked password alone can neither evict the sessions of the user nor trigger a new device alert.
+func (service *AuthService) issueMfaChallengeToken(user *User) string {
+	return service.issueLoginToken(user, "", []AccountAction{MfaChallengeAction}, MfaChallengeExpiration)
+}
+
+// RefreshLoginToken reissues the login token of the session in the claims, reflecting the current
+// state of the user, e.g. lifting the restriction once the required actions are done.
+func (service *AuthService) RefreshLoginToken(ctx context.Context, claims *UserClaims, expiration time.Duration) (string, error) {
+	if claims.IsImpersonated() {
+		return "", security.ImpersonationActionForbidden
+	}
+	if claims.IsMfaChallenge() {
+		return "", security.MfaChallengePending
+	}
+	user, err := service.UserService.GetUserByID(ctx, claims.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(claims.SessionID) == 0 || service.SessionService == nil {
+		return service.IssueSessionToken(ctx, user, expiration)
+	}
+	session, err := service.SessionService.ValidateSession(ctx, claims.SessionID)
+	if err != nil {
+		return "", err
+	}
+	requiredActions, expiration, err := service.loginTokenRestrictions(ctx, user, expiration)
+	if err != nil {
+		return "", err
+	}
+	if err := service.SessionService.ExtendSession(ctx, session, expiration); err != nil {
+		return "", err
+	}
+	return service.issueLoginToken(user, session.ID, requiredActions, expiration), nil
+}
+
+func (service *AuthService) loginTokenRestrictions(ctx context.Context, user *User, expiration time.Duration) ([]AccountAction, time.Duration, error) {
+	if service.AccountActionService == nil {
+		return nil, expiration, nil
+	}
+	requiredActions, err := service.AccountActionService.GetRequiredActions(ctx, user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(requiredActions) > 0 {
+		expiration = min(expiration, RestrictedLoginTokenExpiration)
+	}
+	return requiredActions, expiration, nil
+}
+
+func (service *AuthService) issueLoginToken(user *User, sessionID string, requiredActions []AccountAction, expiration time.Duration) string {
+	claims := loginClaims(user, sessionID, expiration)
+	if len(requiredActions) > 0 {
+		claims["required_actions"] = requiredActions
+	}
+	return service.IssueJsonWebToken(&claims)
+}
 
+func loginClaims(user *User, sessionID string, expiration time.Duration) jwt.MapClaims {
 	claims := jwt.MapClaims{
 		"user_name":   user.Name,
 		"id":          user.ID,
@@ -105,41 +267,80 @@ func (service *AuthService) IssueLoginToken(user *User, expiration time.Duration
 		"exp":         time.Now().Add(expiration).Unix(),
 		"is_verified": user.IsVerified,
 	}
-	return service.IssueJsonWebToken(&claims), nil
+	if len(sessionID) > 0 {
+		claims["sid"] = sessionID
+	}
+	return claims
+}
+
+// AuthenticateToken parses the login token and checks that its session was neither revoked nor expired.
+// Tokens issued before sessions existed carry no session and are accepted until they expire.
+func (service *AuthService) AuthenticateToken(ctx context.Context, token string) (*UserClaims, error) {
+	claims, err := service.ParseUserClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(claims.SessionID) > 0 && service.SessionService != nil {
+		if _, err := service.SessionService.ValidateSession(ctx, claims.SessionID); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// Logout revokes the session of the claims.
+func (service *AuthService) Logout(ctx context.Context, claims *UserClaims) error {
+	if len(claims.SessionID) == 0 || service.SessionService == nil {
+		return nil
+	}
+	return service.SessionService.RevokeUserSession(ctx, claims.ID, claims.SessionID)
 }
 
 func (service *AuthService) ExtractUserClaims(claims *jwt.MapClaims) (*UserClaims, error) {
 
 	userID, ok := (*claims)["id"].(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'id' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'id' claim", security.TokenInvalid)
 	}
 	userName, ok := (*claims)["user_name"].(string)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'user_name' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'user_name' claim", security.TokenInvalid)
 	}
 
 	roleName, ok := (*claims)["role_name"].(string)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'role' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'role' claim", security.TokenInvalid)
 	}
 
 	roleIndex, ok := (*claims)["role_index"].(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'role_index' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'role_index' claim", security.TokenInvalid)
 	}
 
 	expiration, ok := (*claims)["exp"].(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'exp' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'exp' claim", security.TokenInvalid)
 	}
 
 	isVerified, ok := (*claims)["is_verified"].(bool)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'is_verified' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'is_verified' claim", security.TokenInvalid)
 	}
 
 	userClaims := NewUserClaims(uint(userID), userName, roleName, uint(roleIndex), expiration, isVerified)
+	userClaims.SessionID, _ = (*claims)["sid"].(string)
+	if impersonatorID, ok := (*claims)["impersonator_id"].(float64); ok {
+		id := uint(impersonatorID)
+		userClaims.ImpersonatorID = &id
+		userClaims.ImpersonatorSessionID, _ = (*claims)["impersonator_sid"].(string)
+	}
+	if requiredActions, ok := (*claims)["required_actions"].([]any); ok {
+		for _, action := range requiredActions {
+			if actionName, ok := action.(string); ok {
+				userClaims.RequiredActions = append(userClaims.RequiredActions, actionName)
+			}
+		}
+	}
 
 	return userClaims, nil
 }
@@ -176,7 +377,7 @@ func (service *AuthService) ParseUserClaims(tokenString string) (*UserClaims, er
 
 		return userClaims, nil
 	}
-	return nil, fmt.Errorf("invalid token")
+	return nil, security.TokenInvalid
 }
 
 func (service *AuthService) GenerateHashPassword(password string) (string, error) {
@@ -217,7 +418,7 @@ func (service *AuthService) RegisterUserAsGuest(ctx context.Context, name string
 func (service *AuthService) RegisterUser(ctx context.Context, name string, email string, password string, platformType PlatformType, externalID *string, userRole string) (*User, error) {
 	existingUser, err := service.UserService.GetUserByEmail(ctx, email)
 	if err == nil {
-		log.Info().Msgf("User already exists: %v", existingUser)
+		LoggerFromContext(ctx).Info().Uint("user_id", existingUser.ID).Msg("User already exists")
 		return existingUser, security.UserAlreadyExists
 	}
 	hashedPassword, err := service.GenerateHashPassword(password)
@@ -239,5 +440,14 @@ func (service *AuthService) RegisterUser(ctx context.Context, name string, email
 		return nil, err
 	}
 
-	return service.UserService.SaveUser(ctx, user)
+	savedUser, err := service.UserService.SaveUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	event := NewAuditEvent(AuditActionRegister, nil)
+	event.SubjectID = AuditUserID(savedUser.ID)
+	event.Metadata["platform"] = string(platformType)
+	event.Metadata["role"] = userRole
+	service.AuditService.Record(ctx, event)
+	return savedUser, nil
 }
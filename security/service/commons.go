@@ -1 +1,27 @@
 package service
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 200
+)
+
+type Page[T any] struct {
+	Items    []T   `json:"items"`
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
+
+// NormalizePagination clamps a 1-based page and its size and returns the matching limit and offset.
+func NormalizePagination(page int, pageSize int) (int, int, int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize, pageSize, (page - 1) * pageSize
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type CookieConfig struct {
+	Domain   string `yaml:"domain"`
+	Path     string `yaml:"path"`
+	HttpOnly bool   `yaml:"http_only"`
+	Secure   bool   `yaml:"secure"`
+	SameSite string `yaml:"same_site"` // lax, strict or none
+}
+
+func NewDefaultCookieConfig() *CookieConfig {
+	return &CookieConfig{
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: "lax",
+	}
+}
+
+func (config *CookieConfig) SameSiteMode() http.SameSite {
+	switch strings.ToLower(config.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	case "lax":
+		return http.SameSiteLaxMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
+
+// NewCookie creates a cookie carrying the configured attributes, a negative duration expires it.
+func (config *CookieConfig) NewCookie(name string, value string, duration time.Duration) *http.Cookie {
+	path := config.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Expires:  time.Now().Add(duration),
+		Path:     path,
+		Domain:   config.Domain,
+		HttpOnly: config.HttpOnly,
+		Secure:   config.Secure,
+		SameSite: config.SameSiteMode(),
+	}
+	if duration < 0 {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// GetCookieConfig returns the session cookie attributes, defaulting to hardened values when the
+// section is missing from the config file.
+func (config *SecurityConfig) GetCookieConfig() *CookieConfig {
+	if config.Cookie == nil {
+		return NewDefaultCookieConfig()
+	}
+	return config.Cookie
+}
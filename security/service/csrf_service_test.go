@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"go-security/security"
+	"testing"
+)
+
+func TestCsrfServiceVerifiesTokenOfItsBinding(t *testing.T) {
+	csrfService := NewCsrfService("test-secret")
+	binding := CsrfBinding("session-1", 1)
+	token, err := csrfService.IssueToken(binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csrfService.VerifyToken(token, binding); err != nil {
+		t.Fatalf("token of the binding rejected: %v", err)
+	}
+}
+
+func TestCsrfServiceRejectsTokenOfAnotherSession(t *testing.T) {
+	csrfService := NewCsrfService("test-secret")
+	attackerToken, err := csrfService.IssueToken(CsrfBinding("attacker-session", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = csrfService.VerifyToken(attackerToken, CsrfBinding("victim-session", 1))
+	if !errors.Is(err, security.CsrfTokenInvalid) {
+		t.Fatalf("expected CsrfTokenInvalid, got %v", err)
+	}
+}
+
+func TestCsrfServiceRejectsAnonymousTokenForALogin(t *testing.T) {
+	csrfService := NewCsrfService("test-secret")
+	token, err := csrfService.IssueToken(CsrfAnonymousBinding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csrfService.VerifyToken(token, CsrfBinding("session-1", 1)); !errors.Is(err, security.CsrfTokenInvalid) {
+		t.Fatalf("expected CsrfTokenInvalid, got %v", err)
+	}
+}
+
+func TestCsrfServiceRejectsMalformedAndForgedTokens(t *testing.T) {
+	csrfService := NewCsrfService("test-secret")
+	binding := CsrfBinding("", 1)
+	forged, err := NewCsrfService("other-secret").IssueToken(binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"", "nonce-only", ".signature", forged} {
+		if err := csrfService.VerifyToken(token, binding); !errors.Is(err, security.CsrfTokenInvalid) {
+			t.Errorf("token %q: expected CsrfTokenInvalid, got %v", token, err)
+		}
+	}
+}
+
+func TestCsrfBindingFallsBackToUser(t *testing.T) {
+	if CsrfBinding("", 7) == CsrfBinding("", 8) {
+		t.Fatal("bindings of different users collide")
+	}
+	if CsrfBinding("s", 7) == CsrfBinding("", 7) {
+		t.Fatal("session binding collides with user binding")
+	}
+}
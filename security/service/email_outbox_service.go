@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"go-security/security"
+	. "go-security/security/repository"
+	mathrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EmailOutboxConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Workers        int           `yaml:"workers"`
+	BatchSize      int           `yaml:"batch_size"`
+	PollInterval   time.Duration `yaml:"poll_interval"`
+	Lease          time.Duration `yaml:"lease"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+func NewDefaultEmailOutboxConfig() *EmailOutboxConfig {
+	return &EmailOutboxConfig{
+		Enabled:        true,
+		Workers:        4,
+		BatchSize:      20,
+		PollInterval:   5 * time.Second,
+		Lease:          2 * time.Minute,
+		MaxAttempts:    8,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Hour,
+	}
+}
+
+// withDefaults fills the zero values of a partially written config section.
+func (config *EmailOutboxConfig) withDefaults() *EmailOutboxConfig {
+	defaults := NewDefaultEmailOutboxConfig()
+	merged := *config
+	if merged.Workers <= 0 {
+		merged.Workers = defaults.Workers
+	}
+	if merged.BatchSize <= 0 {
+		merged.BatchSize = defaults.BatchSize
+	}
+	if merged.PollInterval <= 0 {
+		merged.PollInterval = defaults.PollInterval
+	}
+	if merged.Lease <= 0 {
+		merged.Lease = defaults.Lease
+	}
+	if merged.MaxAttempts <= 0 {
+		merged.MaxAttempts = defaults.MaxAttempts
+	}
+	if merged.InitialBackoff <= 0 {
+		merged.InitialBackoff = defaults.InitialBackoff
+	}
+	if merged.MaxBackoff <= 0 {
+		merged.MaxBackoff = defaults.MaxBackoff
+	}
+	return &merged
+}
+
+// EmailOutboxService is an EmailSender that persists emails into the outbox table, a pool of workers
+// then delivers them through the underlying transport with exponential backoff. Messages exceeding
+// MaxAttempts are dead-lettered and can be retried by an admin. Bodies carry OTPs, reset tokens and magic
+// links, they are encrypted in the table and never exposed through the admin API.
+type EmailOutboxService struct {
+	Config       *EmailOutboxConfig
+	Repository   IEmailOutboxRepository
+	Transport    EmailSender
+	AuditService *AuditService
+
+	bodyCipher cipher.AEAD
+
+	wakeup    chan struct{}
+	cancel    context.CancelFunc
+	waitGroup sync.WaitGroup
+	lock      sync.Mutex
+}
+
+// NewEmailOutboxService derives the key encrypting the stored bodies from secret.
+func NewEmailOutboxService(config *EmailOutboxConfig, repository IEmailOutboxRepository, transport EmailSender, auditService *AuditService, secret string) *EmailOutboxService {
+	if config == nil {
+		config = NewDefaultEmailOutboxConfig()
+	}
+	key := sha256.Sum256([]byte("email-outbox:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err)
+	}
+	bodyCipher, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &EmailOutboxService{
+		Config:       config.withDefaults(),
+		Repository:   repository,
+		Transport:    transport,
+		AuditService: auditService,
+		bodyCipher:   bodyCipher,
+		wakeup:       make(chan struct{}, 1),
+	}
+}
+
+func (service *EmailOutboxService) PostConstruct() {
+	if service.Config.Enabled {
+		service.Start(context.Background())
+	}
+}
+
+// PreDestroy stops polling and lets the workers deliver the messages already claimed.
+func (service *EmailOutboxService) PreDestroy(ctx context.Context) error {
+	return waitUntilDone(ctx, service.Stop)
+}
+
+func (service *EmailOutboxService) Start(ctx context.Context) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	if service.cancel != nil {
+		return
+	}
+	ctx, service.cancel = context.WithCancel(ctx)
+	jobs := make(chan *EmailOutboxMessage, service.Config.BatchSize)
+
+	for i := 0; i < service.Config.Workers; i++ {
+		service.waitGroup.Add(1)
+		go service.work(ctx, jobs)
+	}
+	service.waitGroup.Add(1)
+	go service.poll(ctx, jobs)
+	log.Info().Msgf("Email outbox started with %d workers", service.Config.Workers)
+}
+
+// Stop stops polling and waits for the messages being delivered, unsent messages stay in the table.
+func (service *EmailOutboxService) Stop() {
+	service.lock.Lock()
+	cancel := service.cancel
+	service.cancel = nil
+	service.lock.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	service.waitGroup.Wait()
+	log.Info().Msg("Email outbox stopped")
+}
+
+// Send saves the email in the transaction carried by the context, see ContextWithTransaction, and delivers
+// it in the background once committed: a rollback of the caller withdraws the email, and a failed save
+// fails the transaction. Outside of a transaction the email is saved in its own statement.
+func (service *EmailOutboxService) Send(ctx context.Context, email *Email) error {
+	body, err := service.sealBody(email.Body)
+	if err != nil {
+		return err
+	}
+	textBody, err := service.sealBody(email.AlternativeBody)
+	if err != nil {
+		return err
+	}
+	message := &EmailOutboxMessage{
+		Sender:        email.From,
+		Recipient:     email.To,
+		Subject:       email.Subject,
+		Body:          body,
+		ContentType:   string(email.ContentType),
+		TextBody:      textBody,
+		Attachments:   strings.Join(email.Attachments, "\n"),
+		Status:        EmailOutboxStatusPending,
+		MaxAttempts:   service.Config.MaxAttempts,
+		NextAttemptAt: time.Now(),
+		RequestID:     RequestIDFromContext(ctx),
+	}
+	if err := service.Repository.Save(ctx, message); err != nil {
+		return err
+	}
+	service.notify()
+	return nil
+}
+
+const sealedBodyPrefix = "sealed:"
+
+func (service *EmailOutboxService) sealBody(body string) (string, error) {
+	if len(body) == 0 {
+		return "", nil
+	}
+	nonce := make([]byte, service.bodyCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := service.bodyCipher.Seal(nonce, nonce, []byte(body), nil)
+	return sealedBodyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openBody decrypts a stored body, bodies saved before encryption was introduced are returned as is.
+func (service *EmailOutboxService) openBody(stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedBodyPrefix)
+	if !ok {
+		return stored, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < service.bodyCipher.NonceSize() {
+		return "", security.EmailOutboxBodyUnreadable
+	}
+	nonceSize := service.bodyCipher.NonceSize()
+	body, err := service.bodyCipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", security.EmailOutboxBodyUnreadable
+	}
+	return string(body), nil
+}
+
+func (service *EmailOutboxService) notify() {
+	select {
+	case service.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (service *EmailOutboxService) poll(ctx context.Context, jobs chan<- *EmailOutboxMessage) {
+	defer service.waitGroup.Done()
+	defer close(jobs)
+	ticker := time.NewTicker(service.Config.PollInterval)
+	defer ticker.Stop()
+	for {
+		service.dispatchDueMessages(ctx, jobs)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-service.wakeup:
+		}
+	}
+}
+
+func (service *EmailOutboxService) dispatchDueMessages(ctx context.Context, jobs chan<- *EmailOutboxMessage) {
+	messages, err := service.Repository.ClaimDueMessages(ctx, time.Now(), service.Config.Lease, service.Config.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to claim email outbox messages")
+		}
+		return
+	}
+	for _, message := range messages {
+		select {
+		case jobs <- message:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (service *EmailOutboxService) work(ctx context.Context, jobs <-chan *EmailOutboxMessage) {
+	defer service.waitGroup.Done()
+	for message := range jobs {
+		// In-flight deliveries finish on shutdown so their outcome is still recorded.
+		service.deliver(context.WithoutCancel(ctx), message)
+	}
+}
+
+func (service *EmailOutboxService) asEmail(message *EmailOutboxMessage) (*Email, error) {
+	var attachments []string
+	if len(message.Attachments) > 0 {
+		attachments = strings.Split(message.Attachments, "\n")
+	}
+	body, err := service.openBody(message.Body)
+	if err != nil {
+		return nil, err
+	}
+	textBody, err := service.openBody(message.TextBody)
+	if err != nil {
+		return nil, err
+	}
+	email := NewEmail(message.Sender, message.Recipient, message.Subject, body, ContentType(message.ContentType), attachments...)
+	email.AlternativeBody = textBody
+	email.CreatedAt = message.CreatedAt
+	return email, nil
+}
+
+func (service *EmailOutboxService) deliver(ctx context.Context, message *EmailOutboxMessage) {
+	if len(message.RequestID) > 0 {
+		ctx = ContextWithRequestID(ctx, message.RequestID)
+	}
+	message.Attempts++
+	email, err := service.asEmail(message)
+	if err == nil {
+		err = service.Transport.Send(ctx, email)
+	}
+	now := time.Now()
+	switch {
+	case err == nil:
+		message.Status = EmailOutboxStatusSent
+		message.SentAt = &now
+		message.LastError = ""
+	case message.Attempts >= message.MaxAttempts || errors.Is(err, security.EmailOutboxBodyUnreadable):
+		LoggerFromContext(ctx).Error().Err(err).Msgf("Email outbox message %d dead-lettered after %d attempts", message.ID, message.Attempts)
+		message.Status = EmailOutboxStatusDead
+		message.LastError = err.Error()
+	default:
+		backoff := service.backoff(message.Attempts)
+		LoggerFromContext(ctx).Warn().Err(err).Msgf("Email outbox message %d failed, retrying in %v", message.ID, backoff)
+		message.Status = EmailOutboxStatusPending
+		message.NextAttemptAt = now.Add(backoff)
+		message.LastError = err.Error()
+	}
+	if err := service.Repository.Save(ctx, message); err != nil {
+		LoggerFromContext(ctx).Error().Err(err).Msgf("Failed to update email outbox message %d", message.ID)
+	}
+}
+
+// backoff doubles the delay on every attempt up to MaxBackoff, with up to 20% jitter.
+func (service *EmailOutboxService) backoff(attempts int) time.Duration {
+	delay := service.Config.InitialBackoff
+	for i := 1; i < attempts && delay < service.Config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > service.Config.MaxBackoff {
+		delay = service.Config.MaxBackoff
+	}
+	jitter := time.Duration(mathrand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
+
+func (service *EmailOutboxService) GetMessage(ctx context.Context, id uint) (*EmailOutboxMessage, error) {
+	message, err := service.Repository.FindByID(ctx, id)
+	if err != nil {
+		return nil, security.EmailOutboxMessageNotFound
+	}
+	return message, nil
+}
+
+func (service *EmailOutboxService) GetMessages(ctx context.Context, status EmailOutboxStatus, page int, pageSize int) (*Page[*EmailOutboxMessage], error) {
+	page, pageSize, limit, offset := NormalizePagination(page, pageSize)
+	messages, total, err := service.Repository.FindAll(ctx, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[*EmailOutboxMessage]{Items: messages, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// Retry puts a dead or pending message back in the queue with a fresh attempt budget.
+func (service *EmailOutboxService) Retry(ctx context.Context, id uint) (*EmailOutboxMessage, error) {
+	message, err := service.GetMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if message.Status == EmailOutboxStatusSent || message.Status == EmailOutboxStatusSending {
+		return nil, security.EmailOutboxMessageNotRetryable
+	}
+	message.Status = EmailOutboxStatusPending
+	message.Attempts = 0
+	message.MaxAttempts = service.Config.MaxAttempts
+	message.NextAttemptAt = time.Now()
+	err = service.Repository.Save(ctx, message)
+	event := NewAuditEvent(AuditActionEmailOutboxRetried, err)
+	event.Metadata["message_id"] = message.ID
+	event.Metadata["recipient"] = message.Recipient
+	service.AuditService.Record(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	service.notify()
+	return message, nil
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"go-security/security/repository"
+	"gorm.io/gorm"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryEmailOutboxRepository keeps copies of the messages, as they would be read back from the database.
+type memoryEmailOutboxRepository struct {
+	messages map[uint]repository.EmailOutboxMessage
+	lock     sync.Mutex
+}
+
+func (repo *memoryEmailOutboxRepository) Save(ctx context.Context, message *repository.EmailOutboxMessage) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	if repo.messages == nil {
+		repo.messages = map[uint]repository.EmailOutboxMessage{}
+	}
+	if message.ID == 0 {
+		message.ID = uint(len(repo.messages) + 1)
+		message.CreatedAt = time.Now()
+	}
+	repo.messages[message.ID] = *message
+	return nil
+}
+
+func (repo *memoryEmailOutboxRepository) FindByID(ctx context.Context, id uint) (*repository.EmailOutboxMessage, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	message, ok := repo.messages[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &message, nil
+}
+
+func (repo *memoryEmailOutboxRepository) FindAll(ctx context.Context, status repository.EmailOutboxStatus, limit int, offset int) ([]*repository.EmailOutboxMessage, int64, error) {
+	panic("not used")
+}
+
+func (repo *memoryEmailOutboxRepository) CountByStatus(ctx context.Context, status repository.EmailOutboxStatus) (int64, error) {
+	panic("not used")
+}
+
+func (repo *memoryEmailOutboxRepository) ClaimDueMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*repository.EmailOutboxMessage, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	var claimed []*repository.EmailOutboxMessage
+	for id, message := range repo.messages {
+		due := !message.NextAttemptAt.After(now)
+		claimable := message.Status == repository.EmailOutboxStatusPending || message.Status == repository.EmailOutboxStatusSending
+		if !due || !claimable || len(claimed) == limit {
+			continue
+		}
+		message.Status = repository.EmailOutboxStatusSending
+		message.NextAttemptAt = now.Add(lease)
+		repo.messages[id] = message
+		claimed = append(claimed, &message)
+	}
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].ID < claimed[j].ID })
+	return claimed, nil
+}
+
+func newTestEmailOutboxService(t *testing.T, config *EmailOutboxConfig) (*EmailOutboxService, *memoryEmailOutboxRepository, *MemoryEmailSender, *memoryAuditEventRepository) {
+	t.Helper()
+	repo := &memoryEmailOutboxRepository{}
+	transport := NewMemoryEmailSender()
+	auditService, auditRepository := newTestAuditService(t, 0)
+	return NewEmailOutboxService(config, repo, transport, auditService, "outbox-test-secret"), repo, transport, auditRepository
+}
+
+// claim dispatches the due messages the way the poller does, without starting the workers.
+func claim(t *testing.T, service *EmailOutboxService) []*repository.EmailOutboxMessage {
+	t.Helper()
+	jobs := make(chan *repository.EmailOutboxMessage, service.Config.BatchSize)
+	service.dispatchDueMessages(context.Background(), jobs)
+	close(jobs)
+	var messages []*repository.EmailOutboxMessage
+	for message := range jobs {
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestEmailOutboxBackoff(t *testing.T) {
+	service, _, _, _ := newTestEmailOutboxService(t, &EmailOutboxConfig{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute})
+	tests := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{10, time.Minute},
+	}
+	for _, test := range tests {
+		jittered := false
+		for i := 0; i < 100; i++ {
+			backoff := service.backoff(test.attempts)
+			if backoff < test.base || backoff > test.base+test.base/5 {
+				t.Fatalf("attempt %d: %v is outside [%v, %v]", test.attempts, backoff, test.base, test.base+test.base/5)
+			}
+			jittered = jittered || backoff != test.base
+		}
+		if !jittered {
+			t.Errorf("attempt %d: the backoff carries no jitter", test.attempts)
+		}
+	}
+}
+
+func TestEmailOutboxDeadLettersAfterMaxAttempts(t *testing.T) {
+	service, repo, transport, _ := newTestEmailOutboxService(t, &EmailOutboxConfig{MaxAttempts: 3, InitialBackoff: time.Hour})
+	transport.SendError = errors.New("smtp unavailable")
+	if err := service.Send(context.Background(), NewEmail("noreply@example.com", "alice@example.com", "Hello", "body", ContentTypeText)); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		messages := claim(t, service)
+		if len(messages) != 1 {
+			t.Fatalf("attempt %d: expected the message to be due, got %d", attempt, len(messages))
+		}
+		service.deliver(context.Background(), messages[0])
+		stored, _ := repo.FindByID(context.Background(), messages[0].ID)
+		if stored.Attempts != attempt || stored.LastError != "smtp unavailable" {
+			t.Fatalf("attempt %d: got %d attempts and error %q", attempt, stored.Attempts, stored.LastError)
+		}
+		if attempt < 3 {
+			if stored.Status != repository.EmailOutboxStatusPending || time.Until(stored.NextAttemptAt) < 59*time.Minute {
+				t.Fatalf("attempt %d: expected a pending message backed off, got %s at %v", attempt, stored.Status, stored.NextAttemptAt)
+			}
+			// Skip the backoff.
+			stored.NextAttemptAt = time.Now()
+			_ = repo.Save(context.Background(), stored)
+		} else if stored.Status != repository.EmailOutboxStatusDead {
+			t.Fatalf("expected the message to be dead-lettered, got %s", stored.Status)
+		}
+	}
+	if messages := claim(t, service); len(messages) != 0 {
+		t.Fatalf("a dead message must not be claimed, got %d", len(messages))
+	}
+	if len(transport.Outbox()) != 0 {
+		t.Fatal("no email must have been delivered")
+	}
+}
+
+func TestEmailOutboxRedeliversAfterLeaseExpiry(t *testing.T) {
+	service, _, transport, _ := newTestEmailOutboxService(t, &EmailOutboxConfig{Lease: 50 * time.Millisecond})
+	if err := service.Send(context.Background(), NewEmail("noreply@example.com", "alice@example.com", "Hello", "one-time code 123456", ContentTypeText)); err != nil {
+		t.Fatal(err)
+	}
+	if messages := claim(t, service); len(messages) != 1 || messages[0].Status != repository.EmailOutboxStatusSending {
+		t.Fatalf("expected the message to be leased, got %v", messages)
+	}
+	// The worker holding the lease dies without recording an outcome.
+	if messages := claim(t, service); len(messages) != 0 {
+		t.Fatalf("a leased message must not be claimed twice, got %d", len(messages))
+	}
+	time.Sleep(60 * time.Millisecond)
+	messages := claim(t, service)
+	if len(messages) != 1 {
+		t.Fatalf("expected the message to be claimed again once its lease expired, got %d", len(messages))
+	}
+	service.deliver(context.Background(), messages[0])
+	if email := transport.LastEmail(); email == nil || email.Body != "one-time code 123456" {
+		t.Fatalf("expected the decrypted body to be delivered, got %+v", email)
+	}
+}
+
+func TestEmailOutboxRetry(t *testing.T) {
+	service, repo, transport, auditRepository := newTestEmailOutboxService(t, &EmailOutboxConfig{MaxAttempts: 1})
+	transport.SendError = errors.New("smtp unavailable")
+	if err := service.Send(context.Background(), NewEmail("noreply@example.com", "alice@example.com", "Hello", "body", ContentTypeText)); err != nil {
+		t.Fatal(err)
+	}
+	message := claim(t, service)[0]
+	service.deliver(context.Background(), message)
+	if message.Status != repository.EmailOutboxStatusDead {
+		t.Fatalf("expected the message to be dead-lettered, got %s", message.Status)
+	}
+
+	transport.SendError = nil
+	retried, err := service.Retry(context.Background(), message.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Status != repository.EmailOutboxStatusPending || retried.Attempts != 0 || retried.MaxAttempts != 1 {
+		t.Fatalf("expected a pending message with a fresh budget, got %s after %d/%d attempts", retried.Status, retried.Attempts, retried.MaxAttempts)
+	}
+	if event := auditRepository.last(); event == nil || event.Action != string(AuditActionEmailOutboxRetried) {
+		t.Fatalf("expected the retry to be audited, got %+v", event)
+	}
+	service.deliver(context.Background(), claim(t, service)[0])
+	if stored, _ := repo.FindByID(context.Background(), message.ID); stored.Status != repository.EmailOutboxStatusSent {
+		t.Fatalf("expected the retried message to be sent, got %s", stored.Status)
+	}
+	if _, err := service.Retry(context.Background(), message.ID); !errors.Is(err, security.EmailOutboxMessageNotRetryable) {
+		t.Fatalf("retrying a sent message: got %v", err)
+	}
+	if _, err := service.Retry(context.Background(), 42); !errors.Is(err, security.EmailOutboxMessageNotFound) {
+		t.Fatalf("retrying a missing message: got %v", err)
+	}
+}
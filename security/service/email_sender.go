@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security/metrics"
+	"go-security/security/tracing"
+	"gopkg.in/gomail.v2"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	EmailTransportSmtp    = "smtp"
+	EmailTransportFile    = "file"
+	EmailTransportConsole = "console"
+	EmailTransportMemory  = "memory"
+	EmailTransportSes     = "ses"
+)
+
+var EmailTransportNotSupported = errors.New("EmailTransportNotSupported")
+
+// Email is a transport independent message, every EmailSender renders it to its own wire format.
+type Email struct {
+	From        string
+	To          string
+	Subject     string
+	Body        string
+	ContentType ContentType
+	// AlternativeBody is an optional plain-text part sent along an html Body.
+	AlternativeBody string
+	Attachments     []string
+	CreatedAt       time.Time
+}
+
+type EmailSender interface {
+	Send(ctx context.Context, email *Email) error
+}
+
+func NewEmail(from string, to string, subject string, body string, contentType ContentType, attachments ...string) *Email {
+	return &Email{
+		From:        from,
+		To:          to,
+		Subject:     subject,
+		Body:        body,
+		ContentType: contentType,
+		Attachments: attachments,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (email *Email) AsMessage() *gomail.Message {
+	message := gomail.NewMessage()
+	message.SetHeader("From", email.From)
+	message.SetHeader("To", email.To)
+	message.SetHeader("Subject", email.Subject)
+	message.SetDateHeader("Date", email.CreatedAt)
+	if len(email.AlternativeBody) > 0 {
+		message.SetBody(ContentTypeText, email.AlternativeBody)
+		message.AddAlternative(string(email.ContentType), email.Body)
+	} else {
+		message.SetBody(string(email.ContentType), email.Body)
+	}
+	for _, attachment := range email.Attachments {
+		message.Attach(attachment)
+	}
+	return message
+}
+
+// WriteTo writes the email in RFC 5322 format, which is the content of an .eml file.
+func (email *Email) WriteTo(writer io.Writer) (int64, error) {
+	return email.AsMessage().WriteTo(writer)
+}
+
+func NewEmailSenderFromConfig(config *SmtpConfig) (EmailSender, error) {
+	switch strings.ToLower(config.Transport) {
+	case "", EmailTransportSmtp:
+		return NewSmtpEmailSender(config), nil
+	case EmailTransportFile:
+		return NewFileEmailSender(config.OutputDirectory), nil
+	case EmailTransportConsole:
+		return NewConsoleEmailSender(), nil
+	case EmailTransportMemory:
+		return NewMemoryEmailSender(), nil
+	case EmailTransportSes:
+		return NewSesEmailSenderFromConfig(config.Ses)
+	default:
+		return nil, EmailTransportNotSupported
+	}
+}
+
+func MustNewEmailSenderFromConfig(config *SmtpConfig) EmailSender {
+	sender, err := NewEmailSenderFromConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	return sender
+}
+
+// InstrumentedEmailSender counts the emails sent and failed by the transport it wraps.
+type InstrumentedEmailSender struct {
+	Sender EmailSender
+}
+
+func NewInstrumentedEmailSender(sender EmailSender) *InstrumentedEmailSender {
+	return &InstrumentedEmailSender{
+		Sender: sender,
+	}
+}
+
+func (sender *InstrumentedEmailSender) Send(ctx context.Context, email *Email) error {
+	ctx, span := tracing.Start(ctx, "EmailSender.Send")
+	err := sender.Sender.Send(ctx, email)
+	tracing.End(span, err)
+	metrics.EmailsSent.WithLabelValues(metrics.Outcome(err)).Inc()
+	return err
+}
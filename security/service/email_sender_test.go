@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubSesClient records SendEmail inputs locally instead of calling AWS.
+type stubSesClient struct {
+	inputs []*sesv2.SendEmailInput
+	err    error
+	lock   sync.Mutex
+}
+
+func (client *stubSesClient) SendEmail(ctx context.Context, input *sesv2.SendEmailInput, optFns ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.err != nil {
+		return nil, client.err
+	}
+	client.inputs = append(client.inputs, input)
+	return &sesv2.SendEmailOutput{MessageId: aws.String("stub-message-id")}, nil
+}
+
+func newTestEmail(to string) *Email {
+	email := NewEmail("noreply@example.com", to, "Welcome", "<p>Hello</p>", ContentTypeHtml)
+	email.AlternativeBody = "Hello"
+	return email
+}
+
+func TestFileEmailSenderWritesEml(t *testing.T) {
+	directory := t.TempDir()
+	sender := NewFileEmailSender(directory)
+	if err := sender.Send(context.Background(), newTestEmail("alice+test@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(directory, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v %v", files, err)
+	}
+	if strings.ContainsAny(filepath.Base(files[0]), "+@") {
+		t.Errorf("unsafe characters must not reach the file name, got %s", files[0])
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"To: alice+test@example.com", "Subject: Welcome", "text/plain", "text/html"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected %q in the file", expected)
+		}
+	}
+}
+
+func TestConsoleEmailSenderPrintsEmail(t *testing.T) {
+	var output bytes.Buffer
+	sender := NewConsoleEmailSender()
+	sender.Writer = &output
+	if err := sender.Send(context.Background(), newTestEmail("bob@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	printed := output.String()
+	if !strings.HasPrefix(printed, "----- BEGIN EMAIL -----") || !strings.Contains(printed, "----- END EMAIL -----") {
+		t.Fatalf("expected the email between markers, got %q", printed)
+	}
+	if !strings.Contains(printed, "To: bob@example.com") {
+		t.Errorf("expected the recipient, got %q", printed)
+	}
+}
+
+func TestMemoryEmailSenderCapturesEmails(t *testing.T) {
+	sender := NewMemoryEmailSender()
+	ctx := context.Background()
+	for _, to := range []string{"alice@example.com", "bob@example.com", "alice@example.com"} {
+		if err := sender.Send(ctx, newTestEmail(to)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sender.Outbox()) != 3 || len(sender.FindByRecipient("alice@example.com")) != 2 {
+		t.Fatalf("unexpected outbox %v", sender.Outbox())
+	}
+	if last := sender.LastEmail(); last == nil || last.To != "alice@example.com" {
+		t.Fatalf("unexpected last email %v", last)
+	}
+
+	sender.SendError = errors.New("unavailable")
+	if err := sender.Send(ctx, newTestEmail("carol@example.com")); err == nil || len(sender.Outbox()) != 3 {
+		t.Fatal("a failing send must not be captured")
+	}
+	sender.Reset()
+	if len(sender.Outbox()) != 0 || sender.LastEmail() != nil {
+		t.Fatal("expected an empty outbox after reset")
+	}
+}
+
+func TestSesEmailSenderSendsRawMessage(t *testing.T) {
+	client := &stubSesClient{}
+	sender := NewSesEmailSender(client, "transactional")
+	if err := sender.Send(context.Background(), newTestEmail("dave@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("expected one call, got %d", len(client.inputs))
+	}
+	input := client.inputs[0]
+	if aws.ToString(input.FromEmailAddress) != "noreply@example.com" || input.Destination.ToAddresses[0] != "dave@example.com" {
+		t.Errorf("unexpected addresses %+v", input)
+	}
+	if aws.ToString(input.ConfigurationSetName) != "transactional" {
+		t.Errorf("expected the configuration set, got %v", input.ConfigurationSetName)
+	}
+	if !bytes.Contains(input.Content.Raw.Data, []byte("Subject: Welcome")) {
+		t.Errorf("expected the raw RFC 5322 message, got %q", input.Content.Raw.Data)
+	}
+
+	client.err = errors.New("throttled")
+	if err := sender.Send(context.Background(), newTestEmail("dave@example.com")); !errors.Is(err, client.err) {
+		t.Fatalf("expected the client error, got %v", err)
+	}
+}
+
+func TestNewEmailSenderFromConfig(t *testing.T) {
+	tests := []struct {
+		transport string
+		expected  any
+	}{
+		{"", &SmtpEmailSender{}},
+		{"SMTP", &SmtpEmailSender{}},
+		{"file", &FileEmailSender{}},
+		{"console", &ConsoleEmailSender{}},
+		{"memory", &MemoryEmailSender{}},
+	}
+	for _, test := range tests {
+		sender, err := NewEmailSenderFromConfig(&SmtpConfig{Transport: test.transport, Host: "localhost", Port: 25})
+		if err != nil {
+			t.Fatalf("%q: %v", test.transport, err)
+		}
+		if got, want := fmt.Sprintf("%T", sender), fmt.Sprintf("%T", test.expected); got != want {
+			t.Errorf("%q: expected %s, got %s", test.transport, want, got)
+		}
+	}
+	if _, err := NewEmailSenderFromConfig(&SmtpConfig{Transport: "pigeon"}); !errors.Is(err, EmailTransportNotSupported) {
+		t.Errorf("expected EmailTransportNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+var unsafeFileNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// FileEmailSender writes every email as an .eml file into a directory, which can be opened by any mail client.
+type FileEmailSender struct {
+	Directory string
+}
+
+func NewFileEmailSender(directory string) *FileEmailSender {
+	if len(directory) == 0 {
+		directory = filepath.Join(os.TempDir(), "go-security-mails")
+	}
+	return &FileEmailSender{
+		Directory: directory,
+	}
+}
+
+func (sender *FileEmailSender) Send(ctx context.Context, email *Email) error {
+	if err := os.MkdirAll(sender.Directory, 0o755); err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf("%s_%s.eml",
+		email.CreatedAt.Format("20060102T150405.000000000"),
+		unsafeFileNameCharacters.ReplaceAllString(email.To, "_"),
+	)
+	file, err := os.Create(filepath.Join(sender.Directory, fileName))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = email.WriteTo(file)
+	return err
+}
+
+// CheckHealth makes sure the output directory exists.
+func (sender *FileEmailSender) CheckHealth(ctx context.Context) error {
+	return os.MkdirAll(sender.Directory, 0o755)
+}
+
+// ConsoleEmailSender prints every email to a writer, os.Stdout by default.
+type ConsoleEmailSender struct {
+	Writer io.Writer
+	lock   sync.Mutex
+}
+
+func NewConsoleEmailSender() *ConsoleEmailSender {
+	return &ConsoleEmailSender{
+		Writer: os.Stdout,
+	}
+}
+
+func (sender *ConsoleEmailSender) Send(ctx context.Context, email *Email) error {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	if _, err := fmt.Fprintln(sender.Writer, "----- BEGIN EMAIL -----"); err != nil {
+		return err
+	}
+	if _, err := email.WriteTo(sender.Writer); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(sender.Writer, "\n----- END EMAIL -----")
+	return err
+}
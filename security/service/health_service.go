@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultHealthCheckTimeout = 3 * time.Second
+
+type HealthStatus string
+
+const (
+	HealthStatusUp       HealthStatus = "up"
+	HealthStatusDegraded HealthStatus = "degraded"
+	HealthStatusDown     HealthStatus = "down"
+)
+
+// HealthChecker reports whether a component works, a nil error means it is up.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+type HealthCheckerFunc func(ctx context.Context) error
+
+func (check HealthCheckerFunc) CheckHealth(ctx context.Context) error {
+	return check(ctx)
+}
+
+type ComponentHealth struct {
+	Status   HealthStatus `json:"status"`
+	Critical bool         `json:"critical"`
+	Error    string       `json:"error,omitempty"`
+	Latency  string       `json:"latency"`
+}
+
+type HealthReport struct {
+	Status     HealthStatus                `json:"status"`
+	Ready      bool                        `json:"ready"`
+	Components map[string]*ComponentHealth `json:"components"`
+	CheckedAt  time.Time                   `json:"checked_at"`
+}
+
+type registeredHealthCheck struct {
+	name     string
+	critical bool
+	checker  HealthChecker
+}
+
+// HealthService runs the registered checks for the health and readiness probes. The application is down
+// when a critical component is, and degraded when only non-critical components are.
+type HealthService struct {
+	Timeout time.Duration
+	checks  []*registeredHealthCheck
+	lock    sync.RWMutex
+	ready   atomic.Bool
+}
+
+func NewHealthService(timeout time.Duration) *HealthService {
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	return &HealthService{
+		Timeout: timeout,
+	}
+}
+
+func (service *HealthService) PostConstruct() {}
+
+// Register adds a check, registering a name again replaces its check.
+func (service *HealthService) Register(name string, critical bool, checker HealthChecker) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	check := &registeredHealthCheck{name: name, critical: critical, checker: checker}
+	for i, registered := range service.checks {
+		if registered.name == name {
+			service.checks[i] = check
+			return
+		}
+	}
+	service.checks = append(service.checks, check)
+}
+
+// SetReady is called by the application once every service is constructed, and again when it stops.
+func (service *HealthService) SetReady(ready bool) {
+	service.ready.Store(ready)
+}
+
+func (service *HealthService) IsReady() bool {
+	return service.ready.Load()
+}
+
+// Check runs every check concurrently, each bounded by the timeout of the service.
+func (service *HealthService) Check(ctx context.Context) *HealthReport {
+	service.lock.RLock()
+	checks := append([]*registeredHealthCheck(nil), service.checks...)
+	service.lock.RUnlock()
+
+	report := &HealthReport{
+		Status:     HealthStatusUp,
+		Ready:      service.IsReady(),
+		Components: make(map[string]*ComponentHealth, len(checks)),
+		CheckedAt:  time.Now(),
+	}
+	results := make([]*ComponentHealth, len(checks))
+	var waitGroup sync.WaitGroup
+	for i, check := range checks {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			results[i] = service.runCheck(ctx, check)
+		}()
+	}
+	waitGroup.Wait()
+
+	for i, check := range checks {
+		component := results[i]
+		report.Components[check.name] = component
+		switch {
+		case component.Status == HealthStatusUp:
+		case check.critical:
+			report.Status = HealthStatusDown
+		case report.Status == HealthStatusUp:
+			report.Status = HealthStatusDegraded
+		}
+	}
+	return report
+}
+
+func (service *HealthService) runCheck(ctx context.Context, check *registeredHealthCheck) *ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+	startedAt := time.Now()
+	// A check ignoring its context is abandoned once the timeout expires.
+	var checkErr error
+	err := waitUntilDone(ctx, func() { checkErr = check.checker.CheckHealth(ctx) })
+	if err == nil {
+		err = checkErr
+	}
+	component := &ComponentHealth{
+		Status:   HealthStatusUp,
+		Critical: check.critical,
+		Latency:  time.Since(startedAt).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		component.Status = HealthStatusDown
+		component.Error = err.Error()
+		LoggerFromContext(ctx).Warn().Err(err).Msgf("Health check %s failed", check.name)
+	}
+	return component
+}
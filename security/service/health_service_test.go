@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func healthCheck(err error) HealthChecker {
+	return HealthCheckerFunc(func(ctx context.Context) error { return err })
+}
+
+func TestHealthStatus(t *testing.T) {
+	failure := errors.New("connection refused")
+	tests := []struct {
+		name     string
+		database error
+		sms      error
+		status   HealthStatus
+	}{
+		{"all up", nil, nil, HealthStatusUp},
+		{"non-critical down", nil, failure, HealthStatusDegraded},
+		{"critical down", failure, nil, HealthStatusDown},
+		{"both down", failure, failure, HealthStatusDown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := NewHealthService(time.Second)
+			service.Register("database", true, healthCheck(test.database))
+			service.Register("sms", false, healthCheck(test.sms))
+			report := service.Check(context.Background())
+			if report.Status != test.status {
+				t.Fatalf("expected %s, got %s", test.status, report.Status)
+			}
+			if sms := report.Components["sms"]; (test.sms != nil) != (sms.Status == HealthStatusDown) || sms.Critical {
+				t.Fatalf("unexpected sms component %+v", sms)
+			}
+			if database := report.Components["database"]; test.database != nil && database.Error != failure.Error() {
+				t.Fatalf("expected the cause of the failure, got %+v", database)
+			}
+		})
+	}
+}
+
+func TestHealthCheckTimeoutDegrades(t *testing.T) {
+	service := NewHealthService(20 * time.Millisecond)
+	service.Register("database", true, healthCheck(nil))
+	hung := make(chan struct{})
+	defer close(hung)
+	service.Register("line", false, HealthCheckerFunc(func(ctx context.Context) error {
+		<-hung
+		return nil
+	}))
+	startedAt := time.Now()
+	report := service.Check(context.Background())
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Fatalf("a hung check must be abandoned after the timeout, took %v", elapsed)
+	}
+	if report.Status != HealthStatusDegraded || report.Components["line"].Status != HealthStatusDown {
+		t.Fatalf("expected a hung non-critical check to degrade the application, got %+v", report)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"go-security/security"
+	"time"
+)
+
+const (
+	// ImpersonationTokenExpiration time-boxes impersonation, the token cannot be refreshed.
+	ImpersonationTokenExpiration = 30 * time.Minute
+
+	AuditActionImpersonationStarted AuditAction = "impersonation_started"
+	AuditActionImpersonationStopped AuditAction = "impersonation_stopped"
+)
+
+// StartImpersonation issues a token letting the impersonator act as the target user. Only users of a
+// lower role can be impersonated, and an impersonation cannot be nested.
+func (service *AuthService) StartImpersonation(ctx context.Context, impersonator *UserClaims, targetID uint) (string, error) {
+	token, err := service.startImpersonation(ctx, impersonator, targetID)
+	event := NewAuditEvent(AuditActionImpersonationStarted, err)
+	event.ActorID = AuditUserID(impersonator.ID)
+	event.SubjectID = AuditUserID(targetID)
+	service.AuditService.Record(ctx, event)
+	return token, err
+}
+
+func (service *AuthService) startImpersonation(ctx context.Context, impersonator *UserClaims, targetID uint) (string, error) {
+	if impersonator.IsImpersonated() || impersonator.ID == targetID {
+		return "", security.ImpersonationNotAllowed
+	}
+	target, err := service.UserService.GetUserByID(ctx, targetID)
+	if err != nil {
+		return "", err
+	}
+	if target.Role.RoleIndex >= impersonator.RoleIndex {
+		return "", security.ImpersonationNotAllowed
+	}
+	claims := loginClaims(target, "", ImpersonationTokenExpiration)
+	if service.SessionService != nil {
+		session, err := service.SessionService.CreateImpersonationSession(ctx, target, impersonator.ID, ImpersonationTokenExpiration)
+		if err != nil {
+			return "", err
+		}
+		claims["sid"] = session.ID
+	}
+	claims["impersonator_id"] = impersonator.ID
+	if len(impersonator.SessionID) > 0 {
+		claims["impersonator_sid"] = impersonator.SessionID
+	}
+	return service.IssueJsonWebToken(&claims), nil
+}
+
+// StopImpersonation ends the impersonation of the claims and resumes the session the impersonator started
+// it from, the token issued for it expires with that session. When that session is no longer active,
+// ImpersonatorSessionEnded is returned and the impersonator has to log in again.
+func (service *AuthService) StopImpersonation(ctx context.Context, claims *UserClaims) (string, error) {
+	if !claims.IsImpersonated() {
+		return "", security.NotImpersonating
+	}
+	var err error
+	if len(claims.SessionID) > 0 && service.SessionService != nil {
+		err = service.SessionService.RevokeSession(ctx, claims.SessionID)
+	}
+	event := NewAuditEvent(AuditActionImpersonationStopped, err)
+	event.ActorID = claims.ImpersonatorID
+	event.SubjectID = AuditUserID(claims.ID)
+	service.AuditService.Record(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	return service.resumeImpersonatorSession(ctx, claims)
+}
+
+func (service *AuthService) resumeImpersonatorSession(ctx context.Context, claims *UserClaims) (string, error) {
+	if len(claims.ImpersonatorSessionID) == 0 || service.SessionService == nil {
+		return "", security.ImpersonatorSessionEnded
+	}
+	session, err := service.SessionService.ValidateSession(ctx, claims.ImpersonatorSessionID)
+	if err != nil || session.UserID != *claims.ImpersonatorID || session.ImpersonatorID != nil {
+		return "", security.ImpersonatorSessionEnded
+	}
+	impersonator, err := service.UserService.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return "", err
+	}
+	requiredActions, expiration, err := service.loginTokenRestrictions(ctx, impersonator, time.Until(session.ExpiresAt))
+	if err != nil {
+		return "", err
+	}
+	return service.issueLoginToken(impersonator, session.ID, requiredActions, expiration), nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"go-security/security/repository"
+	"testing"
+	"time"
+)
+
+const (
+	testSuperAdminID uint = 10
+	testCustomerID   uint = 11
+)
+
+func newTestImpersonationAuthService() (*AuthService, *memorySessionRepository) {
+	users := &memoryUserRepository{users: map[uint]*repository.User{
+		testSuperAdminID: {ID: testSuperAdminID, Name: "admin", Role: repository.UserRole{Name: RoleSuperAdmin, RoleIndex: 3}},
+		testCustomerID:   {ID: testCustomerID, Name: "customer", Role: repository.UserRole{Name: RoleGuest, RoleIndex: 1}},
+	}}
+	sessions := newMemorySessionRepository()
+	sessionService := NewSessionService(sessions, &memoryKnownDeviceRepository{}, nil)
+	return NewAuthService(NewUserService(users), nil, nil, nil, sessionService, nil, nil, "test-secret"), sessions
+}
+
+// impersonate logs the super admin in for adminExpiration and starts impersonating the customer.
+func impersonate(t *testing.T, service *AuthService, adminExpiration time.Duration) (*UserClaims, *UserClaims) {
+	t.Helper()
+	ctx := context.Background()
+	admin, _ := service.UserService.GetUserByID(ctx, testSuperAdminID)
+	token, err := service.IssueLoginToken(ctx, admin, adminExpiration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminClaims, err := service.AuthenticateToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err = service.StartImpersonation(ctx, adminClaims, testCustomerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonationClaims, err := service.AuthenticateToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return adminClaims, impersonationClaims
+}
+
+func TestStopImpersonationResumesOriginalSession(t *testing.T) {
+	service, _ := newTestImpersonationAuthService()
+	ctx := context.Background()
+	adminClaims, impersonationClaims := impersonate(t, service, 10*time.Minute)
+
+	token, err := service.StopImpersonation(ctx, impersonationClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := service.AuthenticateToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.ID != testSuperAdminID || resumed.SessionID != adminClaims.SessionID || resumed.IsImpersonated() {
+		t.Fatalf("expected the original session of the admin, got %+v", resumed)
+	}
+	if resumed.ExpirationDuration > adminClaims.ExpirationDuration {
+		t.Fatalf("the resumed token must not outlive the original session: %v > %v", resumed.ExpirationDuration, adminClaims.ExpirationDuration)
+	}
+	if _, err := service.SessionService.ValidateSession(ctx, impersonationClaims.SessionID); !errors.Is(err, security.SessionRevoked) {
+		t.Fatalf("the impersonation session must be revoked, got %v", err)
+	}
+}
+
+func TestStopImpersonationRequiresLiveOriginalSession(t *testing.T) {
+	service, _ := newTestImpersonationAuthService()
+	ctx := context.Background()
+	adminClaims, impersonationClaims := impersonate(t, service, 10*time.Minute)
+	if err := service.SessionService.RevokeSession(ctx, adminClaims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.StopImpersonation(ctx, impersonationClaims); !errors.Is(err, security.ImpersonatorSessionEnded) {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := service.SessionService.ValidateSession(ctx, impersonationClaims.SessionID); !errors.Is(err, security.SessionRevoked) {
+		t.Fatalf("the impersonation session must be revoked even so, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/line/line-bot-sdk-go/v8/linebot/messaging_api"
+	"go-security/security"
+	. "go-security/security/repository"
+	"sync"
+)
+
+type LineConfig struct {
+	ChannelAccessToken string `yaml:"channel_access_token" json:"-" secret:"true"`
+}
+
+// LineClient is the subset of the LINE Messaging API client used by LineNotifier, so it can be stubbed in tests.
+type LineClient interface {
+	PushMessage(pushMessageRequest *messaging_api.PushMessageRequest, xLineRetryKey string) (*messaging_api.PushMessageResponse, error)
+}
+
+func NewLineClientFromConfig(config *LineConfig) (LineClient, error) {
+	if config == nil || len(config.ChannelAccessToken) == 0 {
+		return NewStubLineClient(), nil
+	}
+	return messaging_api.NewMessagingApiAPI(config.ChannelAccessToken)
+}
+
+func MustNewLineClientFromConfig(config *LineConfig) LineClient {
+	client, err := NewLineClientFromConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// LineNotifier pushes notifications to users who signed in with LINE, their LINE user ID is the external ID.
+type LineNotifier struct {
+	Client LineClient
+}
+
+func NewLineNotifier(client LineClient) *LineNotifier {
+	return &LineNotifier{
+		Client: client,
+	}
+}
+
+func (notifier *LineNotifier) Channel() NotificationChannel {
+	return NotificationChannelLine
+}
+
+func (notifier *LineNotifier) Notify(ctx context.Context, user *User, notification *Notification) error {
+	if user.Platform.Name != string(PlatformLine) || user.ExternalID == nil {
+		return security.NotificationChannelUnavailable
+	}
+	request := &messaging_api.PushMessageRequest{
+		To: *user.ExternalID,
+		Messages: []messaging_api.MessageInterface{
+			messaging_api.TextMessage{Text: fmt.Sprintf("%s\n%s", notification.Title, notification.Message)},
+		},
+	}
+	// The retry key makes LINE drop duplicates if the push is retried after a timeout.
+	_, err := notifier.Client.PushMessage(request, uuid.NewString())
+	return err
+}
+
+// StubLineClient records push requests locally instead of calling the LINE API.
+type StubLineClient struct {
+	Requests []*messaging_api.PushMessageRequest
+	Error    error
+	lock     sync.Mutex
+}
+
+func NewStubLineClient() *StubLineClient {
+	return &StubLineClient{}
+}
+
+func (client *StubLineClient) PushMessage(pushMessageRequest *messaging_api.PushMessageRequest, xLineRetryKey string) (*messaging_api.PushMessageResponse, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.Error != nil {
+		return nil, client.Error
+	}
+	client.Requests = append(client.Requests, pushMessageRequest)
+	return &messaging_api.PushMessageResponse{}, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v4"
+	"go-security/security"
+	. "go-security/security/repository"
+	"time"
+)
+
+const DefaultLoginReportTtl = 7 * 24 * time.Hour
+
+type LoginAlertConfig struct {
+	// ReportUrl is the "this wasn't me" page linked from new sign-in alerts, the report token is appended
+	// as a query parameter and posted back to /api/public/report-login. Alerts carry no link without it.
+	ReportUrl string        `yaml:"report_url"`
+	ReportTtl time.Duration `yaml:"report_ttl"`
+	// ResetUrl is the page completing the password reset forced by a report, the reset token is appended
+	// as a query parameter and posted back with the emailed code to /api/public/reset-password. Without
+	// it no reset email is sent, the user goes through the regular forgotten password flow.
+	ResetUrl string `yaml:"reset_url"`
+}
+
+func (config *LoginAlertConfig) GetReportTtl() time.Duration {
+	if config.ReportTtl <= 0 {
+		return DefaultLoginReportTtl
+	}
+	return config.ReportTtl
+}
+
+func (config *SecurityConfig) GetLoginAlertConfig() *LoginAlertConfig {
+	if config.LoginAlert == nil {
+		return &LoginAlertConfig{}
+	}
+	return config.LoginAlert
+}
+
+type LoginReportClaims struct {
+	ID                 uint    `json:"id"`
+	SessionID          string  `json:"sid"`
+	TokenID            string  `json:"jti"`
+	ExpirationDuration float64 `json:"exp"`
+}
+
+// alertNewDevice notifies the user when the session comes from a device they never signed in from.
+// Alerts are best effort, a failure is logged and never fails the login.
+func (service *AuthService) alertNewDevice(ctx context.Context, user *User, session *Session) {
+	isNew, err := service.SessionService.RecognizeDevice(ctx, session)
+	if err != nil {
+		LoggerFromContext(ctx).Error().Err(err).Msgf("Failed to recognize the device of user %d", user.ID)
+		return
+	}
+	if !isNew || service.NotificationService == nil {
+		return
+	}
+	notification := NewSecurityNotification(SecurityEventNewLogin, RequestMetadataFromContext(ctx))
+	if config := service.LoginAlertConfig; config != nil && len(config.ReportUrl) > 0 {
+		link, err := service.buildLoginReportLink(config, user, session)
+		if err != nil {
+			LoggerFromContext(ctx).Error().Err(err).Msg("Failed to build the login report link")
+		} else {
+			notification.Link = link
+			notification.LinkLabel = "This wasn't me"
+		}
+	}
+	service.NotificationService.NotifyUser(ctx, user.ID, notification)
+}
+
+func (service *AuthService) buildLoginReportLink(config *LoginAlertConfig, user *User, session *Session) (string, error) {
+	tokenID, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"purpose": string(PurposeLoginReport),
+		"id":      user.ID,
+		"sid":     session.ID,
+		"jti":     tokenID,
+		"exp":     time.Now().Add(config.GetReportTtl()).Unix(),
+	}
+	return buildTokenLink(config.ReportUrl, service.IssueJsonWebToken(&claims))
+}
+
+func (service *AuthService) parseLoginReportClaims(token string) (*LoginReportClaims, error) {
+	_jwt, err := service.DecodeJsonWebToken(token)
+	if err != nil {
+		return nil, security.TokenInvalid
+	}
+	claims, ok := _jwt.Claims.(jwt.MapClaims)
+	if !ok || !_jwt.Valid {
+		return nil, security.TokenInvalid
+	}
+	purpose, ok := claims["purpose"].(string)
+	if !ok || purpose != string(PurposeLoginReport) {
+		return nil, security.TokenInvalid
+	}
+	userID, ok := claims["id"].(float64)
+	if !ok {
+		return nil, security.TokenInvalid
+	}
+	sessionID, ok := claims["sid"].(string)
+	if !ok || len(sessionID) == 0 {
+		return nil, security.TokenInvalid
+	}
+	tokenID, ok := claims["jti"].(string)
+	if !ok || len(tokenID) == 0 {
+		return nil, security.TokenInvalid
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, security.TokenInvalid
+	}
+	return &LoginReportClaims{ID: uint(userID), SessionID: sessionID, TokenID: tokenID, ExpirationDuration: exp}, nil
+}
+
+// ReportLogin handles the "this wasn't me" link of a new sign-in alert: the session is revoked and users
+// signing in with a password must reset it, their next logins are restricted until they do. The report
+// token can only be used once.
+func (service *AuthService) ReportLogin(ctx context.Context, token string) (*User, error) {
+	claims, err := service.parseLoginReportClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Unix(int64(claims.ExpirationDuration), 0)
+	if err := service.UsedTokenService.Redeem(ctx, PurposeLoginReport, claims.TokenID, expiresAt); err != nil {
+		return nil, err
+	}
+	user, err := service.UserService.GetUserByID(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.SessionService.ReportSession(ctx, user.ID, claims.SessionID); err != nil {
+		return nil, err
+	}
+	if user.Platform.Name == string(PlatformSelf) && service.AccountActionService != nil {
+		if _, err := service.AccountActionService.RequestAction(ctx, user.ID, AccountActionResetPassword, nil, true); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"go-security/security"
+	. "go-security/security/repository"
+	"net/url"
+	"time"
+)
+
+const (
+	DefaultMagicLinkTtl               = 10 * time.Minute
+	DefaultMagicLinkBindingCookieName = "magic_link_binding"
+	DefaultMagicLinkTokenParameter    = "token"
+)
+
+type MagicLinkConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Ttl     time.Duration `yaml:"ttl"`
+	// LinkUrl is the page the emailed link opens, the token is appended as a query parameter. The page
+	// is expected to post the token back, so link scanners prefetching the URL do not consume it.
+	LinkUrl           string `yaml:"link_url"`
+	IncludeCode       bool   `yaml:"include_code"`
+	BindToBrowser     bool   `yaml:"bind_to_browser"`
+	BindingCookieName string `yaml:"binding_cookie_name"`
+}
+
+func (config *MagicLinkConfig) GetTtl() time.Duration {
+	if config.Ttl <= 0 {
+		return DefaultMagicLinkTtl
+	}
+	return config.Ttl
+}
+
+func (config *MagicLinkConfig) GetBindingCookieName() string {
+	if len(config.BindingCookieName) == 0 {
+		return DefaultMagicLinkBindingCookieName
+	}
+	return config.BindingCookieName
+}
+
+func (config *SecurityConfig) GetMagicLinkConfig() *MagicLinkConfig {
+	if config.MagicLink == nil {
+		return &MagicLinkConfig{}
+	}
+	return config.MagicLink
+}
+
+type MagicLinkClaims struct {
+	ID                 uint    `json:"id"`
+	TokenID            string  `json:"jti"`
+	Binding            string  `json:"binding"`
+	ExpirationDuration float64 `json:"exp"`
+}
+
+// MagicLinkService implements passwordless login: a signed single-use link, and optionally a code,
+// is emailed to the user and exchanged for a regular login token.
+type MagicLinkService struct {
+	Config           *MagicLinkConfig
+	UserService      *UserService
+	AuthService      *AuthService
+	OtpService       *OtpService
+	SmtpService      ISmtpService
+	TemplateRegistry *TemplateRegistry
+	UsedTokenService *UsedTokenService
+}
+
+func NewMagicLinkService(config *MagicLinkConfig, userService *UserService, authService *AuthService, otpService *OtpService, smtpService ISmtpService, templateRegistry *TemplateRegistry, usedTokenService *UsedTokenService) *MagicLinkService {
+	if config == nil {
+		config = &MagicLinkConfig{}
+	}
+	return &MagicLinkService{
+		Config:           config,
+		UserService:      userService,
+		AuthService:      authService,
+		OtpService:       otpService,
+		SmtpService:      smtpService,
+		TemplateRegistry: templateRegistry,
+		UsedTokenService: usedTokenService,
+	}
+}
+
+func (service *MagicLinkService) PostConstruct() {}
+
+func randomString(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+func hashBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueBinding returns a random value to store in the requesting browser, links issued with it can
+// only be redeemed by a request presenting the same value.
+func (service *MagicLinkService) IssueBinding() (string, error) {
+	return randomString(32)
+}
+
+// SendMagicLink emails a login link to the user. Unknown emails are ignored without an error so the
+// endpoint cannot be used to find out which addresses are registered.
+func (service *MagicLinkService) SendMagicLink(ctx context.Context, email string, binding string) error {
+	if !service.Config.Enabled {
+		return security.MagicLinkDisabled
+	}
+	user, err := service.UserService.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, security.UserNotFound) {
+			LoggerFromContext(ctx).Info().Msgf("Magic link requested for unknown email")
+			return nil
+		}
+		return err
+	}
+	token, tokenID, expiresAt, err := service.issueMagicLinkToken(user, binding)
+	if err != nil {
+		return err
+	}
+	service.AuthService.AuditService.RecordUserAction(ctx, AuditActionMagicLinkRequested, user.ID, nil)
+	link, err := service.buildLink(token)
+	if err != nil {
+		return err
+	}
+	code := ""
+	if service.Config.IncludeCode {
+		code = service.OtpService.GenerateLinkedOtp(user.ID, PurposeMagicLink, tokenID, expiresAt).Code
+	}
+	emailTemplate := NewEmailTemplate(user.Name, code, service.SmtpService.GetSmtpConfig().CompanyName).
+		WithLocale(user.Locale).
+		WithLink(link).
+		WithVariable("ttl_minutes", fmt.Sprintf("%d", int(service.Config.GetTtl().Minutes())))
+	rendered, err := service.TemplateRegistry.Render(TemplateMagicLink, emailTemplate)
+	if err != nil {
+		return err
+	}
+	return service.SmtpService.SendEmail(ctx, service.SmtpService.CreateNewTemplatedMessage(user.Email, rendered))
+}
+
+func (service *MagicLinkService) issueMagicLinkToken(user *User, binding string) (string, string, time.Time, error) {
+	tokenID, err := randomString(16)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(service.Config.GetTtl())
+	claims := jwt.MapClaims{
+		"purpose": string(PurposeMagicLink),
+		"id":      user.ID,
+		"jti":     tokenID,
+		"exp":     expiresAt.Unix(),
+	}
+	if service.Config.BindToBrowser && len(binding) > 0 {
+		claims["binding"] = hashBinding(binding)
+	}
+	return service.AuthService.IssueJsonWebToken(&claims), tokenID, expiresAt, nil
+}
+
+func (service *MagicLinkService) buildLink(token string) (string, error) {
+	return buildTokenLink(service.Config.LinkUrl, token)
+}
+
+// buildTokenLink appends the token to the link of a frontend page as the token query parameter.
+func buildTokenLink(linkUrl string, token string) (string, error) {
+	link, err := url.Parse(linkUrl)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set(DefaultMagicLinkTokenParameter, token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// LoginWithToken redeems a magic link token, the binding is the value stored in the browser redeeming it.
+func (service *MagicLinkService) LoginWithToken(ctx context.Context, token string, binding string, expiration time.Duration) (string, error) {
+	if !service.Config.Enabled {
+		return "", security.MagicLinkDisabled
+	}
+	claims, err := service.parseMagicLinkClaims(token)
+	if err != nil {
+		return "", err
+	}
+	if len(claims.Binding) > 0 && subtle.ConstantTimeCompare([]byte(claims.Binding), []byte(hashBinding(binding))) != 1 {
+		service.AuthService.RecordLogin(ctx, claims.ID, "magic_link", security.MagicLinkBrowserMismatch)
+		return "", security.MagicLinkBrowserMismatch
+	}
+	if err := service.markTokenUsed(ctx, claims.TokenID, time.Unix(int64(claims.ExpirationDuration), 0)); err != nil {
+		service.AuthService.RecordLogin(ctx, claims.ID, "magic_link", err)
+		return "", err
+	}
+	service.OtpService.RemoveLinkedOtp(claims.ID, PurposeMagicLink, claims.TokenID)
+	user, err := service.UserService.GetUserByID(ctx, claims.ID)
+	if err != nil {
+		return "", err
+	}
+	return service.login(ctx, user, "magic_link", expiration)
+}
+
+// LoginWithCode redeems the code sent along with the link, the code is removed and the link marked used.
+func (service *MagicLinkService) LoginWithCode(ctx context.Context, email string, code string, expiration time.Duration) (string, error) {
+	if !service.Config.Enabled || !service.Config.IncludeCode {
+		return "", security.MagicLinkDisabled
+	}
+	user, err := service.UserService.GetUserByEmail(ctx, email)
+	if err != nil {
+		return "", security.OtpIncorrect
+	}
+	otp, err := service.OtpService.ConsumeLinkedOtp(user.ID, PurposeMagicLink, code)
+	if err == nil && len(otp.TokenID) > 0 {
+		err = service.markTokenUsed(ctx, otp.TokenID, otp.TokenExpiresAt)
+	}
+	if err != nil {
+		service.AuthService.RecordLogin(ctx, user.ID, "magic_link_code", err)
+		return "", err
+	}
+	return service.login(ctx, user, "magic_link_code", expiration)
+}
+
+// login issues the login token, following the link proves the user owns the email, so unverified users are activated.
+func (service *MagicLinkService) login(ctx context.Context, user *User, method string, expiration time.Duration) (string, error) {
+	if !user.IsVerified {
+		if err := service.UserService.ActivateUser(ctx, user); err != nil {
+			return "", err
+		}
+		user.IsVerified = true
+	}
+	token, err := service.AuthService.IssueLoginToken(ctx, user, expiration)
+	service.AuthService.RecordLogin(ctx, user.ID, method, err)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (service *MagicLinkService) markTokenUsed(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	err := service.UsedTokenService.Redeem(ctx, PurposeMagicLink, tokenID, expiresAt)
+	if errors.Is(err, security.TokenAlreadyUsed) {
+		return security.MagicLinkAlreadyUsed
+	}
+	return err
+}
+
+func (service *MagicLinkService) parseMagicLinkClaims(token string) (*MagicLinkClaims, error) {
+	_jwt, err := service.AuthService.DecodeJsonWebToken(token)
+	if err != nil {
+		return nil, security.TokenInvalid
+	}
+	claims, ok := _jwt.Claims.(jwt.MapClaims)
+	if !ok || !_jwt.Valid {
+		return nil, security.TokenInvalid
+	}
+	purpose, ok := claims["purpose"].(string)
+	if !ok || purpose != string(PurposeMagicLink) {
+		return nil, security.TokenInvalid
+	}
+	var magicLinkClaims MagicLinkClaims
+	userID, ok := claims["id"].(float64)
+	if !ok {
+		return nil, security.TokenInvalid
+	}
+	magicLinkClaims.ID = uint(userID)
+	magicLinkClaims.TokenID, ok = claims["jti"].(string)
+	if !ok || len(magicLinkClaims.TokenID) == 0 {
+		return nil, security.TokenInvalid
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, security.TokenInvalid
+	}
+	magicLinkClaims.ExpirationDuration = exp
+	magicLinkClaims.Binding, _ = claims["binding"].(string)
+	return &magicLinkClaims, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"go-security/security/repository"
+	"testing"
+	"time"
+)
+
+type memoryUsedTokenRepository struct {
+	tokens map[string]bool
+}
+
+func (repo *memoryUsedTokenRepository) MarkUsed(ctx context.Context, token *repository.UsedToken) (bool, error) {
+	if repo.tokens[token.TokenID] {
+		return false, nil
+	}
+	repo.tokens[token.TokenID] = true
+	return true, nil
+}
+
+func (repo *memoryUsedTokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+const testMagicLinkEmail = "alice@example.com"
+
+func newTestMagicLinkService() *MagicLinkService {
+	users := &memoryUserRepository{users: map[uint]*repository.User{
+		testSelfUserID: {ID: testSelfUserID, Name: "alice", Email: testMagicLinkEmail, IsVerified: true, Role: repository.UserRole{Name: RoleGuest}},
+	}}
+	userService := NewUserService(users)
+	authService := NewAuthService(userService, nil, nil, nil, nil, nil, nil, "test-secret")
+	otpService := NewOtpService(func() string { return "123456" })
+	usedTokenService := NewUsedTokenService(&memoryUsedTokenRepository{tokens: map[string]bool{}})
+	config := &MagicLinkConfig{Enabled: true, IncludeCode: true}
+	return NewMagicLinkService(config, userService, authService, otpService, nil, nil, usedTokenService)
+}
+
+// sendMagicLink issues the link and its code the way SendMagicLink does, without rendering the email.
+func sendMagicLink(t *testing.T, service *MagicLinkService) string {
+	t.Helper()
+	user, _ := service.UserService.GetUserByID(context.Background(), testSelfUserID)
+	token, tokenID, expiresAt, err := service.issueMagicLinkToken(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.OtpService.GenerateLinkedOtp(user.ID, PurposeMagicLink, tokenID, expiresAt)
+	return token
+}
+
+func TestMagicLinkCodeInvalidatesLink(t *testing.T) {
+	service := newTestMagicLinkService()
+	token := sendMagicLink(t, service)
+	if _, err := service.LoginWithCode(context.Background(), testMagicLinkEmail, "123456", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.LoginWithToken(context.Background(), token, "", time.Hour); !errors.Is(err, security.MagicLinkAlreadyUsed) {
+		t.Fatalf("the link must be used once its code was redeemed, got %v", err)
+	}
+}
+
+func TestMagicLinkInvalidatesCode(t *testing.T) {
+	service := newTestMagicLinkService()
+	token := sendMagicLink(t, service)
+	if _, err := service.LoginWithToken(context.Background(), token, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.LoginWithCode(context.Background(), testMagicLinkEmail, "123456", time.Hour); !errors.Is(err, security.OtpNotFound) {
+		t.Fatalf("the code must be removed once its link was redeemed, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryEmailSender keeps sent emails in an in-memory outbox so tests can inspect them.
+type MemoryEmailSender struct {
+	outbox []*Email
+	lock   sync.RWMutex
+	// SendError, when set, is returned by Send instead of capturing the email.
+	SendError error
+}
+
+func NewMemoryEmailSender() *MemoryEmailSender {
+	return &MemoryEmailSender{}
+}
+
+func (sender *MemoryEmailSender) Send(ctx context.Context, email *Email) error {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	if sender.SendError != nil {
+		return sender.SendError
+	}
+	sender.outbox = append(sender.outbox, email)
+	return nil
+}
+
+func (sender *MemoryEmailSender) Outbox() []*Email {
+	sender.lock.RLock()
+	defer sender.lock.RUnlock()
+	outbox := make([]*Email, len(sender.outbox))
+	copy(outbox, sender.outbox)
+	return outbox
+}
+
+func (sender *MemoryEmailSender) FindByRecipient(to string) []*Email {
+	sender.lock.RLock()
+	defer sender.lock.RUnlock()
+	var emails []*Email
+	for _, email := range sender.outbox {
+		if email.To == to {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+func (sender *MemoryEmailSender) LastEmail() *Email {
+	sender.lock.RLock()
+	defer sender.lock.RUnlock()
+	if len(sender.outbox) == 0 {
+		return nil
+	}
+	return sender.outbox[len(sender.outbox)-1]
+}
+
+func (sender *MemoryEmailSender) Reset() {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	sender.outbox = nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-security/security"
+	. "go-security/security/repository"
+	"sync"
+	"time"
+)
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "email"
+	NotificationChannelSms   NotificationChannel = "sms"
+	NotificationChannelLine  NotificationChannel = "line"
+)
+
+type SecurityEvent string
+
+const (
+	SecurityEventNewLogin        SecurityEvent = "new_login"
+	SecurityEventPasswordChanged SecurityEvent = "password_changed"
+	SecurityEventMfaDisabled     SecurityEvent = "mfa_disabled"
+)
+
+type Notification struct {
+	Event      SecurityEvent
+	Title      string
+	Message    string
+	Link       string
+	LinkLabel  string
+	OccurredAt time.Time
+}
+
+// Notifier delivers a notification to a user over one channel. Notifiers return
+// security.NotificationChannelUnavailable when the user cannot be reached on their channel.
+type Notifier interface {
+	Channel() NotificationChannel
+	Notify(ctx context.Context, user *User, notification *Notification) error
+}
+
+// NewSecurityNotification builds the notification of an event, the request metadata describes where it came from.
+func NewSecurityNotification(event SecurityEvent, metadata *RequestMetadata) *Notification {
+	notification := &Notification{Event: event, OccurredAt: time.Now()}
+	occurredAt := notification.OccurredAt.Format(time.RFC1123)
+	switch event {
+	case SecurityEventNewLogin:
+		notification.Title = "New sign-in to your account"
+		notification.Message = fmt.Sprintf("Your account was signed in from %s (%s) at %s.", metadata.IPAddress, metadata.UserAgent, occurredAt)
+	case SecurityEventPasswordChanged:
+		notification.Title = "Your password was changed"
+		notification.Message = fmt.Sprintf("The password of your account was changed at %s. If this wasn't you, reset your password immediately.", occurredAt)
+	case SecurityEventMfaDisabled:
+		notification.Title = "Two-factor authentication disabled"
+		notification.Message = fmt.Sprintf("Two-factor authentication was disabled on your account at %s. If this wasn't you, secure your account immediately.", occurredAt)
+	}
+	return notification
+}
+
+// NotificationService fans security notifications out to the channels enabled by each user. Delivery
+// runs in the background so a slow channel never delays the request that triggered the event.
+type NotificationService struct {
+	UserService          *UserService
+	PreferenceRepository INotificationPreferenceRepository
+	Notifiers            map[NotificationChannel]Notifier
+	waitGroup            sync.WaitGroup
+}
+
+func NewNotificationService(userService *UserService, preferenceRepository INotificationPreferenceRepository, notifiers ...Notifier) *NotificationService {
+	service := &NotificationService{
+		UserService:          userService,
+		PreferenceRepository: preferenceRepository,
+		Notifiers:            make(map[NotificationChannel]Notifier),
+	}
+	for _, notifier := range notifiers {
+		service.Notifiers[notifier.Channel()] = notifier
+	}
+	return service
+}
+
+func (service *NotificationService) PostConstruct() {}
+
+// defaultPreferences enables email for everyone and LINE for users who signed in with LINE.
+func (service *NotificationService) defaultPreferences(user *User) map[NotificationChannel]bool {
+	return map[NotificationChannel]bool{
+		NotificationChannelEmail: true,
+		NotificationChannelSms:   false,
+		NotificationChannelLine:  user.Platform.Name == string(PlatformLine),
+	}
+}
+
+// PreDestroy waits for the notifications in flight.
+func (service *NotificationService) PreDestroy(ctx context.Context) error {
+	return waitUntilDone(ctx, service.Wait)
+}
+
+func (service *NotificationService) GetPreferences(ctx context.Context, userID uint) (map[NotificationChannel]bool, error) {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := service.defaultPreferences(user)
+	stored, err := service.PreferenceRepository.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, preference := range stored {
+		preferences[NotificationChannel(preference.Channel)] = preference.Enabled
+	}
+	return preferences, nil
+}
+
+func (service *NotificationService) UpdatePreference(ctx context.Context, userID uint, channel NotificationChannel, enabled bool) error {
+	switch channel {
+	case NotificationChannelEmail, NotificationChannelSms, NotificationChannelLine:
+	default:
+		return security.DeliveryChannelNotSupported
+	}
+	return service.PreferenceRepository.Upsert(ctx, &NotificationPreference{
+		UserID:  userID,
+		Channel: string(channel),
+		Enabled: enabled,
+	})
+}
+
+func (service *NotificationService) NotifyUser(ctx context.Context, userID uint, notification *Notification) {
+	ctx = context.WithoutCancel(ctx)
+	service.waitGroup.Add(1)
+	go func() {
+		defer service.waitGroup.Done()
+		service.notifyUser(ctx, userID, notification)
+	}()
+}
+
+func (service *NotificationService) notifyUser(ctx context.Context, userID uint, notification *Notification) {
+	preferences, err := service.GetPreferences(ctx, userID)
+	if err != nil {
+		LoggerFromContext(ctx).Error().Err(err).Msgf("Failed to load notification preferences of user %d", userID)
+		return
+	}
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	for channel, enabled := range preferences {
+		notifier, ok := service.Notifiers[channel]
+		if !enabled || !ok {
+			continue
+		}
+		if err := notifier.Notify(ctx, user, notification); err != nil {
+			LoggerFromContext(ctx).Warn().Err(err).Msgf("Failed to notify user %d of %s through %s", userID, notification.Event, channel)
+		}
+	}
+}
+
+// Wait blocks until the notifications in flight are delivered.
+func (service *NotificationService) Wait() {
+	service.waitGroup.Wait()
+}
+
+type EmailNotifier struct {
+	SmtpService      ISmtpService
+	TemplateRegistry *TemplateRegistry
+}
+
+func NewEmailNotifier(smtpService ISmtpService, templateRegistry *TemplateRegistry) *EmailNotifier {
+	return &EmailNotifier{
+		SmtpService:      smtpService,
+		TemplateRegistry: templateRegistry,
+	}
+}
+
+func (notifier *EmailNotifier) Channel() NotificationChannel {
+	return NotificationChannelEmail
+}
+
+func (notifier *EmailNotifier) Notify(ctx context.Context, user *User, notification *Notification) error {
+	emailTemplate := NewEmailTemplate(user.Name, "", notifier.SmtpService.GetSmtpConfig().CompanyName).
+		WithLocale(user.Locale).
+		WithLink(notification.Link)
+	if len(notification.LinkLabel) > 0 {
+		emailTemplate.WithVariable("link_label", notification.LinkLabel)
+	}
+	emailTemplate.Title = notification.Title
+	emailTemplate.Message = notification.Message
+	rendered, err := notifier.TemplateRegistry.Render(TemplateSecurityNotification, emailTemplate)
+	if err != nil {
+		return err
+	}
+	return notifier.SmtpService.SendEmail(ctx, notifier.SmtpService.CreateNewTemplatedMessage(user.Email, rendered))
+}
+
+type SmsNotifier struct {
+	SmsSender   SmsSender
+	CompanyName string
+}
+
+func NewSmsNotifier(smsSender SmsSender, companyName string) *SmsNotifier {
+	return &SmsNotifier{
+		SmsSender:   smsSender,
+		CompanyName: companyName,
+	}
+}
+
+func (notifier *SmsNotifier) Channel() NotificationChannel {
+	return NotificationChannelSms
+}
+
+func (notifier *SmsNotifier) Notify(ctx context.Context, user *User, notification *Notification) error {
+	if user.PhoneNumber == nil || !user.IsPhoneVerified {
+		return security.NotificationChannelUnavailable
+	}
+	return notifier.SmsSender.SendSms(ctx, *user.PhoneNumber, fmt.Sprintf("%s: %s", notifier.CompanyName, notification.Message))
+}
@@ -30,7 +30,7 @@ func (ctx *GoogleUser) FullName() string {
 
 type GoogleAuthConfig struct {
 	ClientID     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
+	ClientSecret string `yaml:"client_secret" json:"-" secret:"true"`
 }
 
 type GoogleAuthService struct {
@@ -76,7 +76,8 @@ func (service *GoogleAuthService) RegisterAndLogin(ctx context.Context, user *Go
 		}
 	}
 	// Issue a login token for the user.
-	token, err := service.AuthService.IssueLoginToken(targetUser, expirationTime)
+	token, err := service.AuthService.IssueLoginToken(ctx, targetUser, expirationTime)
+	service.AuthService.RecordLogin(ctx, targetUser.ID, "google", err)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-security/security"
+	. "go-security/security/repository"
+)
+
+type DeliveryChannel string
+
+const (
+	DeliveryChannelEmail DeliveryChannel = "email"
+	DeliveryChannelSms   DeliveryChannel = "sms"
+)
+
+var otpEmailTemplates = map[Purpose]TemplateName{
+	PurposeGuestEmailVerification: TemplateEmailVerification,
+	PurposeResetPassword:          TemplateResetPassword,
+	PurposeMfa:                    TemplateMfaCode,
+}
+
+var otpSmsLabels = map[Purpose]string{
+	PurposeResetPassword:     "password reset",
+	PurposePhoneVerification: "phone verification",
+	PurposeMfa:               "sign-in",
+}
+
+// OtpDeliveryService generates an OTP and delivers it over the channel chosen by the user.
+// Email verification only goes by email and phone verification only by SMS, as the code proves
+// ownership of the address it is sent to; other purposes need a verified phone number for SMS.
+type OtpDeliveryService struct {
+	SmtpService      ISmtpService
+	SmsSender        SmsSender
+	TemplateRegistry *TemplateRegistry
+	OtpService       *OtpService
+}
+
+func NewOtpDeliveryService(smtpService ISmtpService, smsSender SmsSender, templateRegistry *TemplateRegistry, otpService *OtpService) *OtpDeliveryService {
+	return &OtpDeliveryService{
+		SmtpService:      smtpService,
+		SmsSender:        smsSender,
+		TemplateRegistry: templateRegistry,
+		OtpService:       otpService,
+	}
+}
+
+func (service *OtpDeliveryService) PostConstruct() {}
+
+func (service *OtpDeliveryService) DeliverOtp(ctx context.Context, user *User, purpose Purpose, channel DeliveryChannel) (*OTP, error) {
+	if len(channel) == 0 {
+		channel = DeliveryChannelEmail
+	}
+	switch channel {
+	case DeliveryChannelEmail:
+		templateName, ok := otpEmailTemplates[purpose]
+		if !ok {
+			return nil, security.DeliveryChannelNotSupported
+		}
+		otp := service.OtpService.GenerateOtp(user.ID, purpose)
+		return otp, service.sendEmail(ctx, user, templateName, otp, "")
+	case DeliveryChannelSms:
+		if _, ok := otpSmsLabels[purpose]; !ok {
+			return nil, security.DeliveryChannelNotSupported
+		}
+		if user.PhoneNumber == nil {
+			return nil, security.PhoneNumberNotAllowed
+		}
+		if purpose != PurposePhoneVerification && !user.IsPhoneVerified {
+			return nil, security.PhoneNumberNotVerified
+		}
+		otp := service.OtpService.GenerateOtp(user.ID, purpose)
+		return otp, service.sendSms(ctx, *user.PhoneNumber, purpose, otp)
+	default:
+		return nil, security.DeliveryChannelNotSupported
+	}
+}
+
+// DeliverOtpWithLink emails the code along with the link of the page where it is entered.
+func (service *OtpDeliveryService) DeliverOtpWithLink(ctx context.Context, user *User, purpose Purpose, link string) (*OTP, error) {
+	templateName, ok := otpEmailTemplates[purpose]
+	if !ok {
+		return nil, security.DeliveryChannelNotSupported
+	}
+	otp := service.OtpService.GenerateOtp(user.ID, purpose)
+	return otp, service.sendEmail(ctx, user, templateName, otp, link)
+}
+
+func (service *OtpDeliveryService) sendEmail(ctx context.Context, user *User, templateName TemplateName, otp *OTP, link string) error {
+	emailTemplate := NewEmailTemplate(user.Name, otp.Code, service.SmtpService.GetSmtpConfig().CompanyName).WithLocale(user.Locale).WithLink(link)
+	rendered, err := service.TemplateRegistry.Render(templateName, emailTemplate)
+	if err != nil {
+		return err
+	}
+	return service.SmtpService.SendEmail(ctx, service.SmtpService.CreateNewTemplatedMessage(user.Email, rendered))
+}
+
+func (service *OtpDeliveryService) sendSms(ctx context.Context, phoneNumber string, purpose Purpose, otp *OTP) error {
+	message := fmt.Sprintf("%s: your %s code is %s, it expires in 5 minutes.",
+		service.SmtpService.GetSmtpConfig().CompanyName, otpSmsLabels[purpose], otp.Code)
+	return service.SmsSender.SendSms(ctx, phoneNumber, message)
+}
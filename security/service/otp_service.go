@@ -1,18 +1,31 @@
 package service
 
 import (
+	"context"
+	"crypto/subtle"
 	"fmt"
 	"go-security/security"
+	"go-security/security/metrics"
 	"math/rand"
 	"sync"
 	"time"
 )
 
+// OtpMaxFailedAttempts is how many incorrect guesses a code survives before it is removed.
+const OtpMaxFailedAttempts = 5
+
+// otpHealthCheckPollInterval is how often CheckHealth retries the lock of the OTP store.
+const otpHealthCheckPollInterval = 10 * time.Millisecond
+
 type Purpose string
 
 const (
 	PurposeGuestEmailVerification Purpose = "guest_email_verification"
 	PurposeResetPassword          Purpose = "reset_password"
+	PurposePhoneVerification      Purpose = "phone_verification"
+	PurposeMfa                    Purpose = "mfa"
+	PurposeMagicLink              Purpose = "magic_link"
+	PurposeLoginReport            Purpose = "login_report"
 )
 
 func GenerateOtpCode() string {
@@ -28,6 +41,10 @@ type OTP struct {
 	Purpose        Purpose
 	Code           string
 	ExpirationTime int64
+	FailedAttempts int
+	// TokenID, when set, is the single-use token sent along with the code, redeeming either invalidates the other.
+	TokenID        string
+	TokenExpiresAt time.Time
 }
 
 type OtpService struct {
@@ -53,21 +70,26 @@ func (service *OtpService) generateOtp() string {
 }
 
 func (service *OtpService) GenerateOtp(userId uint, purpose Purpose) *OTP {
-	code := service.OtpGeneratorFunc()
-	otp := &OTP{
-		UserId:         userId,
-		Purpose:        purpose,
-		Code:           code,
-		ExpirationTime: time.Now().Add(time.Minute * 5).Unix(),
-	}
+	return service.storeOtp(&OTP{UserId: userId, Purpose: purpose})
+}
+
+// GenerateLinkedOtp generates a code sent along with the single-use token tokenID, see ConsumeLinkedOtp.
+func (service *OtpService) GenerateLinkedOtp(userId uint, purpose Purpose, tokenID string, tokenExpiresAt time.Time) *OTP {
+	return service.storeOtp(&OTP{UserId: userId, Purpose: purpose, TokenID: tokenID, TokenExpiresAt: tokenExpiresAt})
+}
+
+func (service *OtpService) storeOtp(otp *OTP) *OTP {
+	otp.Code = service.OtpGeneratorFunc()
+	otp.ExpirationTime = time.Now().Add(time.Minute * 5).Unix()
 
 	service.OtpLock.Lock()
 	defer service.OtpLock.Unlock()
 
-	if service.OtpCache[userId] == nil {
-		service.OtpCache[userId] = make(map[Purpose]*OTP)
+	if service.OtpCache[otp.UserId] == nil {
+		service.OtpCache[otp.UserId] = make(map[Purpose]*OTP)
 	}
-	service.OtpCache[userId][purpose] = otp
+	service.OtpCache[otp.UserId][otp.Purpose] = otp
+	metrics.OtpIssued.WithLabelValues(string(otp.Purpose)).Inc()
 	return otp
 }
 
@@ -88,18 +110,83 @@ func (service *OtpService) mustGetOtp(userId uint, purpose Purpose) (*OTP, error
 	return otp, nil
 }
 
+// CheckHealth reports the OTP store as down when its lock cannot be acquired in time, e.g. after a deadlock.
+// The lock is polled, a probe never leaves a goroutine blocked on it.
+func (service *OtpService) CheckHealth(ctx context.Context) error {
+	ticker := time.NewTicker(otpHealthCheckPollInterval)
+	defer ticker.Stop()
+	for !service.OtpLock.TryLock() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	service.OtpLock.Unlock()
+	return nil
+}
+
 func (service *OtpService) GetOtp(userId uint, purpose Purpose) (*OTP, error) {
 	return service.mustGetOtp(userId, purpose)
 }
 
+// VerifyOtp checks the code without removing it, a code is removed after OtpMaxFailedAttempts incorrect guesses.
 func (service *OtpService) VerifyOtp(userId uint, purpose Purpose, code string) error {
-	cachedOtp, err := service.mustGetOtp(userId, purpose)
-	if err != nil {
-		return err
+	_, err := service.matchOtp(userId, purpose, code, false)
+	metrics.OtpVerified.WithLabelValues(string(purpose), metrics.Outcome(err)).Inc()
+	return err
+}
+
+// ConsumeOtp verifies the code like VerifyOtp, additionally rejecting expired codes, and removes it
+// once it matched so the same code cannot be used twice.
+func (service *OtpService) ConsumeOtp(userId uint, purpose Purpose, code string) error {
+	_, err := service.ConsumeLinkedOtp(userId, purpose, code)
+	return err
+}
+
+// ConsumeLinkedOtp consumes the code like ConsumeOtp and returns it, so the caller can invalidate the
+// token it was sent along with.
+func (service *OtpService) ConsumeLinkedOtp(userId uint, purpose Purpose, code string) (*OTP, error) {
+	otp, err := service.matchOtp(userId, purpose, code, true)
+	metrics.OtpVerified.WithLabelValues(string(purpose), metrics.Outcome(err)).Inc()
+	return otp, err
+}
+
+// matchOtp compares the code and counts the failure, or removes the code when consume is set, under a
+// single lock so concurrent guesses cannot both redeem it.
+func (service *OtpService) matchOtp(userId uint, purpose Purpose, code string, consume bool) (*OTP, error) {
+	service.OtpLock.Lock()
+	defer service.OtpLock.Unlock()
+
+	otp, ok := service.OtpCache[userId][purpose]
+	if !ok {
+		return nil, security.OtpNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(otp.Code), []byte(code)) != 1 {
+		otp.FailedAttempts++
+		if otp.FailedAttempts >= OtpMaxFailedAttempts {
+			delete(service.OtpCache[userId], purpose)
+			return nil, security.OtpAttemptsExceeded
+		}
+		return nil, security.OtpIncorrect
+	}
+	if !consume {
+		return otp, nil
 	}
+	delete(service.OtpCache[userId], purpose)
+	if otp.ExpirationTime < time.Now().Unix() {
+		return nil, security.OtpExpired
+	}
+	return otp, nil
+}
 
-	if cachedOtp.Code != code {
-		return security.OtpIncorrect
+// RemoveLinkedOtp removes the code sent along with the token tokenID once the token was redeemed, a
+// code sent along with another token is kept.
+func (service *OtpService) RemoveLinkedOtp(userId uint, purpose Purpose, tokenID string) {
+	service.OtpLock.Lock()
+	defer service.OtpLock.Unlock()
+
+	if otp, ok := service.OtpCache[userId][purpose]; ok && otp.TokenID == tokenID {
+		delete(service.OtpCache[userId], purpose)
 	}
-	return nil
 }
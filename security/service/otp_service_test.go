@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"go-security/security"
+	"sync"
+	"testing"
+)
+
+func TestOtpRemovedAfterMaxFailedAttempts(t *testing.T) {
+	service := NewOtpService(func() string { return "123456" })
+	service.GenerateOtp(1, PurposeResetPassword)
+	for i := 1; i < OtpMaxFailedAttempts; i++ {
+		if err := service.ConsumeOtp(1, PurposeResetPassword, "000000"); !errors.Is(err, security.OtpIncorrect) {
+			t.Fatalf("guess %d: got %v", i, err)
+		}
+	}
+	if err := service.VerifyOtp(1, PurposeResetPassword, "000000"); !errors.Is(err, security.OtpAttemptsExceeded) {
+		t.Fatalf("last guess: got %v", err)
+	}
+	if err := service.ConsumeOtp(1, PurposeResetPassword, "123456"); !errors.Is(err, security.OtpNotFound) {
+		t.Fatalf("the code must be removed after %d misses, got %v", OtpMaxFailedAttempts, err)
+	}
+}
+
+func TestOtpConsumedOnce(t *testing.T) {
+	service := NewOtpService(func() string { return "123456" })
+	service.GenerateOtp(1, PurposeResetPassword)
+	var redeemed sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < cap(results); i++ {
+		redeemed.Add(1)
+		go func() {
+			defer redeemed.Done()
+			results <- service.ConsumeOtp(1, PurposeResetPassword, "123456")
+		}()
+	}
+	redeemed.Wait()
+	close(results)
+	consumed := 0
+	for err := range results {
+		if err == nil {
+			consumed++
+		} else if !errors.Is(err, security.OtpNotFound) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if consumed != 1 {
+		t.Fatalf("expected the code to be consumed once, got %d", consumed)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type requestMetadataKey struct{}
+
+type requestIDKey struct{}
+
+type requestLoggerKey struct{}
+
+// RequestMetadata describes the client of the request a service call originates from.
+type RequestMetadata struct {
+	IPAddress string
+	UserAgent string
+	ActorID   *uint // The authenticated user, or their impersonator, set by the auth middleware
+}
+
+func ContextWithRequestMetadata(ctx context.Context, metadata *RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, metadata)
+}
+
+// RequestMetadataFromContext never returns nil, calls outside of a request get empty metadata.
+func RequestMetadataFromContext(ctx context.Context) *RequestMetadata {
+	if metadata, ok := ctx.Value(requestMetadataKey{}).(*RequestMetadata); ok {
+		return metadata
+	}
+	return &RequestMetadata{}
+}
+
+// ContextWithRequestID tags the context, and the logger it carries, with the ID correlating a request
+// to the logs and background work it causes.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	logger := LoggerFromContext(ctx).With().Str("request_id", requestID).Logger()
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	return context.WithValue(ctx, requestLoggerKey{}, &logger)
+}
+
+// RequestIDFromContext returns an empty string outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// LoggerFromContext returns the logger of the request in the context, or the global logger outside of a request.
+func LoggerFromContext(ctx context.Context) *zerolog.Logger {
+	if logger, ok := ctx.Value(requestLoggerKey{}).(*zerolog.Logger); ok {
+		return logger
+	}
+	return &log.Logger
+}
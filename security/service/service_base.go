@@ -1,5 +1,33 @@
 package service
 
+import "context"
+
 type IService interface {
 	PostConstruct()
 }
+
+// IPreDestroyer is implemented by services holding resources to release on shutdown.
+type IPreDestroyer interface {
+	// PreDestroy releases the resources of the service, it should return once the context is done.
+	PreDestroy(ctx context.Context) error
+}
+
+// IReadinessAware is implemented by services following whether the application accepts traffic.
+type IReadinessAware interface {
+	SetReady(ready bool)
+}
+
+// waitUntilDone runs the blocking call, giving up when the context is done first.
+func waitUntilDone(ctx context.Context, wait func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
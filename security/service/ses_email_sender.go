@@ -0,0 +1,73 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+type SesConfig struct {
+	Region           string `yaml:"region"`
+	AccessKeyID      string `yaml:"access_key_id"`
+	SecretAccessKey  string `yaml:"secret_access_key" json:"-" secret:"true"`
+	ConfigurationSet string `yaml:"configuration_set"`
+}
+
+// SesClient is the subset of the SES v2 client used by SesEmailSender, so it can be stubbed in tests.
+type SesClient interface {
+	SendEmail(ctx context.Context, input *sesv2.SendEmailInput, optFns ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error)
+}
+
+type SesEmailSender struct {
+	Client           SesClient
+	ConfigurationSet string
+}
+
+func NewSesEmailSender(client SesClient, configurationSet string) *SesEmailSender {
+	return &SesEmailSender{
+		Client:           client,
+		ConfigurationSet: configurationSet,
+	}
+}
+
+// NewSesEmailSenderFromConfig uses static credentials when given, otherwise the default AWS credential chain.
+func NewSesEmailSenderFromConfig(config *SesConfig) (*SesEmailSender, error) {
+	if config == nil {
+		config = &SesConfig{}
+	}
+	var options []func(*awsconfig.LoadOptions) error
+	if len(config.Region) > 0 {
+		options = append(options, awsconfig.WithRegion(config.Region))
+	}
+	if len(config.AccessKeyID) > 0 {
+		options = append(options, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AccessKeyID, config.SecretAccessKey, ""),
+		))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSesEmailSender(sesv2.NewFromConfig(awsConfig), config.ConfigurationSet), nil
+}
+
+func (sender *SesEmailSender) Send(ctx context.Context, email *Email) error {
+	var buffer bytes.Buffer
+	if _, err := email.WriteTo(&buffer); err != nil {
+		return err
+	}
+	input := &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(email.From),
+		Destination:      &types.Destination{ToAddresses: []string{email.To}},
+		Content:          &types.EmailContent{Raw: &types.RawMessage{Data: buffer.Bytes()}},
+	}
+	if len(sender.ConfigurationSet) > 0 {
+		input.ConfigurationSetName = aws.String(sender.ConfigurationSet)
+	}
+	_, err := sender.Client.SendEmail(ctx, input)
+	return err
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"go-security/security"
+	. "go-security/security/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AuditActionSessionRevoked AuditAction = "session_revoked"
+	AuditActionLoginReported  AuditAction = "login_reported"
+	AuditActionSessionEvicted AuditAction = "session_evicted"
+	// sessionLastSeenResolution throttles the last-seen updates, a session is not written on every request.
+	sessionLastSeenResolution = time.Minute
+)
+
+// SessionService keeps track of the logins of each user, a login token is only valid while its session is.
+type SessionService struct {
+	Repository            ISessionRepository
+	KnownDeviceRepository IKnownDeviceRepository
+	AuditService          *AuditService
+}
+
+func NewSessionService(repository ISessionRepository, knownDeviceRepository IKnownDeviceRepository, auditService *AuditService) *SessionService {
+	return &SessionService{
+		Repository:            repository,
+		KnownDeviceRepository: knownDeviceRepository,
+		AuditService:          auditService,
+	}
+}
+
+func (service *SessionService) PostConstruct() {}
+
+// CreateSession records a login of the user from the client of the request in the context, making room
+// for it within the session limit of their role. Depending on the policy of the role, the oldest sessions
+// are evicted or the login is rejected with SessionLimitReached. The limit is checked and the session
+// inserted under a lock on the user, concurrent logins cannot both take the last slot.
+func (service *SessionService) CreateSession(ctx context.Context, user *User, expiration time.Duration) (*Session, error) {
+	if user.Role.MaxSessions == 0 {
+		return service.createSession(ctx, service.Repository, user, nil, expiration)
+	}
+	var session *Session
+	err := service.Repository.LockUser(ctx, user.ID, func(repo ISessionRepository) error {
+		if err := service.enforceSessionLimit(ctx, repo, user); err != nil {
+			return err
+		}
+		var err error
+		session, err = service.createSession(ctx, repo, user, nil, expiration)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// CreateImpersonationSession records a session of the impersonator acting as the user.
+func (service *SessionService) CreateImpersonationSession(ctx context.Context, user *User, impersonatorID uint, expiration time.Duration) (*Session, error) {
+	return service.createSession(ctx, service.Repository, user, &impersonatorID, expiration)
+}
+
+func (service *SessionService) createSession(ctx context.Context, repo ISessionRepository, user *User, impersonatorID *uint, expiration time.Duration) (*Session, error) {
+	metadata := RequestMetadataFromContext(ctx)
+	now := time.Now()
+	session := &Session{
+		ID:             uuid.NewString(),
+		UserID:         user.ID,
+		Platform:       user.Platform.Name,
+		Device:         DescribeDevice(metadata.UserAgent),
+		UserAgent:      metadata.UserAgent,
+		IPAddress:      metadata.IPAddress,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(expiration),
+		ImpersonatorID: impersonatorID,
+	}
+	if err := repo.Save(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (service *SessionService) enforceSessionLimit(ctx context.Context, repo ISessionRepository, user *User) error {
+	active, err := repo.FindActiveByUserID(ctx, user.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	// Impersonation sessions belong to support staff, they neither count towards the limit nor get evicted.
+	sessions := make([]*Session, 0, len(active))
+	for _, session := range active {
+		if session.ImpersonatorID == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	excess := len(sessions) - int(user.Role.MaxSessions) + 1
+	if excess <= 0 {
+		return nil
+	}
+	if user.Role.SessionLimitPolicy == SessionLimitPolicyReject {
+		return security.SessionLimitReached
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	for _, session := range sessions[:excess] {
+		if err := service.revokeWith(ctx, repo, session, AuditActionSessionEvicted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateSession returns the session if it is still active and refreshes its last-seen timestamp.
+func (service *SessionService) ValidateSession(ctx context.Context, id string) (*Session, error) {
+	session, err := service.Repository.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if session.RevokedAt != nil {
+		return nil, security.SessionRevoked
+	}
+	if !session.IsActive(now) {
+		return nil, security.SessionExpired
+	}
+	if now.Sub(session.LastSeenAt) >= sessionLastSeenResolution {
+		if err := service.Repository.UpdateLastSeen(ctx, session.ID, now); err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = now
+	}
+	return session, nil
+}
+
+// ExtendSession keeps the session alive for as long as the token reissued for it. Only the expiration is
+// written, so a revocation racing the refresh is not overwritten.
+func (service *SessionService) ExtendSession(ctx context.Context, session *Session, expiration time.Duration) error {
+	expiresAt := time.Now().Add(expiration)
+	if err := service.Repository.Extend(ctx, session.ID, expiresAt); err != nil {
+		return err
+	}
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+func (service *SessionService) GetActiveSessions(ctx context.Context, userID uint) ([]*Session, error) {
+	return service.Repository.FindActiveByUserID(ctx, userID, time.Now())
+}
+
+// GetLoginHistory returns the successful and failed logins of the user, most recent first.
+func (service *SessionService) GetLoginHistory(ctx context.Context, userID uint, page int, pageSize int) (*Page[*AuditEvent], error) {
+	filter := &AuditEventFilter{SubjectID: &userID, Action: string(AuditActionLogin)}
+	return service.AuditService.GetEvents(ctx, filter, page, pageSize)
+}
+
+// RevokeUserSession revokes a session of the user, sessions of other users are reported as not found.
+func (service *SessionService) RevokeUserSession(ctx context.Context, userID uint, id string) error {
+	session, err := service.Repository.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return security.SessionNotFound
+	}
+	return service.revoke(ctx, session, AuditActionSessionRevoked)
+}
+
+func (service *SessionService) RevokeSession(ctx context.Context, id string) error {
+	session, err := service.Repository.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.revoke(ctx, session, AuditActionSessionRevoked)
+}
+
+// RevokeOtherSessions revokes the active sessions of the user except keptID, an empty keptID revokes them all.
+func (service *SessionService) RevokeOtherSessions(ctx context.Context, userID uint, keptID string) error {
+	sessions, err := service.GetActiveSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == keptID {
+			continue
+		}
+		if err := service.revoke(ctx, session, AuditActionSessionRevoked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeviceFingerprint identifies the device of a session by its user agent and IP address.
+func DeviceFingerprint(session *Session) string {
+	sum := sha256.Sum256([]byte(session.UserAgent + "\n" + session.IPAddress))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecognizeDevice remembers the device of the session and reports whether it is new to the user.
+// The first device of a user is trusted without being reported.
+func (service *SessionService) RecognizeDevice(ctx context.Context, session *Session) (bool, error) {
+	known, err := service.KnownDeviceRepository.CountByUserID(ctx, session.UserID)
+	if err != nil {
+		return false, err
+	}
+	created, err := service.KnownDeviceRepository.Register(ctx, &KnownDevice{
+		UserID:      session.UserID,
+		Fingerprint: DeviceFingerprint(session),
+		Device:      session.Device,
+		UserAgent:   session.UserAgent,
+		IPAddress:   session.IPAddress,
+		LastSeenAt:  session.LastSeenAt,
+	})
+	if err != nil {
+		return false, err
+	}
+	return created && known > 0, nil
+}
+
+// ReportSession handles a session the user does not recognize: it is revoked and its device is forgotten,
+// so another login from it is reported again.
+func (service *SessionService) ReportSession(ctx context.Context, userID uint, id string) error {
+	session, err := service.Repository.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return security.SessionNotFound
+	}
+	err = service.KnownDeviceRepository.Delete(ctx, userID, DeviceFingerprint(session))
+	event := NewAuditEvent(AuditActionLoginReported, err)
+	event.SubjectID = AuditUserID(userID)
+	event.Metadata["session_id"] = session.ID
+	event.Metadata["ip_address"] = session.IPAddress
+	event.Metadata["user_agent"] = session.UserAgent
+	service.AuditService.Record(ctx, event)
+	if err != nil {
+		return err
+	}
+	return service.revoke(ctx, session, AuditActionSessionRevoked)
+}
+
+func (service *SessionService) revoke(ctx context.Context, session *Session, action AuditAction) error {
+	return service.revokeWith(ctx, service.Repository, session, action)
+}
+
+func (service *SessionService) revokeWith(ctx context.Context, repo ISessionRepository, session *Session, action AuditAction) error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+	err := repo.Revoke(ctx, session.ID, time.Now())
+	event := NewAuditEvent(action, err)
+	event.SubjectID = AuditUserID(session.UserID)
+	event.Metadata["session_id"] = session.ID
+	service.AuditService.Record(ctx, event)
+	return err
+}
+
+var deviceBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Line/", "LINE"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+var deviceSystems = []struct{ token, name string }{
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// DescribeDevice summarizes a user agent as "<browser> on <system>", falling back to the raw value.
+func DescribeDevice(userAgent string) string {
+	browser, system := "", ""
+	for _, candidate := range deviceBrowsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	for _, candidate := range deviceSystems {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.name
+			break
+		}
+	}
+	switch {
+	case len(browser) > 0 && len(system) > 0:
+		return browser + " on " + system
+	case len(browser) > 0:
+		return browser
+	case len(system) > 0:
+		return system
+	case len(userAgent) > 100:
+		return userAgent[:100]
+	}
+	return userAgent
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"go-security/security/repository"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySessionRepository keeps copies of the sessions, as they would be read back from the database.
+type memorySessionRepository struct {
+	sessions map[string]repository.Session
+	lock     sync.Mutex
+	// lockedUsers counts the LockUser calls.
+	lockedUsers int
+}
+
+func newMemorySessionRepository() *memorySessionRepository {
+	return &memorySessionRepository{sessions: map[string]repository.Session{}}
+}
+
+func (repo *memorySessionRepository) LockUser(ctx context.Context, userID uint, fn func(repo repository.ISessionRepository) error) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	repo.lockedUsers++
+	return fn(&lockedSessionRepository{repo})
+}
+
+func (repo *memorySessionRepository) Save(ctx context.Context, session *repository.Session) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	return (&lockedSessionRepository{repo}).Save(ctx, session)
+}
+
+func (repo *memorySessionRepository) FindByID(ctx context.Context, id string) (*repository.Session, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	return (&lockedSessionRepository{repo}).FindByID(ctx, id)
+}
+
+func (repo *memorySessionRepository) FindActiveByUserID(ctx context.Context, userID uint, now time.Time) ([]*repository.Session, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	return (&lockedSessionRepository{repo}).FindActiveByUserID(ctx, userID, now)
+}
+
+func (repo *memorySessionRepository) UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	return (&lockedSessionRepository{repo}).UpdateLastSeen(ctx, id, lastSeenAt)
+}
+
+func (repo *memorySessionRepository) Extend(ctx context.Context, id string, expiresAt time.Time) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	return (&lockedSessionRepository{repo}).Extend(ctx, id, expiresAt)
+}
+
+func (repo *memorySessionRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+	return (&lockedSessionRepository{repo}).Revoke(ctx, id, revokedAt)
+}
+
+// lockedSessionRepository is the repository handed to LockUser, the lock is already held.
+type lockedSessionRepository struct {
+	*memorySessionRepository
+}
+
+func (repo *lockedSessionRepository) LockUser(ctx context.Context, userID uint, fn func(repo repository.ISessionRepository) error) error {
+	return fn(repo)
+}
+
+func (repo *lockedSessionRepository) Save(ctx context.Context, session *repository.Session) error {
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	repo.sessions[session.ID] = *session
+	return nil
+}
+
+func (repo *lockedSessionRepository) FindByID(ctx context.Context, id string) (*repository.Session, error) {
+	session, ok := repo.sessions[id]
+	if !ok {
+		return nil, security.SessionNotFound
+	}
+	return &session, nil
+}
+
+func (repo *lockedSessionRepository) FindActiveByUserID(ctx context.Context, userID uint, now time.Time) ([]*repository.Session, error) {
+	var active []*repository.Session
+	for _, session := range repo.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			active = append(active, &session)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].LastSeenAt.After(active[j].LastSeenAt) })
+	return active, nil
+}
+
+func (repo *lockedSessionRepository) UpdateLastSeen(ctx context.Context, id string, lastSeenAt time.Time) error {
+	return repo.update(id, func(session *repository.Session) { session.LastSeenAt = lastSeenAt })
+}
+
+func (repo *lockedSessionRepository) Extend(ctx context.Context, id string, expiresAt time.Time) error {
+	return repo.update(id, func(session *repository.Session) { session.ExpiresAt = expiresAt })
+}
+
+func (repo *lockedSessionRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	return repo.update(id, func(session *repository.Session) { session.RevokedAt = &revokedAt })
+}
+
+func (repo *lockedSessionRepository) update(id string, fn func(session *repository.Session)) error {
+	session, ok := repo.sessions[id]
+	if !ok {
+		return security.SessionNotFound
+	}
+	fn(&session)
+	repo.sessions[id] = session
+	return nil
+}
+
+type memoryKnownDeviceRepository struct {
+	devices map[uint]map[string]bool
+}
+
+func (repo *memoryKnownDeviceRepository) CountByUserID(ctx context.Context, userID uint) (int64, error) {
+	return int64(len(repo.devices[userID])), nil
+}
+
+func (repo *memoryKnownDeviceRepository) Register(ctx context.Context, device *repository.KnownDevice) (bool, error) {
+	if repo.devices == nil {
+		repo.devices = map[uint]map[string]bool{}
+	}
+	if repo.devices[device.UserID] == nil {
+		repo.devices[device.UserID] = map[string]bool{}
+	}
+	known := repo.devices[device.UserID][device.Fingerprint]
+	repo.devices[device.UserID][device.Fingerprint] = true
+	return !known, nil
+}
+
+func (repo *memoryKnownDeviceRepository) Delete(ctx context.Context, userID uint, fingerprint string) error {
+	delete(repo.devices[userID], fingerprint)
+	return nil
+}
+
+func newTestSessionService() (*SessionService, *memorySessionRepository) {
+	sessions := newMemorySessionRepository()
+	return NewSessionService(sessions, &memoryKnownDeviceRepository{}, nil), sessions
+}
+
+func newTestSessionUser(maxSessions uint, policy repository.SessionLimitPolicy) *repository.User {
+	return &repository.User{ID: testSelfUserID, Name: "self", Role: repository.UserRole{Name: RoleGuest, MaxSessions: maxSessions, SessionLimitPolicy: policy}}
+}
+
+func createSessions(t *testing.T, service *SessionService, user *repository.User, count int) []*repository.Session {
+	t.Helper()
+	sessions := make([]*repository.Session, 0, count)
+	for i := 0; i < count; i++ {
+		session, err := service.CreateSession(context.Background(), user, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func TestSessionLimitEvictsOldest(t *testing.T) {
+	service, _ := newTestSessionService()
+	ctx := context.Background()
+	user := newTestSessionUser(2, repository.SessionLimitPolicyEvictOldest)
+	sessions := createSessions(t, service, user, 3)
+
+	if _, err := service.ValidateSession(ctx, sessions[0].ID); !errors.Is(err, security.SessionRevoked) {
+		t.Fatalf("the oldest session must be evicted, got %v", err)
+	}
+	for _, session := range sessions[1:] {
+		if _, err := service.ValidateSession(ctx, session.ID); err != nil {
+			t.Fatalf("session %s must be kept: %v", session.ID, err)
+		}
+	}
+	if active, _ := service.GetActiveSessions(ctx, user.ID); len(active) != 2 {
+		t.Fatalf("expected 2 active sessions, got %d", len(active))
+	}
+}
+
+func TestSessionLimitRejects(t *testing.T) {
+	service, _ := newTestSessionService()
+	ctx := context.Background()
+	user := newTestSessionUser(2, repository.SessionLimitPolicyReject)
+	sessions := createSessions(t, service, user, 2)
+
+	if _, err := service.CreateSession(ctx, user, time.Hour); !errors.Is(err, security.SessionLimitReached) {
+		t.Fatalf("got %v", err)
+	}
+	for _, session := range sessions {
+		if _, err := service.ValidateSession(ctx, session.ID); err != nil {
+			t.Fatalf("a rejected login must not touch the existing sessions: %v", err)
+		}
+	}
+	// A revoked session frees its slot.
+	if err := service.RevokeSession(ctx, sessions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateSession(ctx, user, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionLimitIgnoresImpersonationSessions(t *testing.T) {
+	service, _ := newTestSessionService()
+	ctx := context.Background()
+	user := newTestSessionUser(1, repository.SessionLimitPolicyReject)
+	impersonation, err := service.CreateImpersonationSession(ctx, user, testSuperAdminID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createSessions(t, service, user, 1)
+	if _, err := service.ValidateSession(ctx, impersonation.ID); err != nil {
+		t.Fatalf("the impersonation session must be kept: %v", err)
+	}
+}
+
+func TestUnlimitedRoleSkipsSessionLimit(t *testing.T) {
+	service, sessions := newTestSessionService()
+	user := newTestSessionUser(0, repository.SessionLimitPolicyReject)
+	createSessions(t, service, user, 5)
+	if active, _ := service.GetActiveSessions(context.Background(), user.ID); len(active) != 5 {
+		t.Fatalf("expected 5 active sessions, got %d", len(active))
+	}
+	if sessions.lockedUsers != 0 {
+		t.Fatalf("an unlimited role must not lock the user, locked %d times", sessions.lockedUsers)
+	}
+}
+
+func TestSessionLimitHoldsUnderConcurrentLogins(t *testing.T) {
+	service, sessions := newTestSessionService()
+	user := newTestSessionUser(3, repository.SessionLimitPolicyReject)
+	var logins sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		logins.Add(1)
+		go func() {
+			defer logins.Done()
+			_, _ = service.CreateSession(context.Background(), user, time.Hour)
+		}()
+	}
+	logins.Wait()
+	if active, _ := service.GetActiveSessions(context.Background(), user.ID); len(active) != 3 {
+		t.Fatalf("expected the limit of 3 sessions, got %d", len(active))
+	}
+	if sessions.lockedUsers != 20 {
+		t.Fatalf("every login must count and create under the user lock, locked %d times", sessions.lockedUsers)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	auditService, auditRepository := newTestAuditService(t, 0)
+	service := NewSessionService(newMemorySessionRepository(), &memoryKnownDeviceRepository{}, auditService)
+	ctx := context.Background()
+	user := newTestSessionUser(0, repository.SessionLimitPolicyEvictOldest)
+	sessions := createSessions(t, service, user, 3)
+
+	if err := service.RevokeUserSession(ctx, testGoogleUserID, sessions[0].ID); !errors.Is(err, security.SessionNotFound) {
+		t.Fatalf("the session of another user must not be found, got %v", err)
+	}
+	if err := service.RevokeUserSession(ctx, user.ID, sessions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ValidateSession(ctx, sessions[0].ID); !errors.Is(err, security.SessionRevoked) {
+		t.Fatalf("got %v", err)
+	}
+	if event := auditRepository.last(); event.Action != string(AuditActionSessionRevoked) || event.Metadata["session_id"] != sessions[0].ID {
+		t.Fatalf("expected the revocation to be audited, got %+v", event)
+	}
+
+	if err := service.RevokeOtherSessions(ctx, user.ID, sessions[2].ID); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := service.GetActiveSessions(ctx, user.ID)
+	if len(active) != 1 || active[0].ID != sessions[2].ID {
+		t.Fatalf("expected only the kept session to be active, got %v", active)
+	}
+}
+
+func TestLoginHistory(t *testing.T) {
+	auditService, _ := newTestAuditService(t, 0)
+	sessionService := NewSessionService(newMemorySessionRepository(), &memoryKnownDeviceRepository{}, auditService)
+	authService := NewAuthService(nil, nil, auditService, nil, sessionService, nil, nil, "test-secret")
+	ctx := context.Background()
+	authService.RecordLogin(ctx, testSelfUserID, "password", security.UserPasswordNotMatched)
+	authService.RecordLogin(ctx, testGoogleUserID, "google", nil)
+	auditService.RecordUserAction(ctx, AuditActionSessionRevoked, testSelfUserID, nil)
+	authService.RecordLogin(ctx, testSelfUserID, "magic_link", nil)
+
+	history, err := sessionService.GetLoginHistory(ctx, testSelfUserID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.Total != 2 || len(history.Items) != 2 {
+		t.Fatalf("expected the 2 logins of the user only, got %d", history.Total)
+	}
+	latest, failed := history.Items[0], history.Items[1]
+	if latest.Metadata["method"] != "magic_link" || latest.Outcome != repository.AuditOutcomeSuccess {
+		t.Fatalf("expected the latest login first, got %+v", latest)
+	}
+	if failed.Metadata["method"] != "password" || failed.Outcome != repository.AuditOutcomeFailure {
+		t.Fatalf("expected the failed login to be listed, got %+v", failed)
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"go-security/security"
+	"golang.org/x/time/rate"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SmsTransportSns  = "sns"
+	SmsTransportFake = "fake"
+)
+
+var e164PhoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+type SmsConfig struct {
+	// Transport selects the SmsSender backend: fake (default) or sns.
+	Transport       string `yaml:"transport"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"-" secret:"true"`
+	SenderID        string `yaml:"sender_id"`
+	// MaxPerRecipientPerHour and MaxPerMinute bound the SMS spending, zero disables the limit.
+	MaxPerRecipientPerHour int `yaml:"max_per_recipient_per_hour"`
+	MaxPerMinute           int `yaml:"max_per_minute"`
+}
+
+type SmsSender interface {
+	SendSms(ctx context.Context, phoneNumber string, message string) error
+}
+
+func ValidatePhoneNumber(phoneNumber string) error {
+	if !e164PhoneNumberPattern.MatchString(phoneNumber) {
+		return security.PhoneNumberNotAllowed
+	}
+	return nil
+}
+
+func NewSmsSenderFromConfig(config *SmsConfig) (SmsSender, error) {
+	if config == nil {
+		config = &SmsConfig{}
+	}
+	var sender SmsSender
+	switch strings.ToLower(config.Transport) {
+	case SmsTransportSns:
+		snsSender, err := NewSnsSmsSenderFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		sender = snsSender
+	case "", SmsTransportFake:
+		sender = NewFakeSmsSender()
+	default:
+		return nil, security.SmsTransportNotSupported
+	}
+	return NewRateLimitedSmsSender(sender, config.MaxPerRecipientPerHour, config.MaxPerMinute), nil
+}
+
+func MustNewSmsSenderFromConfig(config *SmsConfig) SmsSender {
+	sender, err := NewSmsSenderFromConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	return sender
+}
+
+type SmsMessage struct {
+	PhoneNumber string
+	Message     string
+	SentAt      time.Time
+}
+
+// FakeSmsSender keeps sent messages in memory so tests can inspect them.
+type FakeSmsSender struct {
+	messages []*SmsMessage
+	lock     sync.RWMutex
+	// SendError, when set, is returned by SendSms instead of capturing the message.
+	SendError error
+}
+
+func NewFakeSmsSender() *FakeSmsSender {
+	return &FakeSmsSender{}
+}
+
+func (sender *FakeSmsSender) SendSms(ctx context.Context, phoneNumber string, message string) error {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	if sender.SendError != nil {
+		return sender.SendError
+	}
+	sender.messages = append(sender.messages, &SmsMessage{PhoneNumber: phoneNumber, Message: message, SentAt: time.Now()})
+	return nil
+}
+
+func (sender *FakeSmsSender) Messages() []*SmsMessage {
+	sender.lock.RLock()
+	defer sender.lock.RUnlock()
+	messages := make([]*SmsMessage, len(sender.messages))
+	copy(messages, sender.messages)
+	return messages
+}
+
+func (sender *FakeSmsSender) Reset() {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	sender.messages = nil
+}
+
+type recipientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimitedSmsSender caps the SMS sent per recipient and overall, SMS are billed per message so
+// the limit is enforced in front of every transport rather than per endpoint.
+type RateLimitedSmsSender struct {
+	Sender                 SmsSender
+	MaxPerRecipientPerHour int
+	globalLimiter          *rate.Limiter
+	recipientLimiters      map[string]*recipientLimiter
+	lock                   sync.Mutex
+}
+
+func NewRateLimitedSmsSender(sender SmsSender, maxPerRecipientPerHour int, maxPerMinute int) *RateLimitedSmsSender {
+	rateLimitedSender := &RateLimitedSmsSender{
+		Sender:                 sender,
+		MaxPerRecipientPerHour: maxPerRecipientPerHour,
+		recipientLimiters:      make(map[string]*recipientLimiter),
+	}
+	if maxPerMinute > 0 {
+		rateLimitedSender.globalLimiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(maxPerMinute)), maxPerMinute)
+	}
+	return rateLimitedSender
+}
+
+func (sender *RateLimitedSmsSender) allow(phoneNumber string) bool {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	now := time.Now()
+
+	if sender.MaxPerRecipientPerHour > 0 {
+		for recipient, entry := range sender.recipientLimiters {
+			if now.Sub(entry.lastSeen) > time.Hour {
+				delete(sender.recipientLimiters, recipient)
+			}
+		}
+		entry, ok := sender.recipientLimiters[phoneNumber]
+		if !ok {
+			entry = &recipientLimiter{
+				limiter: rate.NewLimiter(rate.Every(time.Hour/time.Duration(sender.MaxPerRecipientPerHour)), sender.MaxPerRecipientPerHour),
+			}
+			sender.recipientLimiters[phoneNumber] = entry
+		}
+		entry.lastSeen = now
+		if !entry.limiter.AllowN(now, 1) {
+			return false
+		}
+	}
+	return sender.globalLimiter == nil || sender.globalLimiter.AllowN(now, 1)
+}
+
+func (sender *RateLimitedSmsSender) SendSms(ctx context.Context, phoneNumber string, message string) error {
+	if !sender.allow(phoneNumber) {
+		return security.SmsRateLimitExceeded
+	}
+	return sender.Sender.SendSms(ctx, phoneNumber, message)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"gopkg.in/gomail.v2"
+	"sync"
+	"time"
+)
+
+// DefaultSmtpHealthCheckInterval is how long the result of a connection to the SMTP server is reused by
+// the health checks, every probe would otherwise open a connection and authenticate.
+const DefaultSmtpHealthCheckInterval = time.Minute
+
+type SmtpEmailSender struct {
+	Dialer              *gomail.Dialer
+	HealthCheckInterval time.Duration
+	healthCheckedAt     time.Time
+	healthErr           error
+	lock                sync.Mutex
+}
+
+func NewSmtpEmailSender(config *SmtpConfig) *SmtpEmailSender {
+	return &SmtpEmailSender{
+		Dialer: gomail.NewDialer(
+			config.Host,
+			config.Port,
+			config.SenderEmail,
+			config.SenderPassword,
+		),
+		HealthCheckInterval: DefaultSmtpHealthCheckInterval,
+	}
+}
+
+func (sender *SmtpEmailSender) Send(ctx context.Context, email *Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return sender.Dialer.DialAndSend(email.AsMessage())
+}
+
+// CheckHealth connects to the SMTP server and authenticates, without sending anything. The result is
+// reused for HealthCheckInterval.
+func (sender *SmtpEmailSender) CheckHealth(ctx context.Context) error {
+	sender.lock.Lock()
+	if !sender.healthCheckedAt.IsZero() && time.Since(sender.healthCheckedAt) < sender.HealthCheckInterval {
+		defer sender.lock.Unlock()
+		return sender.healthErr
+	}
+	sender.lock.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := sender.dialHealth()
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	sender.healthCheckedAt = time.Now()
+	sender.healthErr = err
+	return err
+}
+
+func (sender *SmtpEmailSender) dialHealth() error {
+	closer, err := sender.Dialer.Dial()
+	if err != nil {
+		return err
+	}
+	return closer.Close()
+}
@@ -1,7 +1,7 @@
 package service
 
 import (
-	"gopkg.in/gomail.v2"
+	"context"
 	"os"
 )
 
@@ -13,8 +13,9 @@ const (
 )
 
 type ISmtpService interface {
-	CreateNewMessage(to string, subject string, body string, contentType ContentType, attachments ...*os.File) *gomail.Message
-	SendEmail(message *gomail.Message) error
+	CreateNewMessage(to string, subject string, body string, contentType ContentType, attachments ...*os.File) *Email
+	CreateNewTemplatedMessage(to string, rendered *RenderedTemplate) *Email
+	SendEmail(ctx context.Context, message *Email) error
 	GetSmtpConfig() *SmtpConfig
 }
 
@@ -23,12 +24,16 @@ type SmtpConfig struct {
 	Host           string `yaml:"host"`
 	Port           int    `yaml:"port"`
 	SenderEmail    string `yaml:"sender_email"`
-	SenderPassword string `yaml:"sender_password" json:"-"`
+	SenderPassword string `yaml:"sender_password" json:"-" secret:"true"`
+	// Transport selects the EmailSender backend: smtp (default), file, console, memory or ses.
+	Transport       string     `yaml:"transport"`
+	OutputDirectory string     `yaml:"output_directory"`
+	Ses             *SesConfig `yaml:"ses"`
 }
 
 type SmtpService struct {
 	SmtpConfig *SmtpConfig
-	Dialer     *gomail.Dialer
+	Sender     EmailSender
 }
 
 func (service *SmtpService) PostConstruct() {}
@@ -37,35 +42,29 @@ func (service *SmtpService) GetSmtpConfig() *SmtpConfig {
 	return service.SmtpConfig
 }
 
-func NewSmtpService(config *SmtpConfig) *SmtpService {
-	dialer := gomail.NewDialer(
-		config.Host,
-		config.Port,
-		config.SenderEmail,
-		config.SenderPassword,
-	)
-
+func NewSmtpService(config *SmtpConfig, sender EmailSender) *SmtpService {
 	service := &SmtpService{
 		SmtpConfig: config,
-		Dialer:     dialer,
+		Sender:     sender,
 	}
 
 	return service
 }
 
-func (service *SmtpService) CreateNewMessage(to string, subject string, body string, contentType ContentType, attachments ...*os.File) *gomail.Message {
-	message := gomail.NewMessage()
-	message.SetHeader("From", service.SmtpConfig.SenderEmail)
-	message.SetHeader("To", to)
-	message.SetHeader("Subject", subject)
-	message.SetBody(string(contentType), body)
-
+func (service *SmtpService) CreateNewMessage(to string, subject string, body string, contentType ContentType, attachments ...*os.File) *Email {
+	attachmentNames := make([]string, 0, len(attachments))
 	for _, attachment := range attachments {
-		message.Attach(attachment.Name())
+		attachmentNames = append(attachmentNames, attachment.Name())
 	}
-	return message
+	return NewEmail(service.SmtpConfig.SenderEmail, to, subject, body, contentType, attachmentNames...)
 }
 
-func (service *SmtpService) SendEmail(message *gomail.Message) error {
-	return service.Dialer.DialAndSend(message)
+func (service *SmtpService) SendEmail(ctx context.Context, message *Email) error {
+	return service.Sender.Send(ctx, message)
+}
+
+func (service *SmtpService) CreateNewTemplatedMessage(to string, rendered *RenderedTemplate) *Email {
+	message := service.CreateNewMessage(to, rendered.Subject, rendered.Html, ContentTypeHtml)
+	message.AlternativeBody = rendered.Text
+	return message
 }
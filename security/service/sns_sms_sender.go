@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// SnsClient is the subset of the SNS client used by SnsSmsSender, so it can be stubbed in tests.
+type SnsClient interface {
+	Publish(ctx context.Context, input *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+type SnsSmsSender struct {
+	Client   SnsClient
+	SenderID string
+}
+
+func NewSnsSmsSender(client SnsClient, senderID string) *SnsSmsSender {
+	return &SnsSmsSender{
+		Client:   client,
+		SenderID: senderID,
+	}
+}
+
+// NewSnsSmsSenderFromConfig uses static credentials when given, otherwise the default AWS credential chain.
+func NewSnsSmsSenderFromConfig(config *SmsConfig) (*SnsSmsSender, error) {
+	var options []func(*awsconfig.LoadOptions) error
+	if len(config.Region) > 0 {
+		options = append(options, awsconfig.WithRegion(config.Region))
+	}
+	if len(config.AccessKeyID) > 0 {
+		options = append(options, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AccessKeyID, config.SecretAccessKey, ""),
+		))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSnsSmsSender(sns.NewFromConfig(awsConfig), config.SenderID), nil
+}
+
+func (sender *SnsSmsSender) SendSms(ctx context.Context, phoneNumber string, message string) error {
+	attributes := map[string]types.MessageAttributeValue{
+		"AWS.SNS.SMS.SMSType": {DataType: aws.String("String"), StringValue: aws.String("Transactional")},
+	}
+	if len(sender.SenderID) > 0 {
+		attributes["AWS.SNS.SMS.SenderID"] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(sender.SenderID)}
+	}
+	_, err := sender.Client.Publish(ctx, &sns.PublishInput{
+		PhoneNumber:       aws.String(phoneNumber),
+		Message:           aws.String(message),
+		MessageAttributes: attributes,
+	})
+	return err
+}
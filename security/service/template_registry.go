@@ -0,0 +1,244 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"go-security/security"
+	"html"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+const (
+	templateExtensionHtml = ".html"
+	templateExtensionText = ".txt"
+	templateLayoutName    = "layout"
+	templateSubjectName   = "subject"
+)
+
+type TemplateConfig struct {
+	// Directory mirrors the embedded layout (layouts/, partials/, pages/), its files replace or extend the defaults.
+	Directory     string            `yaml:"directory"`
+	DefaultLocale string            `yaml:"default_locale"`
+	Variables     map[string]string `yaml:"variables"`
+}
+
+type RenderedTemplate struct {
+	Subject string
+	Html    string
+	Text    string
+}
+
+// TemplateRegistry parses every page once at startup. A page "pages/<name>[.<locale>].<html|txt>" is
+// combined with the layout and partials of the same extension, the html page also defines the subject.
+type TemplateRegistry struct {
+	Config        *TemplateConfig
+	htmlTemplates map[string]*htmltemplate.Template
+	textTemplates map[string]*texttemplate.Template
+}
+
+func NewTemplateRegistry(config *TemplateConfig) (*TemplateRegistry, error) {
+	if config == nil {
+		config = &TemplateConfig{}
+	}
+	sources, err := loadTemplateSources(config.Directory)
+	if err != nil {
+		return nil, err
+	}
+	registry := &TemplateRegistry{
+		Config:        config,
+		htmlTemplates: make(map[string]*htmltemplate.Template),
+		textTemplates: make(map[string]*texttemplate.Template),
+	}
+	if err := registry.parseHtmlTemplates(sources); err != nil {
+		return nil, err
+	}
+	if err := registry.parseTextTemplates(sources); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func MustNewTemplateRegistry(config *TemplateConfig) *TemplateRegistry {
+	registry, err := NewTemplateRegistry(config)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+func (registry *TemplateRegistry) PostConstruct() {}
+
+// loadTemplateSources reads the embedded templates, then overlays the files of the override directory.
+func loadTemplateSources(directory string) (map[string]string, error) {
+	sources := make(map[string]string)
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	fileSystems := []fs.FS{embedded}
+	if len(directory) > 0 {
+		fileSystems = append(fileSystems, os.DirFS(directory))
+	}
+	for _, fileSystem := range fileSystems {
+		err := fs.WalkDir(fileSystem, ".", func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			content, err := fs.ReadFile(fileSystem, filePath)
+			if err != nil {
+				return err
+			}
+			sources[filePath] = string(content)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+func sortedTemplatePaths(sources map[string]string, directory string, extension string) []string {
+	var paths []string
+	for filePath := range sources {
+		if path.Dir(filePath) == directory && path.Ext(filePath) == extension {
+			paths = append(paths, filePath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// templateKey turns "pages/reset_password.zh-TW.html" into "reset_password.zh-TW".
+func templateKey(filePath string) string {
+	return strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+}
+
+func (registry *TemplateRegistry) parseHtmlTemplates(sources map[string]string) error {
+	base := htmltemplate.New("base")
+	for _, directory := range []string{"layouts", "partials"} {
+		for _, filePath := range sortedTemplatePaths(sources, directory, templateExtensionHtml) {
+			if _, err := base.New(filePath).Parse(sources[filePath]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, filePath := range sortedTemplatePaths(sources, "pages", templateExtensionHtml) {
+		page, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := page.New(filePath).Parse(sources[filePath]); err != nil {
+			return err
+		}
+		registry.htmlTemplates[templateKey(filePath)] = page
+	}
+	return nil
+}
+
+func (registry *TemplateRegistry) parseTextTemplates(sources map[string]string) error {
+	base := texttemplate.New("base")
+	for _, directory := range []string{"layouts", "partials"} {
+		for _, filePath := range sortedTemplatePaths(sources, directory, templateExtensionText) {
+			if _, err := base.New(filePath).Parse(sources[filePath]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, filePath := range sortedTemplatePaths(sources, "pages", templateExtensionText) {
+		page, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := page.New(filePath).Parse(sources[filePath]); err != nil {
+			return err
+		}
+		registry.textTemplates[templateKey(filePath)] = page
+	}
+	return nil
+}
+
+// localeCandidates lists the keys to try for a locale, "zh-TW" falls back to "zh", the default locale and no locale.
+func (registry *TemplateRegistry) localeCandidates(name TemplateName, locale string) []string {
+	var candidates []string
+	for _, candidate := range []string{locale, registry.Config.DefaultLocale} {
+		candidate = strings.ReplaceAll(candidate, "_", "-")
+		if len(candidate) == 0 {
+			continue
+		}
+		candidates = append(candidates, string(name)+"."+candidate)
+		if language, _, found := strings.Cut(candidate, "-"); found {
+			candidates = append(candidates, string(name)+"."+language)
+		}
+	}
+	return append(candidates, string(name))
+}
+
+func resolveTemplate[T any](templates map[string]T, candidates []string) (T, bool) {
+	for _, candidate := range candidates {
+		if found, ok := templates[candidate]; ok {
+			return found, true
+		}
+	}
+	var notFound T
+	return notFound, false
+}
+
+func (registry *TemplateRegistry) withVariables(data *EmailTemplate) *EmailTemplate {
+	merged := *data
+	merged.Variables = make(map[string]string)
+	for key, value := range DefaultTemplateVariables {
+		merged.Variables[key] = value
+	}
+	for key, value := range registry.Config.Variables {
+		merged.Variables[key] = value
+	}
+	for key, value := range data.Variables {
+		merged.Variables[key] = value
+	}
+	return &merged
+}
+
+// Render renders the html body, its plain-text alternative and the subject of a template in the
+// closest available locale. The text part is empty when the template has no .txt variant.
+func (registry *TemplateRegistry) Render(name TemplateName, data *EmailTemplate) (*RenderedTemplate, error) {
+	candidates := registry.localeCandidates(name, data.Locale)
+	htmlTemplate, ok := resolveTemplate(registry.htmlTemplates, candidates)
+	if !ok {
+		return nil, security.TemplateNotFound
+	}
+	data = registry.withVariables(data)
+
+	var rendered RenderedTemplate
+	var buffer bytes.Buffer
+	if err := htmlTemplate.ExecuteTemplate(&buffer, templateLayoutName, data); err != nil {
+		return nil, err
+	}
+	rendered.Html = buffer.String()
+
+	if htmlTemplate.Lookup(templateSubjectName) != nil {
+		buffer.Reset()
+		if err := htmlTemplate.ExecuteTemplate(&buffer, templateSubjectName, data); err != nil {
+			return nil, err
+		}
+		rendered.Subject = html.UnescapeString(strings.TrimSpace(buffer.String()))
+	}
+
+	if textTemplate, ok := resolveTemplate(registry.textTemplates, candidates); ok {
+		buffer.Reset()
+		if err := textTemplate.ExecuteTemplate(&buffer, templateLayoutName, data); err != nil {
+			return nil, err
+		}
+		rendered.Text = buffer.String()
+	}
+	return &rendered, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"go-security/security"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplateOverride(t *testing.T, directory string, filePath string, content string) {
+	t.Helper()
+	target := filepath.Join(directory, filePath)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemplateLocaleFallback(t *testing.T) {
+	directory := t.TempDir()
+	writeTemplateOverride(t, directory, "pages/reset_password.ja.html", `{{define "subject"}}パスワードの再設定{{end}}{{define "title"}}{{end}}{{define "content"}}{{.OTPCode}}{{end}}`)
+	registry := MustNewTemplateRegistry(&TemplateConfig{Directory: directory})
+	defaultLocale := MustNewTemplateRegistry(&TemplateConfig{DefaultLocale: "zh-TW"})
+	tests := []struct {
+		registry *TemplateRegistry
+		locale   string
+		subject  string
+	}{
+		{registry, "zh-TW", "重設密碼"},
+		{registry, "zh_TW", "重設密碼"},
+		{registry, "ja-JP", "パスワードの再設定"},
+		{registry, "fr", "Reset Password"},
+		{registry, "", "Reset Password"},
+		{defaultLocale, "fr", "重設密碼"},
+		{defaultLocale, "en", "重設密碼"},
+	}
+	for _, test := range tests {
+		rendered, err := test.registry.Render(TemplateResetPassword, NewEmailTemplate("alice", "123456", "Acme").WithLocale(test.locale))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rendered.Subject != test.subject {
+			t.Errorf("%q: expected the subject %q, got %q", test.locale, test.subject, rendered.Subject)
+		}
+	}
+	if _, err := registry.Render("missing", NewEmailTemplate("alice", "", "Acme")); !errors.Is(err, security.TemplateNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestTemplateOverridePrecedence(t *testing.T) {
+	directory := t.TempDir()
+	writeTemplateOverride(t, directory, "pages/reset_password.html", `{{define "subject"}}Custom reset{{end}}{{define "title"}}{{end}}{{define "content"}}<p class="brand">{{index .Variables "accent_color"}}</p>{{end}}`)
+	registry := MustNewTemplateRegistry(&TemplateConfig{
+		Directory: directory,
+		Variables: map[string]string{"accent_color": "#config", "card_color": "#card"},
+	})
+
+	rendered, err := registry.Render(TemplateResetPassword, NewEmailTemplate("alice", "123456", "Acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Subject != "Custom reset" || !strings.Contains(rendered.Html, `<p class="brand">#config</p>`) {
+		t.Fatalf("the override page and the config variables must win over the embedded ones, got %q %s", rendered.Subject, rendered.Html)
+	}
+	if !strings.Contains(rendered.Html, DefaultTemplateVariables["background_color"]) {
+		t.Fatal("the variables the config leaves out must keep their default")
+	}
+	if !strings.Contains(rendered.Text, "123456") {
+		t.Fatal("the embedded text page must still be used when only the html page is overridden")
+	}
+
+	rendered, err = registry.Render(TemplateResetPassword, NewEmailTemplate("alice", "123456", "Acme").WithVariable("accent_color", "#caller"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rendered.Html, `<p class="brand">#caller</p>`) {
+		t.Fatalf("the variables of the caller must win over the config, got %s", rendered.Html)
+	}
+
+	rendered, err = registry.Render(TemplateResetPassword, NewEmailTemplate("alice", "123456", "Acme").WithLocale("zh-TW"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Subject != "重設密碼" {
+		t.Fatalf("a localized page must win over an override in another locale, got %q", rendered.Subject)
+	}
+}
@@ -1,13 +1,12 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/rs/zerolog/log"
 	"go-security/security"
-	"html/template"
+	. "go-security/security/repository"
+	"slices"
 	"time"
 )
 
@@ -24,18 +23,22 @@ func (claims *ResetPasswordClaims) Validate() error {
 }
 
 type UserResetPasswordService struct {
-	SmtpService ISmtpService
-	UserService *UserService
-	AuthService *AuthService
-	OtpService  *OtpService
+	UserService          *UserService
+	AuthService          *AuthService
+	OtpService           *OtpService
+	OtpDeliveryService   *OtpDeliveryService
+	AuditService         *AuditService
+	AccountActionService *AccountActionService
 }
 
-func NewUserResetPasswordService(smtpService ISmtpService, userService *UserService, authService *AuthService, otpService *OtpService) *UserResetPasswordService {
+func NewUserResetPasswordService(userService *UserService, authService *AuthService, otpService *OtpService, otpDeliveryService *OtpDeliveryService, auditService *AuditService, accountActionService *AccountActionService) *UserResetPasswordService {
 	service := &UserResetPasswordService{
-		SmtpService: smtpService,
-		UserService: userService,
-		AuthService: authService,
-		OtpService:  otpService,
+		UserService:          userService,
+		AuthService:          authService,
+		OtpService:           otpService,
+		OtpDeliveryService:   otpDeliveryService,
+		AuditService:         auditService,
+		AccountActionService: accountActionService,
 	}
 	fmt.Printf("")
 	return service
@@ -62,22 +65,24 @@ func (service *UserResetPasswordService) extractResetPasswordClaims(claims *jwt.
 	var resetPasswordClaims ResetPasswordClaims
 	purpose, ok := (*claims)["purpose"].(string)
 	if !ok || purpose != string(PurposeResetPassword) {
-		return nil, fmt.Errorf("invalid or missing 'purpose' claim, getting %s, expects %v", purpose, PurposeResetPassword)
+		return nil, fmt.Errorf("%w: invalid or missing 'purpose' claim, getting %s, expects %v", security.TokenInvalid, purpose, PurposeResetPassword)
 	}
 	userID, ok := (*claims)["id"].(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'id' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'id' claim", security.TokenInvalid)
 	}
 	expiration, ok := (*claims)["exp"].(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid or missing 'exp' claim")
+		return nil, fmt.Errorf("%w: invalid or missing 'exp' claim", security.TokenInvalid)
 	}
 	resetPasswordClaims.ID = uint(userID)
 	resetPasswordClaims.ExpirationDuration = expiration
 	return &resetPasswordClaims, nil
 }
 
-func (service *UserResetPasswordService) doResetPassword(ctx context.Context, userId uint, newPassword string) error {
+// doResetPassword sets the new password and revokes every session of the user but keptSessionID, as any
+// of them may belong to whoever knew the old password.
+func (service *UserResetPasswordService) doResetPassword(ctx context.Context, userId uint, newPassword string, keptSessionID string) error {
 	user, err := service.UserService.GetUserByID(ctx, userId)
 	if err != nil {
 		return security.UserNotFound
@@ -89,30 +94,65 @@ func (service *UserResetPasswordService) doResetPassword(ctx context.Context, us
 	if err := service.UserService.ResetUserPassword(ctx, user, hashedPassword); err != nil {
 		return err
 	}
-	return nil
+	if service.AuthService.SessionService != nil {
+		if err := service.AuthService.SessionService.RevokeOtherSessions(ctx, user.ID, keptSessionID); err != nil {
+			return err
+		}
+	}
+	service.AuthService.NotifySecurityEvent(ctx, user.ID, SecurityEventPasswordChanged)
+	return service.AccountActionService.CompleteAction(ctx, user.ID, AccountActionResetPassword)
 }
 
 func (service *UserResetPasswordService) ResetPassword(ctx context.Context, token string, otpCode string, newPassword string, confirmedPassword string) error {
 	if newPassword != confirmedPassword {
 		return security.ResetPasswordNotMatched
 	}
-	log.Info().Msgf("Reset Password: %v", token)
 	claims, err := service.parseResetPasswordClaims(token)
 	if err != nil {
-		log.Warn().Msgf("Failed to parse reset password claims: %v", err)
+		LoggerFromContext(ctx).Warn().Msgf("Failed to parse reset password claims: %v", err)
 		return err
 	}
-
-	log.Info().Msgf("Claims: %v", claims)
-	log.Info().Msgf("Begin to verify OTP: %v", otpCode)
-	if err := service.OtpService.VerifyOtp(claims.ID, PurposeResetPassword, otpCode); err != nil {
+	if err := service.OtpService.ConsumeOtp(claims.ID, PurposeResetPassword, otpCode); err != nil {
+		service.AuditService.RecordUserAction(ctx, AuditActionPasswordReset, claims.ID, err)
 		return err
 	}
 
 	if err := claims.Validate(); err != nil {
 		return err
 	}
-	return service.doResetPassword(ctx, claims.ID, newPassword)
+	err = service.doResetPassword(ctx, claims.ID, newPassword, "")
+	service.AuditService.RecordUserAction(ctx, AuditActionPasswordReset, claims.ID, err)
+	return err
+}
+
+// ChangePassword changes the password of a logged-in user. The session of the request, sessionID, stays
+// signed in while the other sessions are revoked. A forced reset cannot be completed here, whoever holds
+// the session may not know the password, it goes through the emailed reset token and code instead.
+func (service *UserResetPasswordService) ChangePassword(ctx context.Context, userID uint, sessionID string, currentPassword string, newPassword string, confirmedPassword string) error {
+	if newPassword != confirmedPassword {
+		return security.ResetPasswordNotMatched
+	}
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Platform.Name != string(PlatformSelf) {
+		return security.SelfPlatformRequiredForPasswordReset
+	}
+	actions, err := service.AccountActionService.GetRequiredActions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(actions, AccountActionResetPassword) {
+		return security.PasswordResetRequired
+	}
+	if err := service.AuthService.VerifyPassword(currentPassword, user.Password); err != nil {
+		service.AuditService.RecordUserAction(ctx, AuditActionPasswordReset, user.ID, security.UserPasswordNotMatched)
+		return security.UserPasswordNotMatched
+	}
+	err = service.doResetPassword(ctx, user.ID, newPassword, sessionID)
+	service.AuditService.RecordUserAction(ctx, AuditActionPasswordReset, user.ID, err)
+	return err
 }
 
 func (service *UserResetPasswordService) IssueResetPasswordToken(ctx context.Context, email string) (string, error) {
@@ -121,6 +161,10 @@ func (service *UserResetPasswordService) IssueResetPasswordToken(ctx context.Con
 		return "", err
 	}
 
+	return service.IssueResetPasswordTokenForUser(user)
+}
+
+func (service *UserResetPasswordService) IssueResetPasswordTokenForUser(user *User) (string, error) {
 	if user.Platform.Name != string(PlatformSelf) {
 		return "", security.SelfPlatformRequiredForPasswordReset
 	}
@@ -133,7 +177,31 @@ func (service *UserResetPasswordService) IssueResetPasswordToken(ctx context.Con
 	return service.AuthService.IssueJsonWebToken(&claims), nil
 }
 
+// SendResetPasswordLink emails a reset code along with a link to resetUrl carrying the reset token, the
+// token never leaves the email.
+func (service *UserResetPasswordService) SendResetPasswordLink(ctx context.Context, user *User, resetUrl string) error {
+	token, err := service.IssueResetPasswordTokenForUser(user)
+	if err != nil {
+		return err
+	}
+	link, err := buildTokenLink(resetUrl, token)
+	if err != nil {
+		return err
+	}
+	_, err = service.OtpDeliveryService.DeliverOtpWithLink(ctx, user, PurposeResetPassword, link)
+	event := NewAuditEvent(AuditActionPasswordResetRequested, err)
+	event.SubjectID = AuditUserID(user.ID)
+	event.Metadata["channel"] = string(DeliveryChannelEmail)
+	service.AuditService.Record(ctx, event)
+	return err
+}
+
 func (service *UserResetPasswordService) SendResetPasswordEmail(context context.Context, token string) (string, error) {
+	return service.SendResetPasswordCode(context, token, DeliveryChannelEmail)
+}
+
+// SendResetPasswordCode sends the reset password OTP by email or, for users with a verified phone number, by SMS.
+func (service *UserResetPasswordService) SendResetPasswordCode(context context.Context, token string, channel DeliveryChannel) (string, error) {
 
 	claims, err := service.parseResetPasswordClaims(token)
 	if err != nil {
@@ -145,21 +213,13 @@ func (service *UserResetPasswordService) SendResetPasswordEmail(context context.
 		return "", err
 	}
 
-	otp := service.OtpService.GenerateOtp(claims.ID, PurposeResetPassword)
-
-	subject := "Reset Password"
-	_template, err := template.New("reset_password_email").Parse(RESET_PASSWORD_EMAIL_HTML_TEMPLATE)
+	_, err = service.OtpDeliveryService.DeliverOtp(context, user, PurposeResetPassword, channel)
+	event := NewAuditEvent(AuditActionPasswordResetRequested, err)
+	event.SubjectID = AuditUserID(user.ID)
+	event.Metadata["channel"] = string(channel)
+	service.AuditService.Record(context, event)
 	if err != nil {
-		return "", err
-	}
-	emailTemplate := NewEmailTemplate(user.Name, otp.Code, service.SmtpService.GetSmtpConfig().CompanyName)
-	var buffer bytes.Buffer
-	if err := _template.Execute(&buffer, emailTemplate); err != nil {
-		log.Info().Msgf("Failed to execute template: %v", err)
-	}
-	body := buffer.String()
-	message := service.SmtpService.CreateNewMessage(user.Email, subject, body, ContentTypeHtml)
-	if err := service.SmtpService.SendEmail(message); err != nil {
+		LoggerFromContext(context).Info().Msgf("Failed to deliver reset password code: %v", err)
 		return "", err
 	}
 	return token, nil
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"go-security/security"
+	. "go-security/security/repository"
+	"sync"
+	"time"
+)
+
+const DefaultUsedTokenPruneInterval = 10 * time.Minute
+
+// UsedTokenService makes signed tokens single-use by recording their ID when redeemed. The records are
+// shared by every instance through the database and pruned once the tokens expired.
+type UsedTokenService struct {
+	Repository    IUsedTokenRepository
+	PruneInterval time.Duration
+	cancel        context.CancelFunc
+	waitGroup     sync.WaitGroup
+	lock          sync.Mutex
+}
+
+func NewUsedTokenService(repository IUsedTokenRepository) *UsedTokenService {
+	return &UsedTokenService{
+		Repository:    repository,
+		PruneInterval: DefaultUsedTokenPruneInterval,
+	}
+}
+
+func (service *UsedTokenService) PostConstruct() {
+	service.Start(context.Background())
+}
+
+func (service *UsedTokenService) PreDestroy(ctx context.Context) error {
+	return waitUntilDone(ctx, service.Stop)
+}
+
+// Redeem records the token ID, it returns security.TokenAlreadyUsed when the token was redeemed before.
+func (service *UsedTokenService) Redeem(ctx context.Context, purpose Purpose, tokenID string, expiresAt time.Time) error {
+	stored, err := service.Repository.MarkUsed(ctx, &UsedToken{
+		TokenID:   tokenID,
+		Purpose:   string(purpose),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return security.TokenAlreadyUsed
+	}
+	return nil
+}
+
+// Prune deletes the records of expired tokens.
+func (service *UsedTokenService) Prune(ctx context.Context) (int64, error) {
+	return service.Repository.DeleteExpired(ctx, time.Now())
+}
+
+// Start prunes every PruneInterval until Stop is called.
+func (service *UsedTokenService) Start(ctx context.Context) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	if service.cancel != nil {
+		return
+	}
+	ctx, service.cancel = context.WithCancel(ctx)
+	service.waitGroup.Add(1)
+	go func() {
+		defer service.waitGroup.Done()
+		ticker := time.NewTicker(service.PruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := service.Prune(ctx); err != nil && ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to prune used tokens")
+				}
+			}
+		}
+	}()
+}
+
+func (service *UsedTokenService) Stop() {
+	service.lock.Lock()
+	cancel := service.cancel
+	service.cancel = nil
+	service.lock.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	service.waitGroup.Wait()
+}
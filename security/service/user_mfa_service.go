@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"go-security/security"
+	. "go-security/security/repository"
+	"time"
+)
+
+const (
+	AuditActionMfaEnabled  AuditAction = "mfa_enabled"
+	AuditActionMfaDisabled AuditAction = "mfa_disabled"
+
+	// MfaChallengeAction restricts the login token of a user with MFA enabled until their sign-in code is
+	// verified. It is carried by the token only, it is never requested through the AccountActionService.
+	MfaChallengeAction AccountAction = "mfa_challenge"
+)
+
+// UserMfaService manages the second factor of the users, a one-time code delivered over the channel
+// they enrolled. Codes are sent on demand: at enrollment, to complete a login, and to disable MFA.
+type UserMfaService struct {
+	UserService          *UserService
+	AuthService          *AuthService
+	OtpService           *OtpService
+	OtpDeliveryService   *OtpDeliveryService
+	AuditService         *AuditService
+	AccountActionService *AccountActionService
+}
+
+func NewUserMfaService(userService *UserService, authService *AuthService, otpService *OtpService, otpDeliveryService *OtpDeliveryService, auditService *AuditService, accountActionService *AccountActionService) *UserMfaService {
+	return &UserMfaService{
+		UserService:          userService,
+		AuthService:          authService,
+		OtpService:           otpService,
+		OtpDeliveryService:   otpDeliveryService,
+		AuditService:         auditService,
+		AccountActionService: accountActionService,
+	}
+}
+
+// StartEnrollment sends a code over the channel being enrolled, it is enabled once ConfirmEnrollment verifies the code.
+func (service *UserMfaService) StartEnrollment(ctx context.Context, userID uint, channel DeliveryChannel) error {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsMfaEnabled {
+		return security.MfaAlreadyEnabled
+	}
+	_, err = service.OtpDeliveryService.DeliverOtp(ctx, user, PurposeMfa, channel)
+	return err
+}
+
+// ConfirmEnrollment enables MFA over the channel and completes a pending request to enroll it.
+func (service *UserMfaService) ConfirmEnrollment(ctx context.Context, userID uint, channel DeliveryChannel, otpCode string) error {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsMfaEnabled {
+		return security.MfaAlreadyEnabled
+	}
+	if len(channel) == 0 {
+		channel = DeliveryChannelEmail
+	}
+	if channel == DeliveryChannelSms && (user.PhoneNumber == nil || !user.IsPhoneVerified) {
+		return security.PhoneNumberNotVerified
+	}
+	if err := service.OtpService.ConsumeOtp(user.ID, PurposeMfa, otpCode); err != nil {
+		service.AuditService.RecordUserAction(ctx, AuditActionMfaEnabled, user.ID, err)
+		return err
+	}
+	err = service.UserService.UpdateUserMfa(ctx, user, true, channel)
+	service.AuditService.RecordUserAction(ctx, AuditActionMfaEnabled, user.ID, err)
+	if err != nil {
+		return err
+	}
+	return service.AccountActionService.CompleteAction(ctx, user.ID, AccountActionEnrollMfa)
+}
+
+// SendCode sends a sign-in code over the enrolled channel, to complete a login or to disable MFA.
+func (service *UserMfaService) SendCode(ctx context.Context, userID uint) error {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsMfaEnabled {
+		return security.MfaNotEnabled
+	}
+	_, err = service.OtpDeliveryService.DeliverOtp(ctx, user, PurposeMfa, mfaChannel(user))
+	return err
+}
+
+// VerifyChallenge verifies the sign-in code of an MFA challenge token and issues the login token it stood for.
+func (service *UserMfaService) VerifyChallenge(ctx context.Context, claims *UserClaims, otpCode string, expiration time.Duration) (string, error) {
+	if !claims.IsMfaChallenge() {
+		return "", security.MfaChallengeNotPending
+	}
+	user, err := service.UserService.GetUserByID(ctx, claims.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := service.OtpService.ConsumeOtp(user.ID, PurposeMfa, otpCode); err != nil {
+		service.AuthService.RecordLogin(ctx, user.ID, "mfa", err)
+		return "", err
+	}
+	token, err := service.AuthService.IssueSessionToken(ctx, user, expiration)
+	service.AuthService.RecordLogin(ctx, user.ID, "mfa", err)
+	return token, err
+}
+
+// Disable turns MFA off, the code sent by SendCode proves the request comes from the user. The user is
+// notified on their channels, in case someone else holding the session and the code did it.
+func (service *UserMfaService) Disable(ctx context.Context, userID uint, otpCode string) error {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsMfaEnabled {
+		return security.MfaNotEnabled
+	}
+	if err := service.OtpService.ConsumeOtp(user.ID, PurposeMfa, otpCode); err != nil {
+		service.AuditService.RecordUserAction(ctx, AuditActionMfaDisabled, user.ID, err)
+		return err
+	}
+	err = service.UserService.UpdateUserMfa(ctx, user, false, "")
+	service.AuditService.RecordUserAction(ctx, AuditActionMfaDisabled, user.ID, err)
+	if err != nil {
+		return err
+	}
+	service.AuthService.NotifySecurityEvent(ctx, user.ID, SecurityEventMfaDisabled)
+	return nil
+}
+
+// mfaChannel falls back to email once the phone number the codes were texted to is removed or replaced,
+// so the user is not locked out of their account.
+func mfaChannel(user *User) DeliveryChannel {
+	if DeliveryChannel(user.MfaChannel) == DeliveryChannelSms && user.PhoneNumber != nil && user.IsPhoneVerified {
+		return DeliveryChannelSms
+	}
+	return DeliveryChannelEmail
+}
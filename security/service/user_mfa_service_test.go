@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-security/security"
+	"go-security/security/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryNotificationPreferenceRepository struct{}
+
+func (repo *memoryNotificationPreferenceRepository) FindByUserID(ctx context.Context, userID uint) ([]*repository.NotificationPreference, error) {
+	return nil, nil
+}
+
+func (repo *memoryNotificationPreferenceRepository) Upsert(ctx context.Context, preference *repository.NotificationPreference) error {
+	return nil
+}
+
+type recordingNotifier struct {
+	notifications []*Notification
+}
+
+func (notifier *recordingNotifier) Channel() NotificationChannel {
+	return NotificationChannelEmail
+}
+
+func (notifier *recordingNotifier) Notify(ctx context.Context, user *repository.User, notification *Notification) error {
+	notifier.notifications = append(notifier.notifications, notification)
+	return nil
+}
+
+func newTestUserMfaService(t *testing.T) (*UserMfaService, *MemoryEmailSender) {
+	t.Helper()
+	accountActionService := newTestAccountActionService()
+	userService := accountActionService.UserService
+	authService := NewAuthService(userService, nil, nil, accountActionService, nil, nil, nil, "test-secret")
+	otpService := NewOtpService(func() string { return "123456" })
+	emailSender := NewMemoryEmailSender()
+	smtpService := NewSmtpService(&SmtpConfig{CompanyName: "Acme", SenderEmail: "noreply@example.com"}, emailSender)
+	otpDeliveryService := NewOtpDeliveryService(smtpService, NewFakeSmsSender(), MustNewTemplateRegistry(nil), otpService)
+	user, _ := userService.GetUserByID(context.Background(), testSelfUserID)
+	user.Email = "self@example.com"
+	return NewUserMfaService(userService, authService, otpService, otpDeliveryService, nil, accountActionService), emailSender
+}
+
+func enrollTestMfa(t *testing.T, service *UserMfaService) {
+	t.Helper()
+	ctx := context.Background()
+	if err := service.StartEnrollment(ctx, testSelfUserID, DeliveryChannelEmail); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.ConfirmEnrollment(ctx, testSelfUserID, DeliveryChannelEmail, "123456"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMfaEnrollmentCompletesRequiredAction(t *testing.T) {
+	service, emailSender := newTestUserMfaService(t)
+	ctx := context.Background()
+	if _, err := service.AccountActionService.RequestAction(ctx, testSelfUserID, repository.AccountActionEnrollMfa, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.StartEnrollment(ctx, testSelfUserID, DeliveryChannelEmail); err != nil {
+		t.Fatal(err)
+	}
+	if email := emailSender.LastEmail(); email == nil || email.To != "self@example.com" || !strings.Contains(email.Body, "123456") {
+		t.Fatalf("expected the code to be emailed, got %+v", email)
+	}
+	if err := service.ConfirmEnrollment(ctx, testSelfUserID, DeliveryChannelEmail, "000000"); !errors.Is(err, security.OtpIncorrect) {
+		t.Fatalf("a wrong code: got %v", err)
+	}
+	if err := service.ConfirmEnrollment(ctx, testSelfUserID, DeliveryChannelEmail, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := service.UserService.GetUserByID(ctx, testSelfUserID)
+	if !user.IsMfaEnabled || user.MfaChannel != string(DeliveryChannelEmail) {
+		t.Fatalf("expected MFA to be enabled by email, got %v over %q", user.IsMfaEnabled, user.MfaChannel)
+	}
+	if actions, _ := service.AccountActionService.GetRequiredActions(ctx, testSelfUserID); len(actions) > 0 {
+		t.Fatalf("expected the enrollment to complete the required action, got %v", actions)
+	}
+	if err := service.StartEnrollment(ctx, testSelfUserID, DeliveryChannelEmail); !errors.Is(err, security.MfaAlreadyEnabled) {
+		t.Fatalf("enrolling again: got %v", err)
+	}
+}
+
+func TestMfaLoginChallenge(t *testing.T) {
+	service, _ := newTestUserMfaService(t)
+	enrollTestMfa(t, service)
+	ctx := context.Background()
+	user, _ := service.UserService.GetUserByID(ctx, testSelfUserID)
+
+	token, err := service.AuthService.IssueLoginToken(ctx, user, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := service.AuthService.ParseUserClaims(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !challenge.IsMfaChallenge() || len(challenge.SessionID) > 0 {
+		t.Fatalf("expected a challenge token without a session, got %+v", challenge)
+	}
+	if limit := float64(time.Now().Add(MfaChallengeExpiration).Unix()); challenge.ExpirationDuration > limit {
+		t.Fatalf("a challenge token must expire within %v", MfaChallengeExpiration)
+	}
+	if _, err := service.AuthService.RefreshLoginToken(ctx, challenge, time.Hour); !errors.Is(err, security.MfaChallengePending) {
+		t.Fatalf("refreshing a challenge token must not skip the code, got %v", err)
+	}
+
+	if err := service.SendCode(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.VerifyChallenge(ctx, challenge, "000000", time.Hour); !errors.Is(err, security.OtpIncorrect) {
+		t.Fatalf("a wrong code: got %v", err)
+	}
+	token, err = service.VerifyChallenge(ctx, challenge, "123456", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := service.AuthService.ParseUserClaims(token)
+	if err != nil || claims.IsRestricted() {
+		t.Fatalf("expected an unrestricted login token, got %+v %v", claims, err)
+	}
+	if _, err := service.VerifyChallenge(ctx, claims, "123456", time.Hour); !errors.Is(err, security.MfaChallengeNotPending) {
+		t.Fatalf("verifying a login token: got %v", err)
+	}
+}
+
+func TestMfaDisableRequiresCode(t *testing.T) {
+	service, _ := newTestUserMfaService(t)
+	notifier := &recordingNotifier{}
+	notificationService := NewNotificationService(service.UserService, &memoryNotificationPreferenceRepository{}, notifier)
+	service.AuthService.NotificationService = notificationService
+	ctx := context.Background()
+	if err := service.Disable(ctx, testSelfUserID, "123456"); !errors.Is(err, security.MfaNotEnabled) {
+		t.Fatalf("disabling before enrolling: got %v", err)
+	}
+	enrollTestMfa(t, service)
+	if err := service.Disable(ctx, testSelfUserID, "123456"); !errors.Is(err, security.OtpNotFound) {
+		t.Fatalf("disabling without requesting a code: got %v", err)
+	}
+	if err := service.SendCode(ctx, testSelfUserID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Disable(ctx, testSelfUserID, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	notificationService.Wait()
+	if len(notifier.notifications) != 1 || notifier.notifications[0].Event != SecurityEventMfaDisabled {
+		t.Fatalf("expected the user to be notified once, got %+v", notifier.notifications)
+	}
+	user, _ := service.UserService.GetUserByID(ctx, testSelfUserID)
+	token, err := service.AuthService.IssueLoginToken(ctx, user, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := service.AuthService.ParseUserClaims(token); user.IsMfaEnabled || claims.IsMfaChallenge() {
+		t.Fatal("expected a regular login token once MFA is disabled")
+	}
+}
+
+func TestMfaCodesBySms(t *testing.T) {
+	service, emailSender := newTestUserMfaService(t)
+	smsSender := service.OtpDeliveryService.SmsSender.(*FakeSmsSender)
+	ctx := context.Background()
+	user, _ := service.UserService.GetUserByID(ctx, testSelfUserID)
+	phoneNumber := "+886912345678"
+	user.PhoneNumber = &phoneNumber
+
+	if err := service.StartEnrollment(ctx, user.ID, DeliveryChannelSms); !errors.Is(err, security.PhoneNumberNotVerified) {
+		t.Fatalf("enrolling an unverified number: got %v", err)
+	}
+	if err := service.ConfirmEnrollment(ctx, user.ID, DeliveryChannelSms, "123456"); !errors.Is(err, security.PhoneNumberNotVerified) {
+		t.Fatalf("confirming an unverified number: got %v", err)
+	}
+	user.IsPhoneVerified = true
+	if err := service.StartEnrollment(ctx, user.ID, DeliveryChannelSms); err != nil {
+		t.Fatal(err)
+	}
+	if messages := smsSender.Messages(); len(messages) != 1 || messages[0].PhoneNumber != phoneNumber || !strings.Contains(messages[0].Message, "sign-in code is 123456") {
+		t.Fatalf("expected the code to be texted, got %+v", messages)
+	}
+	if err := service.ConfirmEnrollment(ctx, user.ID, DeliveryChannelSms, "123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.SendCode(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(smsSender.Messages()) != 2 || emailSender.LastEmail() != nil {
+		t.Fatal("expected the sign-in code to be texted")
+	}
+	// The number is removed, the codes fall back to email rather than locking the user out.
+	user.PhoneNumber = nil
+	user.IsPhoneVerified = false
+	if err := service.SendCode(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(smsSender.Messages()) != 2 || emailSender.LastEmail() == nil {
+		t.Fatal("expected the sign-in code to be emailed")
+	}
+}
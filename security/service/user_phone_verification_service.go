@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"go-security/security"
+)
+
+type UserPhoneVerificationService struct {
+	UserService        *UserService
+	OtpService         *OtpService
+	OtpDeliveryService *OtpDeliveryService
+}
+
+func NewUserPhoneVerificationService(userService *UserService, otpService *OtpService, otpDeliveryService *OtpDeliveryService) *UserPhoneVerificationService {
+	return &UserPhoneVerificationService{
+		UserService:        userService,
+		OtpService:         otpService,
+		OtpDeliveryService: otpDeliveryService,
+	}
+}
+
+// RegisterPhoneNumber stores the number as unverified and texts a verification code to it.
+func (service *UserPhoneVerificationService) RegisterPhoneNumber(ctx context.Context, userID uint, phoneNumber string) error {
+	if err := ValidatePhoneNumber(phoneNumber); err != nil {
+		return err
+	}
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	owner, err := service.UserService.GetUserByPhoneNumber(ctx, phoneNumber)
+	if err == nil && owner.ID != user.ID {
+		return security.PhoneNumberAlreadyUsed
+	}
+	if user.PhoneNumber == nil || *user.PhoneNumber != phoneNumber {
+		if err := service.UserService.UpdateUserPhoneNumber(ctx, user, &phoneNumber); err != nil {
+			return err
+		}
+		user.PhoneNumber = &phoneNumber
+		user.IsPhoneVerified = false
+	}
+	if user.IsPhoneVerified {
+		return security.PhoneNumberAlreadyVerified
+	}
+	_, err = service.OtpDeliveryService.DeliverOtp(ctx, user, PurposePhoneVerification, DeliveryChannelSms)
+	return err
+}
+
+func (service *UserPhoneVerificationService) VerifyPhoneNumber(ctx context.Context, userID uint, otpCode string) error {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.PhoneNumber == nil {
+		return security.PhoneNumberNotAllowed
+	}
+	if err := service.OtpService.ConsumeOtp(user.ID, PurposePhoneVerification, otpCode); err != nil {
+		return err
+	}
+	return service.UserService.VerifyUserPhoneNumber(ctx, user)
+}
+
+func (service *UserPhoneVerificationService) RemovePhoneNumber(ctx context.Context, userID uint) error {
+	user, err := service.UserService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return service.UserService.UpdateUserPhoneNumber(ctx, user, nil)
+}
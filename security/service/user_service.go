@@ -4,6 +4,7 @@ import (
 	"context"
 	"go-security/security"
 	. "go-security/security/repository"
+	"go-security/security/tracing"
 )
 
 const (
@@ -88,6 +89,27 @@ func (service *UserService) GetRoleByName(ctx context.Context, name string) (*Us
 	}
 	return service.UserRepository.FindRoleByName(ctx, name)
 }
+
+// UpdateRoleSessionLimit sets how many sessions each user of the role may have at once, and what
+// happens to a login beyond the limit.
+func (service *UserService) UpdateRoleSessionLimit(ctx context.Context, name string, maxSessions uint, policy SessionLimitPolicy) (*UserRole, error) {
+	switch policy {
+	case SessionLimitPolicyEvictOldest, SessionLimitPolicyReject:
+	default:
+		return nil, security.SessionLimitPolicyNotSupported
+	}
+	role, err := service.GetRoleByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	role.MaxSessions = maxSessions
+	role.SessionLimitPolicy = policy
+	if err := service.UserRepository.UpdateRoleSessionLimit(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
 func (service *UserService) GetPlatformByName(ctx context.Context, name PlatformType) (*Platform, error) {
 	if len(name) == 0 {
 		return nil, security.UserPlatformEmpty
@@ -100,6 +122,8 @@ func (service *UserService) GetAllUsers(ctx context.Context) ([]*User, error) {
 }
 
 func (service *UserService) GetUserByID(ctx context.Context, id uint) (*User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByID")
+	defer span.End()
 	user, err := service.UserRepository.FindByID(ctx, id)
 	if err != nil {
 		return nil, security.UserNotFound
@@ -108,6 +132,8 @@ func (service *UserService) GetUserByID(ctx context.Context, id uint) (*User, er
 }
 
 func (service *UserService) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByEmail")
+	defer span.End()
 	user, err := service.UserRepository.FindByEmail(ctx, email)
 	if err != nil {
 		return nil, security.UserNotFound
@@ -137,3 +163,39 @@ func (service *UserService) ActivateUser(ctx context.Context, user *User) error
 	}
 	return service.UserRepository.ActivateUser(ctx, user)
 }
+
+func (service *UserService) UpdateUserLocale(ctx context.Context, userID uint, locale string) error {
+	user, err := service.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return service.UserRepository.UpdateUserLocale(ctx, user, locale)
+}
+
+func (service *UserService) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*User, error) {
+	user, err := service.UserRepository.FindByPhoneNumber(ctx, phoneNumber)
+	if err != nil {
+		return nil, security.UserNotFound
+	}
+	return user, nil
+}
+
+func (service *UserService) UpdateUserPhoneNumber(ctx context.Context, user *User, phoneNumber *string) error {
+	return service.UserRepository.UpdateUserPhoneNumber(ctx, user, phoneNumber)
+}
+
+func (service *UserService) VerifyUserPhoneNumber(ctx context.Context, user *User) error {
+	if user.IsPhoneVerified {
+		return nil
+	}
+	return service.UserRepository.VerifyUserPhoneNumber(ctx, user)
+}
+
+func (service *UserService) UpdateUserMfa(ctx context.Context, user *User, enabled bool, channel DeliveryChannel) error {
+	if err := service.UserRepository.UpdateUserMfa(ctx, user, enabled, string(channel)); err != nil {
+		return err
+	}
+	user.IsMfaEnabled = enabled
+	user.MfaChannel = string(channel)
+	return nil
+}
@@ -1,13 +1,11 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/rs/zerolog/log"
 	"go-security/security"
-	"html/template"
+	. "go-security/security/repository"
 	"time"
 )
 
@@ -17,20 +15,22 @@ type UserVerificationClaims struct {
 }
 
 type UserVerificationService struct {
-	SmtpService                       ISmtpService
-	UserService                       *UserService
-	AuthService                       *AuthService
-	OtpService                        *OtpService
-	AdminPushedEmailVerificationCache security.Set[uint]
+	UserService          *UserService
+	AuthService          *AuthService
+	OtpService           *OtpService
+	OtpDeliveryService   *OtpDeliveryService
+	AuditService         *AuditService
+	AccountActionService *AccountActionService
 }
 
-func NewUserVerificationService(smtpService ISmtpService, userService *UserService, authService *AuthService, otpService *OtpService) *UserVerificationService {
+func NewUserVerificationService(userService *UserService, authService *AuthService, otpService *OtpService, otpDeliveryService *OtpDeliveryService, auditService *AuditService, accountActionService *AccountActionService) *UserVerificationService {
 	return &UserVerificationService{
-		SmtpService:                       smtpService,
-		UserService:                       userService,
-		AuthService:                       authService,
-		OtpService:                        otpService,
-		AdminPushedEmailVerificationCache: make(security.Set[uint]),
+		OtpDeliveryService:   otpDeliveryService,
+		UserService:          userService,
+		AuthService:          authService,
+		OtpService:           otpService,
+		AuditService:         auditService,
+		AccountActionService: accountActionService,
 	}
 }
 
@@ -55,8 +55,8 @@ func (service *UserVerificationService) IssueVerificationToken(ctx context.Conte
 	return _jwt, nil
 }
 
-func (service *UserVerificationService) IsAdminAskingForVerification(userID uint) bool {
-	return service.AdminPushedEmailVerificationCache.Contains(userID)
+func (service *UserVerificationService) IsAdminAskingForVerification(ctx context.Context, userID uint) (bool, error) {
+	return service.AccountActionService.HasPendingAction(ctx, userID, AccountActionVerifyEmail)
 }
 
 func (service *UserVerificationService) SendVerificationEmailByUserID(ctx context.Context, userID uint, isAdminPushed bool) error {
@@ -70,27 +70,19 @@ func (service *UserVerificationService) SendVerificationEmailByUserID(ctx contex
 	}
 
 	if isAdminPushed {
-		log.Info().Msgf("Admin is pushing email verification for user %d", user.ID)
-		service.AdminPushedEmailVerificationCache.Add(user.ID)
-	}
-
-	_template, err := template.New("email_verification").Parse(EMAIL_VERIFICATION_HTML_TEMPLATE)
-	if err != nil {
-		return err
+		LoggerFromContext(ctx).Info().Msgf("Admin is pushing email verification for user %d", user.ID)
+		if _, err := service.AccountActionService.RequestAction(ctx, user.ID, AccountActionVerifyEmail, nil, false); err != nil {
+			return err
+		}
 	}
-	otp := service.OtpService.GenerateOtp(user.ID, PurposeGuestEmailVerification)
 
-	var buffer bytes.Buffer
-	emailTemplate := NewEmailTemplate(user.Name, otp.Code, service.SmtpService.GetSmtpConfig().CompanyName)
-	if err := _template.Execute(&buffer, emailTemplate); err != nil {
-		return err
+	_, err = service.OtpDeliveryService.DeliverOtp(ctx, user, PurposeGuestEmailVerification, DeliveryChannelEmail)
+	action := AuditActionEmailVerificationSent
+	if isAdminPushed {
+		action = AuditActionEmailVerificationPushed
 	}
-
-	emailContent := buffer.String()
-
-	message := service.SmtpService.CreateNewMessage(user.Email, "Email Verification", emailContent, ContentTypeHtml)
-
-	return service.SmtpService.SendEmail(message)
+	service.AuditService.RecordUserAction(ctx, action, user.ID, err)
+	return err
 }
 
 func (service *UserVerificationService) SendVerificationEmailByToken(ctx context.Context, token string) error {
@@ -120,7 +112,7 @@ func (service *UserVerificationService) extractVerificationClaims(claims *jwt.Ma
 	var verificationClaims UserVerificationClaims
 	purpose, ok := (*claims)["purpose"].(string)
 	if !ok || purpose != string(PurposeGuestEmailVerification) {
-		return nil, fmt.Errorf("invalid or missing 'purpose' claim, getting %s, expects %v", purpose, PurposeGuestEmailVerification)
+		return nil, fmt.Errorf("%w: invalid or missing 'purpose' claim, getting %s, expects %v", security.TokenInvalid, purpose, PurposeGuestEmailVerification)
 	}
 	userID, ok := (*claims)["id"].(float64)
 	if !ok {
@@ -135,24 +127,25 @@ func (service *UserVerificationService) extractVerificationClaims(claims *jwt.Ma
 	return &verificationClaims, nil
 }
 
-func (service *UserVerificationService) VerifyEmail(token string, otpCode string) error {
+func (service *UserVerificationService) VerifyEmail(ctx context.Context, token string, otpCode string) error {
 	claims, err := service.parseVerificationClaims(token)
 	if err != nil {
 		return err
 	}
 
 	if err := service.OtpService.VerifyOtp(claims.ID, PurposeGuestEmailVerification, otpCode); err != nil {
+		service.AuditService.RecordUserAction(ctx, AuditActionEmailVerified, claims.ID, err)
 		return err
 	}
-	user, err := service.UserService.GetUserByID(context.Background(), claims.ID)
+	user, err := service.UserService.GetUserByID(ctx, claims.ID)
 	if err != nil {
 		return err
 	}
 
-	if service.AdminPushedEmailVerificationCache.Contains(user.ID) {
-		log.Info().Msgf("user: %d is inside cache, removing from cache upone verification", user.ID)
-		service.AdminPushedEmailVerificationCache.Remove(user.ID)
+	err = service.UserService.ActivateUser(ctx, user)
+	service.AuditService.RecordUserAction(ctx, AuditActionEmailVerified, user.ID, err)
+	if err != nil {
+		return err
 	}
-
-	return service.UserService.ActivateUser(context.Background(), user)
+	return service.AccountActionService.CompleteAction(ctx, user.ID, AccountActionVerifyEmail)
 }
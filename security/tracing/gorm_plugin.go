@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin starts a client span around every statement run with a context, repositories pass the context
+// of the request through WithContext so the statements join its trace.
+type GormPlugin struct {
+	// System is the db.system attribute of the spans.
+	System string
+}
+
+func NewGormPlugin(system string) *GormPlugin {
+	return &GormPlugin{
+		System: system,
+	}
+}
+
+func (plugin *GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (plugin *GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("gorm:create").Register("tracing:before_create", plugin.before("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", plugin.after),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", plugin.before("select")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", plugin.after),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", plugin.before("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", plugin.after),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", plugin.before("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", plugin.after),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", plugin.before("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", plugin.after),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", plugin.before("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", plugin.after),
+	)
+}
+
+func (plugin *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		attributes := []attribute.KeyValue{
+			semconv.DBSystemKey.String(plugin.System),
+			semconv.DBOperationName(operation),
+		}
+		if len(db.Statement.Table) > 0 {
+			attributes = append(attributes, semconv.DBCollectionName(db.Statement.Table))
+		}
+		ctx, span := Tracer().Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// after records the statement with its placeholders, the bound values never reach the span.
+func (plugin *GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
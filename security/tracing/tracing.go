@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+	"strings"
+)
+
+const (
+	InstrumentationName = "go-security"
+	DefaultServiceName  = "go-security"
+
+	ExporterNone   = "none"
+	ExporterOtlp   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var ExporterNotSupported = errors.New("TracingExporterNotSupported")
+
+type Config struct {
+	// Exporter is one of otlp, stdout or none, tracing is disabled when it is empty or none.
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+	// Endpoint is the host:port of the OTLP/HTTP collector, the OTEL_EXPORTER_OTLP_* variables apply when it is empty.
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// SampleRatio is the share of new traces recorded, requests carrying a sampled traceparent are always recorded.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+func (config *Config) GetServiceName() string {
+	if len(config.ServiceName) == 0 {
+		return DefaultServiceName
+	}
+	return config.ServiceName
+}
+
+func (config *Config) GetSampleRatio() float64 {
+	if config.SampleRatio <= 0 || config.SampleRatio > 1 {
+		return 1
+	}
+	return config.SampleRatio
+}
+
+// Provider owns the tracer provider installed globally, it flushes the spans left on shutdown.
+type Provider struct {
+	TracerProvider *sdktrace.TracerProvider
+}
+
+// NewProviderFromConfig installs the tracer provider and the W3C trace context propagator globally.
+// Without an exporter the global no-op provider stays in place and spans cost next to nothing.
+func NewProviderFromConfig(config *Config) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config == nil {
+		config = &Config{}
+	}
+	provider := &Provider{}
+	var exporter sdktrace.SpanExporter
+	switch strings.ToLower(config.Exporter) {
+	case "", ExporterNone:
+		return provider, nil
+	case ExporterOtlp:
+		options := []otlptracehttp.Option{}
+		if len(config.Endpoint) > 0 {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		otlpExporter, err := otlptracehttp.New(context.Background(), options...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = otlpExporter
+	case ExporterStdout:
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		exporter = stdoutExporter
+	default:
+		return nil, ExporterNotSupported
+	}
+
+	provider.TracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.GetSampleRatio()))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.GetServiceName()))),
+	)
+	otel.SetTracerProvider(provider.TracerProvider)
+	return provider, nil
+}
+
+func MustNewProviderFromConfig(config *Config) *Provider {
+	provider, err := NewProviderFromConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	return provider
+}
+
+func (provider *Provider) PostConstruct() {}
+
+// PreDestroy exports the spans still buffered.
+func (provider *Provider) PreDestroy(ctx context.Context) error {
+	if provider == nil || provider.TracerProvider == nil {
+		return nil
+	}
+	return provider.TracerProvider.Shutdown(ctx)
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start starts a span, child of the span in the context if any.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// SetUser tags the span of the context with the authenticated user.
+func SetUser(ctx context.Context, userID uint, roleName string) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		semconv.EnduserID(strconv.FormatUint(uint64(userID), 10)),
+		semconv.EnduserRole(roleName),
+	)
+}
@@ -0,0 +1,107 @@
+package tracing_test
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"go-security/security/repository"
+	"go-security/security/service"
+	"go-security/security/tracing"
+	web "go-security/security/web/middleware"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testEmail = "alice@example.com"
+
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+// newTestEngine builds the statements without running them, no database is needed.
+func newTestEngine(t *testing.T) *gorm.DB {
+	t.Helper()
+	engine, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Use(tracing.NewGormPlugin("postgresql")); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("span %s not found", name)
+	return tracetest.SpanStub{}
+}
+
+func TestRequestSpanHierarchy(t *testing.T) {
+	exporter := newTestExporter(t)
+	userService := service.NewUserService(repository.NewUserRepository(newTestEngine(t)))
+	engine := echo.New()
+	engine.Use(web.TracingMiddlewareFunc)
+	engine.GET("/users/:email", func(ctx echo.Context) error {
+		_, err := userService.GetUserByEmail(ctx.Request().Context(), ctx.Param("email"))
+		return err
+	})
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/"+testEmail, nil))
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "GET /users/:email")
+	userSpan := findSpan(t, spans, "UserService.GetUserByEmail")
+	query := findSpan(t, spans, "gorm.select")
+	if userSpan.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Fatal("the service span must be a child of the server span")
+	}
+	if query.Parent.SpanID() != userSpan.SpanContext.SpanID() {
+		t.Fatal("the statement span must be a child of the service span")
+	}
+	if query.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Fatal("the spans must share the trace of the request")
+	}
+}
+
+func TestStatementSpanHidesBoundValues(t *testing.T) {
+	exporter := newTestExporter(t)
+	userRepository := repository.NewUserRepository(newTestEngine(t))
+	ctx, span := tracing.Start(context.Background(), "test")
+	if _, err := userRepository.FindByEmail(ctx, testEmail); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	query := findSpan(t, exporter.GetSpans(), "gorm.select")
+	var text string
+	for _, attribute := range query.Attributes {
+		if attribute.Key == semconv.DBQueryTextKey {
+			text = attribute.Value.AsString()
+		}
+	}
+	if !strings.Contains(text, "email = $1") {
+		t.Fatalf("expected the statement with its placeholders, got %q", text)
+	}
+	if strings.Contains(text, testEmail) {
+		t.Fatalf("the bound values must not reach the span, got %q", text)
+	}
+}
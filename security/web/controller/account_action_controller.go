@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"go-security/security/repository"
+	"go-security/security/service"
+	web "go-security/security/web/middleware"
+	"net/http"
+	"time"
+)
+
+type AccountActionController struct {
+	Router                  *echo.Group
+	UserService             *service.UserService
+	AccountActionService    *service.AccountActionService
+	UserVerificationService *service.UserVerificationService
+}
+
+func NewAccountActionController(routerGroup *echo.Group, userService *service.UserService, accountActionService *service.AccountActionService, userVerificationService *service.UserVerificationService) *AccountActionController {
+	return &AccountActionController{
+		Router:                  routerGroup,
+		UserService:             userService,
+		AccountActionService:    accountActionService,
+		UserVerificationService: userVerificationService,
+	}
+}
+
+func (controller *AccountActionController) RegisterRoutes() {
+	adminRole, err := controller.UserService.GetRoleByName(context.Background(), service.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+	controller.Router.GET("/private/account-actions", controller.GetPendingActions)
+	controller.Router.POST("/private/account-actions/accept-terms", controller.AcceptTerms)
+	controller.Router.POST("/private/admin/account-actions", web.RoleRequired(adminRole, controller.RequestAction))
+}
+
+func (controller *AccountActionController) GetPendingActions(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	requests, err := controller.AccountActionService.GetPendingActions(ctx.Request().Context(), userClaims.ID)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, requests)
+}
+
+func (controller *AccountActionController) AcceptTerms(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	if err := controller.AccountActionService.CompleteAction(ctx.Request().Context(), userClaims.ID, repository.AccountActionAcceptTerms); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// RequestAction lets an admin ask a user to perform an action, a required action restricts the next
+// logins of the user to the endpoints completing it. A verification request also emails the verification code.
+func (controller *AccountActionController) RequestAction(ctx echo.Context) error {
+	var schema struct {
+		UserID   uint                     `json:"user_id"`
+		Action   repository.AccountAction `json:"action"`
+		DueAt    *time.Time               `json:"due_at"`
+		Required bool                     `json:"required"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	request, err := controller.AccountActionService.RequestAction(ctx.Request().Context(), schema.UserID, schema.Action, schema.DueAt, schema.Required)
+	if err != nil {
+		return err
+	}
+	if schema.Action == repository.AccountActionVerifyEmail {
+		if err := controller.UserVerificationService.SendVerificationEmailByUserID(ctx.Request().Context(), schema.UserID, true); err != nil {
+			return err
+		}
+	}
+	return ctx.JSON(http.StatusCreated, request)
+}
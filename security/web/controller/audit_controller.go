@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go-security/security/repository"
+	"go-security/security/service"
+	web "go-security/security/web/middleware"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+type AuditController struct {
+	Router       *echo.Group
+	UserService  *service.UserService
+	AuditService *service.AuditService
+}
+
+func NewAuditController(routerGroup *echo.Group, userService *service.UserService, auditService *service.AuditService) *AuditController {
+	return &AuditController{
+		Router:       routerGroup,
+		UserService:  userService,
+		AuditService: auditService,
+	}
+}
+
+func (controller *AuditController) RegisterRoutes() {
+	adminRole, err := controller.UserService.GetRoleByName(context.Background(), service.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+	controller.Router.GET("/private/admin/audit-events", web.RoleRequired(adminRole, controller.GetEvents))
+	controller.Router.GET("/private/admin/audit-events/export", web.RoleRequired(adminRole, controller.ExportEvents))
+	controller.Router.GET("/private/admin/audit-events/verify", web.RoleRequired(adminRole, controller.VerifyChain))
+	controller.Router.POST("/private/admin/audit-events/checkpoints", web.RoleRequired(adminRole, controller.Checkpoint))
+}
+
+type auditEventQuery struct {
+	ActorID   *uint      `query:"actor_id"`
+	SubjectID *uint      `query:"subject_id"`
+	Action    string     `query:"action"`
+	Outcome   string     `query:"outcome"`
+	IPAddress string     `query:"ip_address"`
+	From      *time.Time `query:"from"`
+	To        *time.Time `query:"to"`
+	Page      int        `query:"page"`
+	PageSize  int        `query:"page_size"`
+	Format    string     `query:"format"`
+}
+
+func (query *auditEventQuery) AsFilter() *repository.AuditEventFilter {
+	return &repository.AuditEventFilter{
+		ActorID:   query.ActorID,
+		SubjectID: query.SubjectID,
+		Action:    query.Action,
+		Outcome:   repository.AuditOutcome(query.Outcome),
+		IPAddress: query.IPAddress,
+		From:      query.From,
+		To:        query.To,
+	}
+}
+
+func (controller *AuditController) GetEvents(ctx echo.Context) error {
+	var query auditEventQuery
+	if err := ctx.Bind(&query); err != nil {
+		return err
+	}
+	page, err := controller.AuditService.GetEvents(ctx.Request().Context(), query.AsFilter(), query.Page, query.PageSize)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// ExportEvents sends the matching events as NDJSON (default) or CSV, selected by the format query parameter.
+// The export is spooled to a temporary file first, so a failure midway is reported as an error rather
+// than as a truncated file with a 200 status.
+func (controller *AuditController) ExportEvents(ctx echo.Context) error {
+	var query auditEventQuery
+	if err := ctx.Bind(&query); err != nil {
+		return err
+	}
+	format := service.AuditExportFormat(query.Format)
+	contentType, extension := "application/x-ndjson", "ndjson"
+	switch format {
+	case service.AuditExportFormatCsv:
+		contentType, extension = "text/csv", "csv"
+	case service.AuditExportFormatNdjson, "":
+	default:
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unsupported export format: %s", query.Format)})
+	}
+	spool, err := os.CreateTemp("", "audit-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if err := controller.AuditService.Export(ctx.Request().Context(), query.AsFilter(), format, spool); err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=audit-events-%s.%s", time.Now().UTC().Format("20060102T150405Z"), extension))
+	return ctx.Stream(http.StatusOK, contentType, spool)
+}
+
+func (controller *AuditController) VerifyChain(ctx echo.Context) error {
+	report, err := controller.AuditService.VerifyChain(ctx.Request().Context())
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+func (controller *AuditController) Checkpoint(ctx echo.Context) error {
+	checkpoint, err := controller.AuditService.Checkpoint(ctx.Request().Context())
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+	return ctx.JSON(http.StatusCreated, checkpoint)
+}
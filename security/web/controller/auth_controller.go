@@ -14,15 +14,17 @@ type AuthController struct {
 	UserResetPasswordService *service.UserResetPasswordService
 	UserVerificationService  *service.UserVerificationService
 	UserService              *service.UserService
+	CsrfService              *service.CsrfService
 }
 
-func NewAuthController(routerGroup *echo.Group, authService *service.AuthService, userResetPasswordService *service.UserResetPasswordService, userVerificationService *service.UserVerificationService, userService *service.UserService, securityConfig *service.SecurityConfig) *AuthController {
+func NewAuthController(routerGroup *echo.Group, authService *service.AuthService, userResetPasswordService *service.UserResetPasswordService, userVerificationService *service.UserVerificationService, userService *service.UserService, csrfService *service.CsrfService, securityConfig *service.SecurityConfig) *AuthController {
 	return &AuthController{
 		Router:                   routerGroup,
 		AuthService:              authService,
 		UserResetPasswordService: userResetPasswordService,
 		UserVerificationService:  userVerificationService,
 		UserService:              userService,
+		CsrfService:              csrfService,
 		SecurityConfig:           securityConfig,
 	}
 }
@@ -39,12 +41,18 @@ func (controller *AuthController) RegisterRoutes() {
 	controller.Router.POST("/public/issue-reset-password-token", controller.IssueResetPasswordToken)
 
 	controller.Router.POST("/public/reset-password", controller.ResetPassword)
+	controller.Router.POST("/public/report-login", controller.ReportLogin)
+	controller.Router.POST("/private/change-password", controller.ChangePassword)
+	controller.Router.POST("/private/refresh-login-token", controller.RefreshLoginToken)
 
 	controller.Router.GET("/private/issue-verification-token", controller.IssueVerificationToken)
 	controller.Router.GET("/private/is-admin-pushed-email-verification", controller.IsAdminPushedEmailVerification)
 	controller.Router.POST("/private/verify-email", controller.VerifyEmail)
 
 	controller.Router.GET("/private/logout", controller.Logout)
+	controller.Router.GET("/private/csrf-token", controller.IssueCsrfToken)
+	controller.Router.GET("/public/csrf-token", controller.IssueCsrfToken)
+	controller.Router.POST("/private/locale", controller.UpdateLocale)
 	controller.Router.GET("/private/redirect-url", controller.GetRedirectURL)
 }
 
@@ -64,7 +72,7 @@ func (controller *AuthController) GetRedirectURL(ctx echo.Context) error {
 func (controller *AuthController) GetUser(ctx echo.Context) error {
 	userClaims, err := ExtractUserClaims(ctx)
 	if err != nil {
-		WriteCookie(&ctx, CookieName, "", -1*time.Hour)
+		ClearLoginCookies(ctx, controller.SecurityConfig)
 		return err
 	}
 
@@ -76,7 +84,10 @@ func (controller *AuthController) IsAdminPushedEmailVerification(ctx echo.Contex
 	if err != nil {
 		return err
 	}
-	isAskingVerification := controller.UserVerificationService.IsAdminAskingForVerification(userClaims.ID)
+	isAskingVerification, err := controller.UserVerificationService.IsAdminAskingForVerification(ctx.Request().Context(), userClaims.ID)
+	if err != nil {
+		return err
+	}
 
 	return ctx.JSON(http.StatusOK, map[string]bool{"is_admin_pushed": isAskingVerification})
 
@@ -113,15 +124,45 @@ func (controller *AuthController) Login(ctx echo.Context) error {
 	if err != nil {
 		return err
 	}
-	WriteCookie(&ctx, CookieName, token, 24*time.Hour)
-	return ctx.NoContent(http.StatusOK)
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
+}
+
+// RefreshLoginToken reissues the login token of the current user, lifting its restriction once the required actions are done.
+func (controller *AuthController) RefreshLoginToken(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := controller.AuthService.RefreshLoginToken(ctx.Request().Context(), userClaims, time.Hour)
+	if err != nil {
+		return err
+	}
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
 }
 
 func (controller *AuthController) Logout(ctx echo.Context) error {
-	WriteCookie(&ctx, CookieName, "", -1*time.Hour)
+	if userClaims, err := ExtractUserClaims(ctx); err == nil {
+		if err := controller.AuthService.Logout(ctx.Request().Context(), userClaims); err != nil {
+			return err
+		}
+	}
+	ClearLoginCookies(ctx, controller.SecurityConfig)
 	return ctx.NoContent(http.StatusOK)
 }
 
+// IssueCsrfToken issues a token bound to the session of the current user, or an anonymous one for the public routes.
+func (controller *AuthController) IssueCsrfToken(ctx echo.Context) error {
+	binding := service.CsrfAnonymousBinding
+	if userClaims, err := ExtractUserClaims(ctx); err == nil {
+		binding = service.CsrfBinding(userClaims.SessionID, userClaims.ID)
+	}
+	csrfToken, err := WriteCsrfCookie(ctx, controller.SecurityConfig, controller.CsrfService, binding, 24*time.Hour)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"csrf_token": csrfToken})
+}
+
 // can be also used for resending.
 func (controller *AuthController) SendResetPasswordEmail(ctx echo.Context) error {
 	var resetPasswordSchema struct {
@@ -137,6 +178,21 @@ func (controller *AuthController) SendResetPasswordEmail(ctx echo.Context) error
 	return ctx.JSON(http.StatusOK, map[string]string{"token": token})
 }
 
+func (controller *AuthController) SendResetPasswordCode(ctx echo.Context) error {
+	var resetPasswordSchema struct {
+		Token   string                  `json:"token"`
+		Channel service.DeliveryChannel `json:"channel"`
+	}
+	if err := ctx.Bind(&resetPasswordSchema); err != nil {
+		return err
+	}
+	token, err := controller.UserResetPasswordService.SendResetPasswordCode(ctx.Request().Context(), resetPasswordSchema.Token, resetPasswordSchema.Channel)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"token": token})
+}
+
 func (controller *AuthController) ResetPassword(ctx echo.Context) error {
 	var resetPasswordSchema struct {
 		Token             string `json:"token"`
@@ -161,6 +217,33 @@ func (controller *AuthController) ResetPassword(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, map[string]string{"message": "Password has been reset"})
 }
 
+func (controller *AuthController) ChangePassword(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var changePasswordSchema struct {
+		CurrentPassword   string `json:"current_password"`
+		NewPassword       string `json:"new_password"`
+		ConfirmedPassword string `json:"confirmed_password"`
+	}
+	if err := ctx.Bind(&changePasswordSchema); err != nil {
+		return err
+	}
+	err = controller.UserResetPasswordService.ChangePassword(
+		ctx.Request().Context(),
+		userClaims.ID,
+		userClaims.SessionID,
+		changePasswordSchema.CurrentPassword,
+		changePasswordSchema.NewPassword,
+		changePasswordSchema.ConfirmedPassword,
+	)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "Password has been changed"})
+}
+
 func (controller *AuthController) IssueVerificationToken(ctx echo.Context) error {
 	// for verification, we can assume that the user is already logged in, but the email is not verified
 	userClaims, err := ExtractUserClaims(ctx)
@@ -188,6 +271,30 @@ func (controller *AuthController) IssueResetPasswordToken(ctx echo.Context) erro
 	return ctx.JSON(http.StatusOK, map[string]string{"token": token})
 }
 
+// ReportLogin handles the "this wasn't me" link of a new sign-in alert. The session is revoked and, for
+// users signing in with a password, a reset code and link are emailed to complete the forced reset.
+func (controller *AuthController) ReportLogin(ctx echo.Context) error {
+	var schema struct {
+		Token string `json:"token"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	user, err := controller.AuthService.ReportLogin(ctx.Request().Context(), schema.Token)
+	if err != nil {
+		return err
+	}
+	if user.Platform.Name != string(service.PlatformSelf) {
+		return ctx.JSON(http.StatusOK, map[string]string{"message": "Session has been revoked"})
+	}
+	if config := controller.AuthService.LoginAlertConfig; config != nil && len(config.ResetUrl) > 0 {
+		if err := controller.UserResetPasswordService.SendResetPasswordLink(ctx.Request().Context(), user, config.ResetUrl); err != nil {
+			return err
+		}
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "Session has been revoked"})
+}
+
 func (controller *AuthController) SendVerificationEmailByToken(ctx echo.Context) error {
 	var schema struct {
 		Token string `json:"token"`
@@ -225,9 +332,26 @@ func (controller *AuthController) VerifyEmail(ctx echo.Context) error {
 	if err := ctx.Bind(&verificationSchema); err != nil {
 		return err
 	}
-	err := controller.UserVerificationService.VerifyEmail(verificationSchema.Token, verificationSchema.Otp)
+	err := controller.UserVerificationService.VerifyEmail(ctx.Request().Context(), verificationSchema.Token, verificationSchema.Otp)
 	if err != nil {
 		return err
 	}
 	return ctx.JSON(http.StatusOK, map[string]string{"message": "Email has been verified"})
 }
+
+func (controller *AuthController) UpdateLocale(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Locale string `json:"locale"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.UserService.UpdateUserLocale(ctx.Request().Context(), userClaims.ID, schema.Locale); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
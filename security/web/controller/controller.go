@@ -2,10 +2,49 @@ package controller
 
 import (
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/labstack/echo/v4"
+	"net"
+	"time"
 )
 
+const DefaultShutdownTimeout = 10 * time.Second
+
 type ServerConfig struct {
 	Port int `yaml:"port"`
+	// ShutdownTimeout bounds the draining of in-flight requests and the PreDestroy hooks on shutdown.
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout"`
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+	// HealthCheckDetails exposes the errors of the failing components on the unauthenticated health and
+	// readiness probes, they may reveal hostnames or credentials problems so they are hidden by default.
+	HealthCheckDetails bool `yaml:"health_check_details"`
+	// TrustedProxies are the CIDR ranges of the reverse proxies whose X-Forwarded-For is believed. Without
+	// them the client IP is the peer address, as anyone could forge the header.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// MetricsToken is the bearer token Prometheus must present to scrape /metrics, the route is open without it.
+	MetricsToken string `yaml:"metrics_token" json:"-" secret:"true"`
+}
+
+// IPExtractor resolves the client IP from X-Forwarded-For, skipping the trusted proxies only.
+func (config *ServerConfig) IPExtractor() (echo.IPExtractor, error) {
+	if len(config.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range config.TrustedProxies {
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+func (config *ServerConfig) GetShutdownTimeout() time.Duration {
+	if config.ShutdownTimeout <= 0 {
+		return DefaultShutdownTimeout
+	}
+	return config.ShutdownTimeout
 }
 
 type Controller interface {
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerConfigIPExtractor(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		peer    string
+		xff     string
+		ip      string
+	}{
+		{"no trusted proxy ignores the header", nil, "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"untrusted peer cannot forge the header", []string{"10.0.0.0/8"}, "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.2:4000", "198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", []string{"10.0.0.0/8"}, "10.0.0.2:4000", "198.51.100.1, 10.0.0.5, 10.0.0.3", "198.51.100.1"},
+		{"forged entry before the client", []string{"10.0.0.0/8"}, "10.0.0.2:4000", "1.2.3.4, 198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"private address not trusted by default", []string{"10.0.0.0/8"}, "10.0.0.2:4000", "198.51.100.1, 192.168.1.9", "192.168.1.9"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := &ServerConfig{TrustedProxies: test.proxies}
+			extractor, err := config.IPExtractor()
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = test.peer
+			request.Header.Set(echo.HeaderXForwardedFor, test.xff)
+			if ip := extractor(request); ip != test.ip {
+				t.Fatalf("expected %s, got %s", test.ip, ip)
+			}
+		})
+	}
+}
+
+func TestServerConfigIPExtractorRejectsInvalidRanges(t *testing.T) {
+	config := &ServerConfig{TrustedProxies: []string{"10.0.0.1"}}
+	if _, err := config.IPExtractor(); err == nil {
+		t.Fatal("expected an address without a mask to be rejected")
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"go-security/security/repository"
+	"go-security/security/service"
+	web "go-security/security/web/middleware"
+	"net/http"
+	"strconv"
+)
+
+type EmailOutboxController struct {
+	Router             *echo.Group
+	UserService        *service.UserService
+	EmailOutboxService *service.EmailOutboxService
+}
+
+func NewEmailOutboxController(routerGroup *echo.Group, userService *service.UserService, emailOutboxService *service.EmailOutboxService) *EmailOutboxController {
+	return &EmailOutboxController{
+		Router:             routerGroup,
+		UserService:        userService,
+		EmailOutboxService: emailOutboxService,
+	}
+}
+
+func (controller *EmailOutboxController) RegisterRoutes() {
+	adminRole, err := controller.UserService.GetRoleByName(context.Background(), service.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+	controller.Router.GET("/private/admin/email-outbox", web.RoleRequired(adminRole, controller.GetMessages))
+	controller.Router.GET("/private/admin/email-outbox/:id", web.RoleRequired(adminRole, controller.GetMessage))
+	controller.Router.POST("/private/admin/email-outbox/:id/retry", web.RoleRequired(adminRole, controller.RetryMessage))
+}
+
+func (controller *EmailOutboxController) GetMessages(ctx echo.Context) error {
+	var query struct {
+		Status   string `query:"status"`
+		Page     int    `query:"page"`
+		PageSize int    `query:"page_size"`
+	}
+	if err := ctx.Bind(&query); err != nil {
+		return err
+	}
+	page, err := controller.EmailOutboxService.GetMessages(ctx.Request().Context(), repository.EmailOutboxStatus(query.Status), query.Page, query.PageSize)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
+
+func (controller *EmailOutboxController) GetMessage(ctx echo.Context) error {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid message id")
+	}
+	message, err := controller.EmailOutboxService.GetMessage(ctx.Request().Context(), uint(id))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, message)
+}
+
+func (controller *EmailOutboxController) RetryMessage(ctx echo.Context) error {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid message id")
+	}
+	message, err := controller.EmailOutboxService.Retry(ctx.Request().Context(), uint(id))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusAccepted, message)
+}
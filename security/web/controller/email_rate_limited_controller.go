@@ -27,5 +27,6 @@ func (controller *EmailRateLimitedController) RegisterRoutes() {
 	controller.Router.POST("/private/send-verification-email-by-user-id", web.RoleRequired(supperAdmin, controller.AdminSendVerificationEmailByUserID))
 
 	controller.Router.POST("/public/send-reset-password-email", controller.SendResetPasswordEmail)
+	controller.Router.POST("/public/send-reset-password-code", controller.SendResetPasswordCode)
 	controller.Router.POST("/private/send-verification-email-by-token", controller.SendVerificationEmailByToken)
 }
@@ -4,20 +4,20 @@ import (
 	"github.com/labstack/echo/v4"
 	"go-security/security/service"
 	"go-security/security/service/oauth"
-	"net/http"
-	"time"
 )
 
 type GoogleAuthController struct {
 	SecurityConfig    *service.SecurityConfig
 	Router            *echo.Group
 	GoogleAuthService *oauth.GoogleAuthService
+	CsrfService       *service.CsrfService
 }
 
-func NewGoogleAuthController(routerGroup *echo.Group, googleAuthService *oauth.GoogleAuthService, securityConfig *service.SecurityConfig) *GoogleAuthController {
+func NewGoogleAuthController(routerGroup *echo.Group, googleAuthService *oauth.GoogleAuthService, csrfService *service.CsrfService, securityConfig *service.SecurityConfig) *GoogleAuthController {
 	return &GoogleAuthController{
 		Router:            routerGroup,
 		GoogleAuthService: googleAuthService,
+		CsrfService:       csrfService,
 		SecurityConfig:    securityConfig,
 	}
 }
@@ -35,7 +35,5 @@ func (controller *GoogleAuthController) RegisterAndLogin(ctx echo.Context) error
 	if err != nil {
 		return err
 	}
-	expiration := time.Until(time.Unix(googleUser.Expiration, 0))
-	WriteCookie(&ctx, CookieName, token, expiration)
-	return ctx.NoContent(http.StatusOK)
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
 }
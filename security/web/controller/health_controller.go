@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	web "go-security/security/web/middleware"
+	"net/http"
+)
+
+type HealthController struct {
+	Engine        *echo.Echo
+	HealthService *service.HealthService
+	// ExposeErrors keeps the errors of the failing components in the reports, see ServerConfig.HealthCheckDetails.
+	ExposeErrors bool
+}
+
+func NewHealthController(engine *echo.Echo, healthService *service.HealthService, exposeErrors bool) *HealthController {
+	return &HealthController{
+		Engine:        engine,
+		HealthService: healthService,
+		ExposeErrors:  exposeErrors,
+	}
+}
+
+func (controller *HealthController) RegisterRoutes() {
+	controller.Engine.GET(web.LivenessRoute, controller.Liveness)
+	controller.Engine.GET(web.HealthRoute, controller.Health)
+	controller.Engine.GET(web.ReadinessRoute, controller.Readiness)
+}
+
+// Liveness only tells the process serves requests, it never runs the checks so a failing
+// dependency does not get the process restarted.
+func (controller *HealthController) Liveness(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]service.HealthStatus{"status": service.HealthStatusUp})
+}
+
+// Health reports the status of every component, a degraded application still answers 200.
+func (controller *HealthController) Health(ctx echo.Context) error {
+	report := controller.check(ctx)
+	if report.Status == service.HealthStatusDown {
+		return ctx.JSON(http.StatusServiceUnavailable, report)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// Readiness answers 503 until the services are constructed, while shutting down and while a critical component is down.
+func (controller *HealthController) Readiness(ctx echo.Context) error {
+	report := controller.check(ctx)
+	if !report.Ready || report.Status == service.HealthStatusDown {
+		return ctx.JSON(http.StatusServiceUnavailable, report)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+func (controller *HealthController) check(ctx echo.Context) *service.HealthReport {
+	report := controller.HealthService.Check(ctx.Request().Context())
+	if !controller.ExposeErrors {
+		for _, component := range report.Components {
+			component.Error = ""
+		}
+	}
+	return report
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go-security/security"
+	"go-security/security/service"
+	web "go-security/security/web/middleware"
+)
+
+type ImpersonationController struct {
+	Router         *echo.Group
+	AuthService    *service.AuthService
+	UserService    *service.UserService
+	CsrfService    *service.CsrfService
+	SecurityConfig *service.SecurityConfig
+}
+
+func NewImpersonationController(routerGroup *echo.Group, authService *service.AuthService, userService *service.UserService, csrfService *service.CsrfService, securityConfig *service.SecurityConfig) *ImpersonationController {
+	return &ImpersonationController{
+		Router:         routerGroup,
+		AuthService:    authService,
+		UserService:    userService,
+		CsrfService:    csrfService,
+		SecurityConfig: securityConfig,
+	}
+}
+
+func (controller *ImpersonationController) RegisterRoutes() {
+	superAdminRole, err := controller.UserService.GetRoleByName(context.Background(), service.RoleSuperAdmin)
+	if err != nil {
+		panic(err)
+	}
+	controller.Router.POST("/private/admin/impersonate", web.RoleRequired(superAdminRole, controller.StartImpersonation))
+	controller.Router.POST("/private/impersonation/stop", controller.StopImpersonation)
+}
+
+// StartImpersonation replaces the login token of the super admin with one of the target user for
+// ImpersonationTokenExpiration, GET /private/current-user then reports the impersonator_id.
+func (controller *ImpersonationController) StartImpersonation(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	token, err := controller.AuthService.StartImpersonation(ctx.Request().Context(), userClaims, schema.UserID)
+	if err != nil {
+		return err
+	}
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
+}
+
+// StopImpersonation ends the impersonation and returns the super admin to the session they started it
+// from, the login cookies are cleared when that session ended meanwhile.
+func (controller *ImpersonationController) StopImpersonation(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := controller.AuthService.StopImpersonation(ctx.Request().Context(), userClaims)
+	if errors.Is(err, security.ImpersonatorSessionEnded) {
+		ClearLoginCookies(ctx, controller.SecurityConfig)
+	}
+	if err != nil {
+		return err
+	}
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+	"time"
+)
+
+type MagicLinkController struct {
+	Router           *echo.Group
+	MagicLinkService *service.MagicLinkService
+	CsrfService      *service.CsrfService
+	SecurityConfig   *service.SecurityConfig
+}
+
+// NewMagicLinkController expects a rate limited router group, limiting both the emails sent and the codes guessed.
+func NewMagicLinkController(limitedRouter *echo.Group, magicLinkService *service.MagicLinkService, csrfService *service.CsrfService, securityConfig *service.SecurityConfig) *MagicLinkController {
+	return &MagicLinkController{
+		Router:           limitedRouter,
+		MagicLinkService: magicLinkService,
+		CsrfService:      csrfService,
+		SecurityConfig:   securityConfig,
+	}
+}
+
+func (controller *MagicLinkController) RegisterRoutes() {
+	if !controller.MagicLinkService.Config.Enabled {
+		return
+	}
+	controller.Router.POST("/public/magic-link", controller.SendMagicLink)
+	controller.Router.POST("/public/magic-link/login", controller.Login)
+}
+
+func (controller *MagicLinkController) SendMagicLink(ctx echo.Context) error {
+	var schema struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	config := controller.MagicLinkService.Config
+	binding := ""
+	if config.BindToBrowser {
+		var err error
+		if binding, err = controller.MagicLinkService.IssueBinding(); err != nil {
+			return err
+		}
+		WriteCookie(&ctx, controller.SecurityConfig.GetCookieConfig(), config.GetBindingCookieName(), binding, config.GetTtl())
+	}
+	if err := controller.MagicLinkService.SendMagicLink(ctx.Request().Context(), schema.Email, binding); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusAccepted, map[string]string{"message": "If the email is registered, a sign-in link has been sent"})
+}
+
+// Login exchanges either the token of the link or the emailed code for a login token.
+func (controller *MagicLinkController) Login(ctx echo.Context) error {
+	var schema struct {
+		Token string `json:"token"`
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	config := controller.MagicLinkService.Config
+	expiration := 24 * time.Hour
+	var token string
+	var err error
+	if len(schema.Token) > 0 {
+		binding := ""
+		if cookie, cookieErr := ctx.Cookie(config.GetBindingCookieName()); cookieErr == nil {
+			binding = cookie.Value
+		}
+		token, err = controller.MagicLinkService.LoginWithToken(ctx.Request().Context(), schema.Token, binding, expiration)
+	} else {
+		token, err = controller.MagicLinkService.LoginWithCode(ctx.Request().Context(), schema.Email, schema.Code, expiration)
+	}
+	if err != nil {
+		return err
+	}
+	if config.BindToBrowser {
+		WriteCookie(&ctx, controller.SecurityConfig.GetCookieConfig(), config.GetBindingCookieName(), "", -1*time.Hour)
+	}
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
+}
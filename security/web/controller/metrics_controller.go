@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"github.com/labstack/echo/v4"
+	"go-security/security/metrics"
+	web "go-security/security/web/middleware"
+	"net/http"
+)
+
+type MetricsController struct {
+	Engine *echo.Echo
+	// Token is the bearer token required to scrape the metrics, empty leaves them open.
+	Token string
+}
+
+func NewMetricsController(engine *echo.Echo, token string) *MetricsController {
+	return &MetricsController{
+		Engine: engine,
+		Token:  token,
+	}
+}
+
+func (controller *MetricsController) RegisterRoutes() {
+	controller.Engine.GET(web.MetricsRoute, echo.WrapHandler(metrics.Handler()), controller.requireToken)
+}
+
+func (controller *MetricsController) requireToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if len(controller.Token) == 0 {
+			return next(ctx)
+		}
+		token, err := web.NewBearerTokenExtractor().Extract(ctx)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(controller.Token)) != 1 {
+			return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": web.LoginRequired.Error()})
+		}
+		return next(ctx)
+	}
+}
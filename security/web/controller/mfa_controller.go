@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+	"time"
+)
+
+type MfaController struct {
+	Router         *echo.Group
+	UserMfaService *service.UserMfaService
+	CsrfService    *service.CsrfService
+	SecurityConfig *service.SecurityConfig
+}
+
+func NewMfaController(routerGroup *echo.Group, userMfaService *service.UserMfaService, csrfService *service.CsrfService, securityConfig *service.SecurityConfig) *MfaController {
+	return &MfaController{
+		Router:         routerGroup,
+		UserMfaService: userMfaService,
+		CsrfService:    csrfService,
+		SecurityConfig: securityConfig,
+	}
+}
+
+func (controller *MfaController) RegisterRoutes() {
+	controller.Router.POST("/private/mfa/enroll", controller.StartEnrollment)
+	controller.Router.POST("/private/mfa/confirm", controller.ConfirmEnrollment)
+	controller.Router.POST("/private/mfa/code", controller.SendCode)
+	controller.Router.POST("/private/mfa/challenge", controller.VerifyChallenge)
+	controller.Router.POST("/private/mfa/disable", controller.Disable)
+}
+
+func (controller *MfaController) StartEnrollment(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Channel service.DeliveryChannel `json:"channel"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.UserMfaService.StartEnrollment(ctx.Request().Context(), userClaims.ID, schema.Channel); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+func (controller *MfaController) ConfirmEnrollment(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Channel service.DeliveryChannel `json:"channel"`
+		Otp     string                  `json:"otp"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.UserMfaService.ConfirmEnrollment(ctx.Request().Context(), userClaims.ID, schema.Channel, schema.Otp); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "Two-factor authentication has been enabled"})
+}
+
+func (controller *MfaController) SendCode(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	if err := controller.UserMfaService.SendCode(ctx.Request().Context(), userClaims.ID); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// VerifyChallenge exchanges the MFA challenge token of the request and the sign-in code for a login token.
+func (controller *MfaController) VerifyChallenge(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Otp string `json:"otp"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	token, err := controller.UserMfaService.VerifyChallenge(ctx.Request().Context(), userClaims, schema.Otp, time.Hour)
+	if err != nil {
+		return err
+	}
+	return WriteLoginToken(ctx, controller.SecurityConfig, controller.CsrfService, token)
+}
+
+func (controller *MfaController) Disable(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Otp string `json:"otp"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.UserMfaService.Disable(ctx.Request().Context(), userClaims.ID, schema.Otp); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "Two-factor authentication has been disabled"})
+}
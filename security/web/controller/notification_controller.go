@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+)
+
+type NotificationController struct {
+	Router              *echo.Group
+	NotificationService *service.NotificationService
+}
+
+func NewNotificationController(routerGroup *echo.Group, notificationService *service.NotificationService) *NotificationController {
+	return &NotificationController{
+		Router:              routerGroup,
+		NotificationService: notificationService,
+	}
+}
+
+func (controller *NotificationController) RegisterRoutes() {
+	controller.Router.GET("/private/notification-preferences", controller.GetPreferences)
+	controller.Router.PUT("/private/notification-preferences", controller.UpdatePreference)
+}
+
+func (controller *NotificationController) GetPreferences(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	preferences, err := controller.NotificationService.GetPreferences(ctx.Request().Context(), userClaims.ID)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, preferences)
+}
+
+func (controller *NotificationController) UpdatePreference(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Channel service.NotificationChannel `json:"channel"`
+		Enabled bool                        `json:"enabled"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.NotificationService.UpdatePreference(ctx.Request().Context(), userClaims.ID, schema.Channel, schema.Enabled); err != nil {
+		return err
+	}
+	return controller.GetPreferences(ctx)
+}
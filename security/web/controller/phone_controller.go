@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+)
+
+type PhoneController struct {
+	Router                       *echo.Group
+	UserPhoneVerificationService *service.UserPhoneVerificationService
+}
+
+func NewPhoneController(routerGroup *echo.Group, userPhoneVerificationService *service.UserPhoneVerificationService) *PhoneController {
+	return &PhoneController{
+		Router:                       routerGroup,
+		UserPhoneVerificationService: userPhoneVerificationService,
+	}
+}
+
+func (controller *PhoneController) RegisterRoutes() {
+	controller.Router.POST("/private/phone-number", controller.RegisterPhoneNumber)
+	controller.Router.POST("/private/verify-phone-number", controller.VerifyPhoneNumber)
+	controller.Router.DELETE("/private/phone-number", controller.RemovePhoneNumber)
+}
+
+func (controller *PhoneController) RegisterPhoneNumber(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		PhoneNumber string `json:"phone_number"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.UserPhoneVerificationService.RegisterPhoneNumber(ctx.Request().Context(), userClaims.ID, schema.PhoneNumber); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+func (controller *PhoneController) VerifyPhoneNumber(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var schema struct {
+		Otp string `json:"otp"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if err := controller.UserPhoneVerificationService.VerifyPhoneNumber(ctx.Request().Context(), userClaims.ID, schema.Otp); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "Phone number has been verified"})
+}
+
+func (controller *PhoneController) RemovePhoneNumber(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	if err := controller.UserPhoneVerificationService.RemovePhoneNumber(ctx.Request().Context(), userClaims.ID); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"go-security/security/repository"
+	"go-security/security/service"
+	web "go-security/security/web/middleware"
+	"net/http"
+	"strconv"
+)
+
+type SessionController struct {
+	Router         *echo.Group
+	UserService    *service.UserService
+	SessionService *service.SessionService
+}
+
+func NewSessionController(routerGroup *echo.Group, userService *service.UserService, sessionService *service.SessionService) *SessionController {
+	return &SessionController{
+		Router:         routerGroup,
+		UserService:    userService,
+		SessionService: sessionService,
+	}
+}
+
+func (controller *SessionController) RegisterRoutes() {
+	adminRole, err := controller.UserService.GetRoleByName(context.Background(), service.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+	controller.Router.GET("/private/sessions", controller.GetSessions)
+	controller.Router.DELETE("/private/sessions/:id", controller.RevokeSession)
+	controller.Router.GET("/private/login-history", controller.GetLoginHistory)
+	controller.Router.GET("/private/admin/users/:id/sessions", web.RoleRequired(adminRole, controller.GetUserSessions))
+	controller.Router.DELETE("/private/admin/sessions/:id", web.RoleRequired(adminRole, controller.RevokeAnySession))
+}
+
+type sessionResponse struct {
+	*repository.Session
+	Current bool `json:"current"`
+}
+
+func toSessionResponses(sessions []*repository.Session, currentID string) []sessionResponse {
+	responses := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, sessionResponse{Session: session, Current: session.ID == currentID})
+	}
+	return responses
+}
+
+// GetSessions lists the active sessions of the current user, flagging the one making the request.
+func (controller *SessionController) GetSessions(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	sessions, err := controller.SessionService.GetActiveSessions(ctx.Request().Context(), userClaims.ID)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, toSessionResponses(sessions, userClaims.SessionID))
+}
+
+func (controller *SessionController) RevokeSession(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	if err := controller.SessionService.RevokeUserSession(ctx.Request().Context(), userClaims.ID, ctx.Param("id")); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (controller *SessionController) GetLoginHistory(ctx echo.Context) error {
+	userClaims, err := ExtractUserClaims(ctx)
+	if err != nil {
+		return err
+	}
+	var query struct {
+		Page     int `query:"page"`
+		PageSize int `query:"page_size"`
+	}
+	if err := ctx.Bind(&query); err != nil {
+		return err
+	}
+	page, err := controller.SessionService.GetLoginHistory(ctx.Request().Context(), userClaims.ID, query.Page, query.PageSize)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, page)
+}
+
+func (controller *SessionController) GetUserSessions(ctx echo.Context) error {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return err
+	}
+	sessions, err := controller.SessionService.GetActiveSessions(ctx.Request().Context(), uint(id))
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, toSessionResponses(sessions, ""))
+}
+
+func (controller *SessionController) RevokeAnySession(ctx echo.Context) error {
+	if err := controller.SessionService.RevokeSession(ctx.Request().Context(), ctx.Param("id")); err != nil {
+		return err
+	}
+	return ctx.NoContent(http.StatusOK)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"github.com/labstack/echo/v4"
+	"go-security/security/repository"
 	"go-security/security/service"
 	web "go-security/security/web/middleware"
 	"net/http"
@@ -30,6 +31,8 @@ func (controller *UserController) RegisterRoutes() {
 		panic(err)
 	}
 	controller.Router.GET("/private/user", web.RoleRequired(adminRole, controller.GetUser))
+	controller.Router.GET("/private/admin/roles", web.RoleRequired(adminRole, controller.GetRoles))
+	controller.Router.PUT("/private/admin/roles/:name/session-limit", web.RoleRequired(adminRole, controller.UpdateRoleSessionLimit))
 }
 
 func (controller *UserController) GetUser(ctx echo.Context) error {
@@ -39,3 +42,30 @@ func (controller *UserController) GetUser(ctx echo.Context) error {
 	}
 	return ctx.JSON(http.StatusOK, users)
 }
+
+func (controller *UserController) GetRoles(ctx echo.Context) error {
+	roles, err := controller.UserService.GetAllRoles(ctx.Request().Context())
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, roles)
+}
+
+// UpdateRoleSessionLimit sets the maximum of active sessions per user of the role, zero lifts the limit.
+func (controller *UserController) UpdateRoleSessionLimit(ctx echo.Context) error {
+	var schema struct {
+		MaxSessions uint                          `json:"max_sessions"`
+		Policy      repository.SessionLimitPolicy `json:"policy"`
+	}
+	if err := ctx.Bind(&schema); err != nil {
+		return err
+	}
+	if len(schema.Policy) == 0 {
+		schema.Policy = repository.SessionLimitPolicyEvictOldest
+	}
+	role, err := controller.UserService.UpdateRoleSessionLimit(ctx.Request().Context(), ctx.Param("name"), schema.MaxSessions, schema.Policy)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, role)
+}
@@ -1,20 +1,25 @@
 package controller
 
 import (
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 	"go-security/security/service"
 	web "go-security/security/web/middleware"
 	"net/http"
+	"strings"
 	"time"
 )
 
-func WriteCookie(c *echo.Context, key string, value string, duration time.Duration) {
-	cookie := new(http.Cookie)
-	cookie.Name = key
-	cookie.Value = value
-	cookie.Expires = time.Now().Add(duration)
-	cookie.Path = "/"
-	(*c).SetCookie(cookie)
+const (
+	LoginModeCookie = "cookie"
+	LoginModeToken  = "token"
+
+	LoginModeHeader         = "X-Login-Mode"
+	LoginModeQueryParameter = "mode"
+)
+
+func WriteCookie(c *echo.Context, config *service.CookieConfig, key string, value string, duration time.Duration) {
+	(*c).SetCookie(config.NewCookie(key, value, duration))
 }
 
 func ExtractUserClaims(ctx echo.Context) (*service.UserClaims, error) {
@@ -25,3 +30,83 @@ func ExtractUserClaims(ctx echo.Context) (*service.UserClaims, error) {
 	}
 	return claims, nil
 }
+
+// IsTokenModeRequested reports whether the client asked for the token in the response body,
+// either via the `mode` query parameter or the X-Login-Mode header, instead of a cookie.
+func IsTokenModeRequested(ctx echo.Context) bool {
+	mode := ctx.QueryParam(LoginModeQueryParameter)
+	if len(mode) == 0 {
+		mode = ctx.Request().Header.Get(LoginModeHeader)
+	}
+	return strings.EqualFold(mode, LoginModeToken)
+}
+
+// WriteCsrfCookie issues a CSRF token bound to the binding into a cookie readable by scripts, so the client
+// can echo it back in the header.
+func WriteCsrfCookie(ctx echo.Context, securityConfig *service.SecurityConfig, csrfService *service.CsrfService, binding string, duration time.Duration) (string, error) {
+	csrfToken, err := csrfService.IssueToken(binding)
+	if err != nil {
+		return "", err
+	}
+	cookie := securityConfig.GetCookieConfig().NewCookie(securityConfig.GetCsrfConfig().GetCookieName(), csrfToken, duration)
+	cookie.HttpOnly = false
+	ctx.SetCookie(cookie)
+	return csrfToken, nil
+}
+
+// WriteLoginToken answers the login token in the body or in a cookie, both expiring with the token itself.
+func WriteLoginToken(ctx echo.Context, securityConfig *service.SecurityConfig, csrfService *service.CsrfService, token string) error {
+	claims := issuedLoginTokenClaims(token)
+	duration := loginTokenLifetime(claims)
+	if IsTokenModeRequested(ctx) {
+		return ctx.JSON(http.StatusOK, map[string]any{
+			"token":      token,
+			"token_type": "Bearer",
+			"expires_in": int64(duration.Seconds()),
+		})
+	}
+	WriteCookie(&ctx, securityConfig.GetCookieConfig(), CookieName, token, duration)
+	if !securityConfig.GetCsrfConfig().Enabled {
+		return ctx.NoContent(http.StatusOK)
+	}
+	csrfToken, err := WriteCsrfCookie(ctx, securityConfig, csrfService, csrfBindingOfLoginToken(claims), duration)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"csrf_token": csrfToken})
+}
+
+// issuedLoginTokenClaims reads the claims of a login token issued by this request, so it is not verified again.
+func issuedLoginTokenClaims(token string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return jwt.MapClaims{}
+	}
+	return claims
+}
+
+// loginTokenLifetime is the time left until the exp claim of the token.
+func loginTokenLifetime(claims jwt.MapClaims) time.Duration {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return 0
+	}
+	return max(time.Until(time.Unix(int64(exp), 0)), 0)
+}
+
+func csrfBindingOfLoginToken(claims jwt.MapClaims) string {
+	sessionID, _ := claims["sid"].(string)
+	userID, ok := claims["id"].(float64)
+	if !ok {
+		return service.CsrfAnonymousBinding
+	}
+	return service.CsrfBinding(sessionID, uint(userID))
+}
+
+func ClearLoginCookies(ctx echo.Context, securityConfig *service.SecurityConfig) {
+	cookieConfig := securityConfig.GetCookieConfig()
+	WriteCookie(&ctx, cookieConfig, CookieName, "", -1*time.Hour)
+	if securityConfig.GetCsrfConfig().Enabled {
+		WriteCookie(&ctx, cookieConfig, securityConfig.GetCsrfConfig().GetCookieName(), "", -1*time.Hour)
+	}
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func issueTestToken(t *testing.T, lifetime time.Duration) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":  1,
+		"sid": "session",
+		"exp": time.Now().Add(lifetime).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestWriteLoginTokenExpiresWithToken(t *testing.T) {
+	engine := echo.New()
+	token := issueTestToken(t, 15*time.Minute)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/login?mode=token", nil)
+	if err := WriteLoginToken(engine.NewContext(request, recorder), &service.SecurityConfig{}, nil, token); err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		ExpiresIn int64 `json:"expires_in"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ExpiresIn <= 14*60 || body.ExpiresIn > 15*60 {
+		t.Fatalf("expected expires_in of the token, got %d", body.ExpiresIn)
+	}
+
+	recorder = httptest.NewRecorder()
+	if err := WriteLoginToken(engine.NewContext(httptest.NewRequest(http.MethodPost, "/login", nil), recorder), &service.SecurityConfig{}, nil, token); err != nil {
+		t.Fatal(err)
+	}
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || time.Until(cookies[0].Expires) > 15*time.Minute || time.Until(cookies[0].Expires) < 14*time.Minute {
+		t.Fatalf("expected a cookie expiring with the token, got %v", cookies)
+	}
+}
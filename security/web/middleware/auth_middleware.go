@@ -3,34 +3,99 @@ package web
 import (
 	"errors"
 	"github.com/labstack/echo/v4"
-	"github.com/rs/zerolog/log"
+	"go-security/security"
+	"go-security/security/metrics"
 	"go-security/security/repository"
 	"go-security/security/service"
+	"go-security/security/tracing"
 	"net/http"
+	"slices"
 	"strings"
 )
 
 var (
-	LoginRequired    = errors.New("LoginRequired")
-	PermissionDenied = errors.New("PermissionDenied")
-	RoleKeyRequired  = errors.New("RoleKeyRequired")
+	LoginRequired          = errors.New("LoginRequired")
+	PermissionDenied       = errors.New("PermissionDenied")
+	RoleKeyRequired        = errors.New("RoleKeyRequired")
+	RequiredActionsPending = errors.New("RequiredActionsPending")
 )
 
+// ImpersonationAllowedRoutes are the only route prefixes an impersonation token may send anything but a GET
+// to, impersonation is read-only otherwise.
+var ImpersonationAllowedRoutes = []string{
+	"/api/private/impersonation/stop",
+}
+
+// ImpersonationBlockedRoutes are the route prefixes of GET requests that still change the account, they are
+// rejected for impersonation tokens as well.
+var ImpersonationBlockedRoutes = []string{
+	"/api/private/issue-verification-token",
+}
+
+const (
+	LivenessRoute  = "/livez"
+	HealthRoute    = "/healthz"
+	ReadinessRoute = "/readyz"
+	MetricsRoute   = "/metrics"
+)
+
+// ProbeRoutes are served without authentication so orchestrators can probe the application and Prometheus can scrape it.
+var ProbeRoutes = []string{LivenessRoute, HealthRoute, ReadinessRoute, MetricsRoute}
+
+// RestrictedTokenRoutes are reachable with a restricted login token whatever its required actions are.
+var RestrictedTokenRoutes = []string{
+	"/api/private/current-user",
+	"/api/private/logout",
+	"/api/private/csrf-token",
+	"/api/private/account-actions",
+	"/api/private/refresh-login-token",
+}
+
+// RequiredActionRoutes are the route prefixes completing each required action. A forced password reset has
+// none, it is completed through the public reset flow with the emailed token and code.
+var RequiredActionRoutes = map[repository.AccountAction][]string{
+	repository.AccountActionVerifyEmail: {
+		"/api/private/issue-verification-token",
+		"/api/private/send-verification-email-by-token",
+		"/api/private/verify-email",
+	},
+	repository.AccountActionAcceptTerms: {"/api/private/account-actions/accept-terms"},
+	repository.AccountActionEnrollMfa:   {"/api/private/mfa/enroll", "/api/private/mfa/confirm"},
+}
+
+// MfaChallengeRoutes are the only routes reachable with an MFA challenge token, the password alone must not
+// let anyone complete the required actions of the user.
+var MfaChallengeRoutes = []string{
+	"/api/private/current-user",
+	"/api/private/logout",
+	"/api/private/csrf-token",
+	"/api/private/mfa/code",
+	"/api/private/mfa/challenge",
+}
+
 type AuthMiddleware struct {
-	AuthService    *service.AuthService
-	ExcludedRoutes []string
+	AuthService     *service.AuthService
+	ExcludedRoutes  []string
+	TokenExtractors []TokenExtractor
 }
 
-func NewAuthMiddleware(authService *service.AuthService, excludedRoutes []string) *AuthMiddleware {
+func NewAuthMiddleware(authService *service.AuthService, excludedRoutes []string, tokenExtractors ...TokenExtractor) *AuthMiddleware {
+	if len(tokenExtractors) == 0 {
+		tokenExtractors = []TokenExtractor{NewCookieTokenExtractor(service.CookieName)}
+	}
 	return &AuthMiddleware{
-		AuthService:    authService,
-		ExcludedRoutes: excludedRoutes,
+		AuthService:     authService,
+		ExcludedRoutes:  excludedRoutes,
+		TokenExtractors: tokenExtractors,
 	}
 }
 
 func (middleware *AuthMiddleware) AuthMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		urlPath := ctx.Request().URL.Path
+		if slices.Contains(ProbeRoutes, urlPath) {
+			return next(ctx)
+		}
 		for _, excludedRoute := range middleware.ExcludedRoutes {
 			if strings.HasPrefix(urlPath, excludedRoute) {
 				ctx.Logger().Infof("Bypass auth middleware excluded route: %s", urlPath)
@@ -38,20 +103,87 @@ func (middleware *AuthMiddleware) AuthMiddlewareFunc(next echo.HandlerFunc) echo
 			}
 		}
 
-		cookie, err := ctx.Cookie(service.CookieName)
+		token, source, err := ExtractToken(ctx, middleware.TokenExtractors)
 		if err != nil {
 			return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": LoginRequired.Error()})
 		}
-		userClaims, err := middleware.AuthService.ParseUserClaims(cookie.Value)
+		userClaims, err := middleware.AuthService.AuthenticateToken(ctx.Request().Context(), token)
 		if err != nil {
+			metrics.TokenValidationFailures.WithLabelValues(tokenValidationFailureReason(err)).Inc()
+			if errors.Is(err, security.SessionRevoked) || errors.Is(err, security.SessionExpired) || errors.Is(err, security.SessionNotFound) {
+				return ctx.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			}
 			return err
 		}
 
+		if userClaims.IsRestricted() && !isRouteAllowedForRequiredActions(urlPath, userClaims.RequiredActions) {
+			return ctx.JSON(http.StatusForbidden, map[string]any{
+				"error":            RequiredActionsPending.Error(),
+				"required_actions": userClaims.RequiredActions,
+			})
+		}
+
+		if userClaims.IsImpersonated() && !isRouteAllowedForImpersonation(ctx.Request().Method, urlPath) {
+			return ctx.JSON(http.StatusForbidden, map[string]string{"error": security.ImpersonationActionForbidden.Error()})
+		}
+
 		ctx.Set("user", userClaims)
+		ctx.Set("token_source", source)
+		request := ctx.Request()
+		tracing.SetUser(request.Context(), userClaims.ID, userClaims.RoleName)
+		metadata := *service.RequestMetadataFromContext(request.Context())
+		metadata.ActorID = &userClaims.ID
+		if userClaims.IsImpersonated() {
+			metadata.ActorID = userClaims.ImpersonatorID
+		}
+		ctx.SetRequest(request.WithContext(service.ContextWithRequestMetadata(request.Context(), &metadata)))
 		return next(ctx)
 	}
 }
 
+func tokenValidationFailureReason(err error) string {
+	switch {
+	case errors.Is(err, security.SessionRevoked):
+		return "session_revoked"
+	case errors.Is(err, security.SessionExpired):
+		return "session_expired"
+	case errors.Is(err, security.SessionNotFound):
+		return "session_not_found"
+	}
+	return "invalid_token"
+}
+
+func isRouteAllowedForImpersonation(method string, urlPath string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return hasRoutePrefix(urlPath, ImpersonationAllowedRoutes)
+	}
+	return !hasRoutePrefix(urlPath, ImpersonationBlockedRoutes)
+}
+
+func hasRoutePrefix(urlPath string, routes []string) bool {
+	for _, route := range routes {
+		if strings.HasPrefix(urlPath, route) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRouteAllowedForRequiredActions(urlPath string, requiredActions []string) bool {
+	if slices.Contains(requiredActions, string(service.MfaChallengeAction)) {
+		return hasRoutePrefix(urlPath, MfaChallengeRoutes)
+	}
+	if hasRoutePrefix(urlPath, RestrictedTokenRoutes) {
+		return true
+	}
+	for _, action := range requiredActions {
+		if hasRoutePrefix(urlPath, RequiredActionRoutes[repository.AccountAction(action)]) {
+			return true
+		}
+	}
+	return false
+}
+
 func RoleRequired(role *repository.UserRole, next echo.HandlerFunc) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		user := ctx.Get("user")
@@ -63,7 +195,7 @@ func RoleRequired(role *repository.UserRole, next echo.HandlerFunc) echo.Handler
 		}
 
 		if castedUser.RoleIndex < role.RoleIndex {
-			log.Warn().Msgf("User %s with role %s has no permission to access this resource", castedUser.UserName, castedUser.RoleName)
+			service.LoggerFromContext(ctx.Request().Context()).Warn().Msgf("User %s with role %s has no permission to access this resource", castedUser.UserName, castedUser.RoleName)
 			return ctx.JSON(http.StatusForbidden, map[string]string{
 				"error": PermissionDenied.Error(),
 			})
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequiredActionRoutes(t *testing.T) {
+	tests := []struct {
+		path    string
+		actions []string
+		allowed bool
+	}{
+		{"/api/private/current-user", []string{"accept_terms"}, true},
+		{"/api/private/refresh-login-token", []string{"accept_terms"}, true},
+		{"/api/private/account-actions/accept-terms", []string{"accept_terms"}, true},
+		{"/api/private/account-actions/accept-terms", []string{"verify_email"}, true},
+		{"/api/private/verify-email", []string{"verify_email"}, true},
+		{"/api/private/verify-email", []string{"accept_terms"}, false},
+		{"/api/private/admin/users", []string{"accept_terms"}, false},
+		{"/api/private/mfa/enroll", []string{"enroll_mfa"}, true},
+		{"/api/private/mfa/confirm", []string{"enroll_mfa"}, true},
+		{"/api/private/mfa/disable", []string{"enroll_mfa"}, false},
+		{"/api/private/mfa/challenge", []string{"mfa_challenge"}, true},
+		{"/api/private/mfa/code", []string{"mfa_challenge"}, true},
+		{"/api/private/logout", []string{"mfa_challenge"}, true},
+		{"/api/private/refresh-login-token", []string{"mfa_challenge"}, false},
+		{"/api/private/account-actions/accept-terms", []string{"mfa_challenge", "accept_terms"}, false},
+		{"/api/private/mfa/enroll", []string{"mfa_challenge", "enroll_mfa"}, false},
+		{"/api/private/mfa/challenge", []string{"accept_terms"}, false},
+		{"/api/private/change-password", []string{"reset_password"}, false},
+	}
+	for _, test := range tests {
+		if allowed := isRouteAllowedForRequiredActions(test.path, test.actions); allowed != test.allowed {
+			t.Errorf("%s with %v: expected allowed=%v", test.path, test.actions, test.allowed)
+		}
+	}
+}
+
+func TestImpersonationRoutes(t *testing.T) {
+	tests := []struct {
+		method  string
+		path    string
+		allowed bool
+	}{
+		{http.MethodGet, "/api/private/current-user", true},
+		{http.MethodGet, "/api/private/sessions", true},
+		{http.MethodGet, "/api/private/logout", true},
+		{http.MethodPost, "/api/private/impersonation/stop", true},
+		{http.MethodGet, "/api/private/issue-verification-token", false},
+		{http.MethodPost, "/api/private/locale", false},
+		{http.MethodPut, "/api/private/notification-preferences", false},
+		{http.MethodDelete, "/api/private/sessions/1", false},
+		{http.MethodPost, "/api/private/admin/impersonate", false},
+		{http.MethodPatch, "/api/private/user", false},
+	}
+	for _, test := range tests {
+		if allowed := isRouteAllowedForImpersonation(test.method, test.path); allowed != test.allowed {
+			t.Errorf("%s %s: expected allowed=%v", test.method, test.path, test.allowed)
+		}
+	}
+}
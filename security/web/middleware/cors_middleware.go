@@ -2,22 +2,246 @@ package web
 
 import (
 	"github.com/labstack/echo/v4"
+	"go-security/security"
 	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func CORSMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		// Add CORS headers
-		c.Response().Header().Set("Access-Control-Allow-Origin", "*")
-		c.Response().Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Response().Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Response().Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+const corsRegexPrefix = "regex:"
 
-		// Handle preflight request
-		if c.Request().Method == http.MethodOptions {
-			return c.NoContent(http.StatusNoContent)
+var (
+	DefaultCorsAllowedMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	DefaultCorsAllowedHeaders = []string{
+		"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With", "X-Login-Mode", "X-Request-ID",
+	}
+)
+
+// CorsConfig describes a CORS policy. Allowed origins accept exact origins, wildcard subdomains
+// such as "https://*.example.com" where "*" is the leftmost label and matches exactly one label, regular expressions prefixed by "regex:" matching the whole origin,
+// and "*" for any origin, which cannot be combined with credentials.
+type CorsConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"`
+	// RouteOverrides replaces the policy for requests under the given path prefix, the longest prefix wins.
+	RouteOverrides map[string]*CorsConfig `yaml:"route_overrides"`
+}
+
+type corsPolicy struct {
+	config         *CorsConfig
+	allowAny       bool
+	exactOrigins   map[string]bool
+	wildcards      [][2]string
+	patterns       []*regexp.Regexp
+	allowedMethods string
+	allowedHeaders string
+	exposedHeaders string
+	maxAge         string
+}
+
+func newCorsPolicy(config *CorsConfig) (*corsPolicy, error) {
+	if slices.Contains(config.AllowedOrigins, "*") && config.AllowCredentials {
+		// Any site could make credentialed reads of the API.
+		return nil, security.CorsWildcardWithCredentials
+	}
+	policy := &corsPolicy{
+		config:       config,
+		exactOrigins: make(map[string]bool),
+	}
+	for _, origin := range config.AllowedOrigins {
+		switch {
+		case origin == "*":
+			policy.allowAny = true
+		case strings.HasPrefix(origin, corsRegexPrefix):
+			// Anchored, so a pattern cannot be satisfied by an origin merely containing it.
+			pattern, err := regexp.Compile("^(?:" + strings.TrimPrefix(origin, corsRegexPrefix) + ")$")
+			if err != nil {
+				return nil, err
+			}
+			policy.patterns = append(policy.patterns, pattern)
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(strings.ToLower(origin), "*")
+			if !strings.HasSuffix(prefix, "://") || strings.Count(prefix, "://") != 1 ||
+				!strings.HasPrefix(suffix, ".") || strings.Contains(suffix, "*") || len(suffix) < 2 {
+				return nil, security.CorsWildcardOriginInvalid
+			}
+			policy.wildcards = append(policy.wildcards, [2]string{prefix, suffix})
+		default:
+			policy.exactOrigins[strings.ToLower(origin)] = true
+		}
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultCorsAllowedMethods
+	}
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultCorsAllowedHeaders
+	}
+	policy.allowedMethods = strings.Join(methods, ", ")
+	policy.allowedHeaders = strings.Join(headers, ", ")
+	policy.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+	if config.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(config.MaxAge)
+	}
+	return policy, nil
+}
+
+// isWildcardLabelMatch tells whether the origin is the scheme prefix, a single DNS label and the suffix, so
+// "https://*.example.com" matches neither "https://a.b.example.com" nor "https://evil.com.example.com".
+func isWildcardLabelMatch(origin string, prefix string, suffix string) bool {
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	label := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(label, ".:/@?#")
+}
+
+func (policy *corsPolicy) isOriginAllowed(origin string) bool {
+	if policy.allowAny {
+		return true
+	}
+	lowerOrigin := strings.ToLower(origin)
+	if policy.exactOrigins[lowerOrigin] {
+		return true
+	}
+	for _, wildcard := range policy.wildcards {
+		if isWildcardLabelMatch(lowerOrigin, wildcard[0], wildcard[1]) {
+			return true
+		}
+	}
+	for _, pattern := range policy.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+type CorsMiddleware struct {
+	defaultPolicy *corsPolicy
+	routePrefixes []string
+	routePolicies map[string]*corsPolicy
+}
+
+func NewCorsMiddleware(config *CorsConfig) (*CorsMiddleware, error) {
+	if config == nil {
+		config = &CorsConfig{}
+	}
+	defaultPolicy, err := newCorsPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	middleware := &CorsMiddleware{
+		defaultPolicy: defaultPolicy,
+		routePolicies: make(map[string]*corsPolicy),
+	}
+	for prefix, routeConfig := range config.RouteOverrides {
+		if err := middleware.addRoutePolicy(prefix, routeConfig); err != nil {
+			return nil, err
 		}
+	}
+	return middleware, nil
+}
+
+func (middleware *CorsMiddleware) addRoutePolicy(prefix string, config *CorsConfig) error {
+	policy, err := newCorsPolicy(config)
+	if err != nil {
+		return err
+	}
+	if _, ok := middleware.routePolicies[prefix]; !ok {
+		middleware.routePrefixes = append(middleware.routePrefixes, prefix)
+	}
+	middleware.routePolicies[prefix] = policy
+	sort.Slice(middleware.routePrefixes, func(i, j int) bool {
+		return len(middleware.routePrefixes[i]) > len(middleware.routePrefixes[j])
+	})
+	return nil
+}
+
+// Group creates a route group of the engine served with its own CORS policy instead of the default one.
+// Groups must be created before the engine starts serving.
+func (middleware *CorsMiddleware) Group(engine *echo.Echo, prefix string, config *CorsConfig, middlewares ...echo.MiddlewareFunc) (*echo.Group, error) {
+	if config == nil {
+		config = &CorsConfig{}
+	}
+	if err := middleware.addRoutePolicy(prefix, config); err != nil {
+		return nil, err
+	}
+	return engine.Group(prefix, middlewares...), nil
+}
+
+func MustNewCorsMiddleware(config *CorsConfig) *CorsMiddleware {
+	middleware, err := NewCorsMiddleware(config)
+	if err != nil {
+		panic(err)
+	}
+	return middleware
+}
 
-		return next(c)
+func (middleware *CorsMiddleware) policyFor(urlPath string) *corsPolicy {
+	for _, prefix := range middleware.routePrefixes {
+		if strings.HasPrefix(urlPath, prefix) {
+			return middleware.routePolicies[prefix]
+		}
+	}
+	return middleware.defaultPolicy
+}
+
+// CorsMiddlewareFunc must run before AuthMiddlewareFunc, preflight requests carry no credentials.
+func (middleware *CorsMiddleware) CorsMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		request := ctx.Request()
+		header := ctx.Response().Header()
+		origin := request.Header.Get(echo.HeaderOrigin)
+		isPreflight := request.Method == http.MethodOptions && len(request.Header.Get(echo.HeaderAccessControlRequestMethod)) > 0
+
+		header.Add(echo.HeaderVary, echo.HeaderOrigin)
+		if len(origin) == 0 {
+			return next(ctx)
+		}
+
+		policy := middleware.policyFor(request.URL.Path)
+		if !policy.isOriginAllowed(origin) {
+			if isPreflight {
+				return ctx.NoContent(http.StatusNoContent)
+			}
+			return next(ctx)
+		}
+
+		if policy.allowAny {
+			header.Set(echo.HeaderAccessControlAllowOrigin, "*")
+		} else {
+			header.Set(echo.HeaderAccessControlAllowOrigin, origin)
+		}
+		if policy.config.AllowCredentials {
+			header.Set(echo.HeaderAccessControlAllowCredentials, "true")
+		}
+
+		if !isPreflight {
+			if len(policy.exposedHeaders) > 0 {
+				header.Set(echo.HeaderAccessControlExposeHeaders, policy.exposedHeaders)
+			}
+			return next(ctx)
+		}
+
+		header.Add(echo.HeaderVary, echo.HeaderAccessControlRequestMethod)
+		header.Add(echo.HeaderVary, echo.HeaderAccessControlRequestHeaders)
+		header.Set(echo.HeaderAccessControlAllowMethods, policy.allowedMethods)
+		header.Set(echo.HeaderAccessControlAllowHeaders, policy.allowedHeaders)
+		if len(policy.maxAge) > 0 {
+			header.Set(echo.HeaderAccessControlMaxAge, policy.maxAge)
+		}
+		return ctx.NoContent(http.StatusNoContent)
 	}
 }
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go-security/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveCors(t *testing.T, engine *echo.Echo, method string, path string, origin string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, path, nil)
+	if len(origin) > 0 {
+		request.Header.Set(echo.HeaderOrigin, origin)
+	}
+	if method == http.MethodOptions {
+		request.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func newCorsTestEngine(t *testing.T, middleware *CorsMiddleware) *echo.Echo {
+	t.Helper()
+	engine := echo.New()
+	engine.Use(middleware.CorsMiddlewareFunc)
+	engine.Any("/*", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+	return engine
+}
+
+func TestNewCorsMiddlewareRejectsWildcardWithCredentials(t *testing.T) {
+	_, err := NewCorsMiddleware(&CorsConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	if !errors.Is(err, security.CorsWildcardWithCredentials) {
+		t.Fatalf("default policy: got %v", err)
+	}
+	_, err = NewCorsMiddleware(&CorsConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		RouteOverrides: map[string]*CorsConfig{
+			"/api/public": {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		},
+	})
+	if !errors.Is(err, security.CorsWildcardWithCredentials) {
+		t.Fatalf("route override: got %v", err)
+	}
+	if _, err := NewCorsMiddleware(&CorsConfig{AllowedOrigins: []string{"*"}}); err != nil {
+		t.Fatalf("wildcard without credentials: %v", err)
+	}
+	for _, origin := range []string{"https://example.*", "https://app*.example.com", "https://*", "*.example.com", "https://*.*.example.com"} {
+		if _, err := NewCorsMiddleware(&CorsConfig{AllowedOrigins: []string{origin}}); !errors.Is(err, security.CorsWildcardOriginInvalid) {
+			t.Errorf("%s: got %v", origin, err)
+		}
+	}
+}
+
+func TestCorsMiddlewareOrigins(t *testing.T) {
+	middleware := MustNewCorsMiddleware(&CorsConfig{
+		AllowedOrigins: []string{
+			"https://app.example.com",
+			"https://*.example.org",
+			`regex:https://tenant-[a-z]+\.example\.net`,
+		},
+		AllowCredentials: true,
+	})
+	engine := newCorsTestEngine(t, middleware)
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://evil.com", false},
+		{"https://a.example.org", true},
+		{"https://example.org", false},
+		{"https://a.b.example.org", false},
+		{"https://evil.com.example.org", false},
+		{"http://a.example.org", false},
+		{"https://a.example.org.evil.com", false},
+		{"https://tenant-one.example.net", true},
+		{"https://tenant-one.example.net.evil.com", false},
+		{"https://evil.com/https://tenant-one.example.net", false},
+	}
+	for _, test := range tests {
+		recorder := serveCors(t, engine, http.MethodGet, "/api/private/me", test.origin)
+		allowOrigin := recorder.Header().Get(echo.HeaderAccessControlAllowOrigin)
+		if test.allowed && allowOrigin != test.origin {
+			t.Errorf("%s: expected to be echoed, got %q", test.origin, allowOrigin)
+		}
+		if !test.allowed && len(allowOrigin) > 0 {
+			t.Errorf("%s: expected no allow origin, got %q", test.origin, allowOrigin)
+		}
+		if test.allowed && recorder.Header().Get(echo.HeaderAccessControlAllowCredentials) != "true" {
+			t.Errorf("%s: expected credentials to be allowed", test.origin)
+		}
+	}
+}
+
+func TestCorsMiddlewarePreflight(t *testing.T) {
+	middleware := MustNewCorsMiddleware(&CorsConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		MaxAge:         600,
+	})
+	engine := newCorsTestEngine(t, middleware)
+
+	recorder := serveCors(t, engine, http.MethodOptions, "/api/private/me", "https://app.example.com")
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", recorder.Code)
+	}
+	if methods := recorder.Header().Get(echo.HeaderAccessControlAllowMethods); methods != "GET, POST" {
+		t.Errorf("unexpected allowed methods %q", methods)
+	}
+	if maxAge := recorder.Header().Get(echo.HeaderAccessControlMaxAge); maxAge != "600" {
+		t.Errorf("unexpected max age %q", maxAge)
+	}
+
+	recorder = serveCors(t, engine, http.MethodOptions, "/api/private/me", "https://evil.com")
+	if recorder.Code != http.StatusNoContent || len(recorder.Header().Get(echo.HeaderAccessControlAllowMethods)) > 0 {
+		t.Errorf("disallowed origin got a preflight grant: %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestCorsMiddlewareRouteOverrides(t *testing.T) {
+	middleware := MustNewCorsMiddleware(&CorsConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		RouteOverrides: map[string]*CorsConfig{
+			"/api/public": {AllowedOrigins: []string{"*"}},
+		},
+	})
+	engine := newCorsTestEngine(t, middleware)
+
+	recorder := serveCors(t, engine, http.MethodGet, "/api/public/status", "https://other.com")
+	if allowOrigin := recorder.Header().Get(echo.HeaderAccessControlAllowOrigin); allowOrigin != "*" {
+		t.Errorf("public route: expected *, got %q", allowOrigin)
+	}
+	if len(recorder.Header().Get(echo.HeaderAccessControlAllowCredentials)) > 0 {
+		t.Errorf("public route: credentials must not be allowed")
+	}
+
+	recorder = serveCors(t, engine, http.MethodGet, "/api/private/me", "https://other.com")
+	if allowOrigin := recorder.Header().Get(echo.HeaderAccessControlAllowOrigin); len(allowOrigin) > 0 {
+		t.Errorf("private route: expected no allow origin, got %q", allowOrigin)
+	}
+}
+
+func TestCorsMiddlewareGroup(t *testing.T) {
+	middleware := MustNewCorsMiddleware(&CorsConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	engine := echo.New()
+	engine.Use(middleware.CorsMiddlewareFunc)
+	group, err := middleware.Group(engine, "/partner", &CorsConfig{AllowedOrigins: []string{"https://partner.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.GET("/orders", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+	engine.GET("/api/me", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+
+	recorder := serveCors(t, engine, http.MethodGet, "/partner/orders", "https://partner.example.com")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the group route to be served, got %d", recorder.Code)
+	}
+	if allowOrigin := recorder.Header().Get(echo.HeaderAccessControlAllowOrigin); allowOrigin != "https://partner.example.com" {
+		t.Errorf("group route: got %q", allowOrigin)
+	}
+	recorder = serveCors(t, engine, http.MethodGet, "/partner/orders", "https://app.example.com")
+	if allowOrigin := recorder.Header().Get(echo.HeaderAccessControlAllowOrigin); len(allowOrigin) > 0 {
+		t.Errorf("group route must not use the default policy, got %q", allowOrigin)
+	}
+	recorder = serveCors(t, engine, http.MethodGet, "/api/me", "https://partner.example.com")
+	if allowOrigin := recorder.Header().Get(echo.HeaderAccessControlAllowOrigin); len(allowOrigin) > 0 {
+		t.Errorf("default route must not use the group policy, got %q", allowOrigin)
+	}
+
+	if _, err := middleware.Group(engine, "/open", &CorsConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}); !errors.Is(err, security.CorsWildcardWithCredentials) {
+		t.Errorf("group: got %v", err)
+	}
+}
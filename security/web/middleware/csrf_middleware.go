@@ -0,0 +1,83 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+	"strings"
+)
+
+var (
+	CsrfTokenRequired = errors.New("CsrfTokenRequired")
+	CsrfTokenMismatch = errors.New("CsrfTokenMismatch")
+)
+
+type CsrfMiddleware struct {
+	CsrfService *service.CsrfService
+	Config      *service.CsrfConfig
+}
+
+func NewCsrfMiddleware(csrfService *service.CsrfService, config *service.CsrfConfig) *CsrfMiddleware {
+	return &CsrfMiddleware{
+		CsrfService: csrfService,
+		Config:      config,
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// isBrowserRequest reports whether the request comes from a browser, the only client a forged request can come
+// from. Browsers send Origin on every unsafe request and Sec-Fetch-Site when they support fetch metadata.
+func isBrowserRequest(request *http.Request) bool {
+	return len(request.Header.Get(echo.HeaderOrigin)) > 0 || len(request.Header.Get("Sec-Fetch-Site")) > 0
+}
+
+// CsrfMiddlewareFunc enforces the double-submit check on unsafe methods. It must run after AuthMiddlewareFunc.
+// Cookie authenticated requests need a token bound to their session. Anonymous browser requests, such as the
+// login on public routes, need the double submit only, which keeps other sites from logging the victim into
+// an account of theirs. Requests authenticated by bearer or query token carry no ambient credential and are exempted.
+func (middleware *CsrfMiddleware) CsrfMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		request := ctx.Request()
+		if !middleware.Config.Enabled || isSafeMethod(request.Method) {
+			return next(ctx)
+		}
+		urlPath := request.URL.Path
+		for _, exemptedRoute := range middleware.Config.ExemptedRoutePrefixes {
+			if strings.HasPrefix(urlPath, exemptedRoute) {
+				return next(ctx)
+			}
+		}
+		source, _ := ctx.Get("token_source").(TokenSource)
+		claims, authenticated := ctx.Get("user").(*service.UserClaims)
+		switch {
+		case source == TokenSourceCookie && authenticated:
+		case len(source) == 0 && isBrowserRequest(request):
+		default:
+			return next(ctx)
+		}
+
+		headerToken := request.Header.Get(middleware.Config.GetHeaderName())
+		cookie, err := ctx.Cookie(middleware.Config.GetCookieName())
+		if err != nil || len(headerToken) == 0 {
+			return ctx.JSON(http.StatusForbidden, map[string]string{"error": CsrfTokenRequired.Error()})
+		}
+		if subtle.ConstantTimeCompare([]byte(headerToken), []byte(cookie.Value)) != 1 {
+			return ctx.JSON(http.StatusForbidden, map[string]string{"error": CsrfTokenMismatch.Error()})
+		}
+		if authenticated {
+			if err := middleware.CsrfService.VerifyToken(headerToken, service.CsrfBinding(claims.SessionID, claims.ID)); err != nil {
+				return ctx.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+		}
+		return next(ctx)
+	}
+}
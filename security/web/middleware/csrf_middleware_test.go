@@ -0,0 +1,141 @@
+package web
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testCsrfSecret = "test-secret"
+
+type csrfTestRequest struct {
+	claims      *service.UserClaims
+	source      TokenSource
+	headerToken string
+	cookieToken string
+	origin      string
+	method      string
+	path        string
+}
+
+func serveCsrf(t *testing.T, config *service.CsrfConfig, testRequest csrfTestRequest) int {
+	t.Helper()
+	middleware := NewCsrfMiddleware(service.NewCsrfService(testCsrfSecret), config)
+	engine := echo.New()
+	method := testRequest.method
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+	path := testRequest.path
+	if len(path) == 0 {
+		path = "/api/private/change-password"
+	}
+	request := httptest.NewRequest(method, path, nil)
+	if len(testRequest.headerToken) > 0 {
+		request.Header.Set(config.GetHeaderName(), testRequest.headerToken)
+	}
+	if len(testRequest.cookieToken) > 0 {
+		request.AddCookie(&http.Cookie{Name: config.GetCookieName(), Value: testRequest.cookieToken})
+	}
+	if len(testRequest.origin) > 0 {
+		request.Header.Set(echo.HeaderOrigin, testRequest.origin)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := engine.NewContext(request, recorder)
+	if testRequest.claims != nil {
+		ctx.Set("user", testRequest.claims)
+		ctx.Set("token_source", testRequest.source)
+	}
+	handler := middleware.CsrfMiddlewareFunc(func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+	if err := handler(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code
+}
+
+func issueTestCsrfToken(t *testing.T, binding string) string {
+	t.Helper()
+	token, err := service.NewCsrfService(testCsrfSecret).IssueToken(binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestCsrfMiddlewareAcceptsTokenOfTheSession(t *testing.T) {
+	claims := &service.UserClaims{ID: 1, SessionID: "victim-session"}
+	token := issueTestCsrfToken(t, service.CsrfBinding(claims.SessionID, claims.ID))
+	code := serveCsrf(t, &service.CsrfConfig{Enabled: true}, csrfTestRequest{
+		claims: claims, source: TokenSourceCookie, headerToken: token, cookieToken: token,
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+}
+
+func TestCsrfMiddlewareRejectsTokenPlantedFromAnotherAccount(t *testing.T) {
+	claims := &service.UserClaims{ID: 1, SessionID: "victim-session"}
+	planted := issueTestCsrfToken(t, service.CsrfBinding("attacker-session", 2))
+	code := serveCsrf(t, &service.CsrfConfig{Enabled: true}, csrfTestRequest{
+		claims: claims, source: TokenSourceCookie, headerToken: planted, cookieToken: planted,
+	})
+	if code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", code)
+	}
+}
+
+func TestCsrfMiddlewareRejectsMissingOrMismatchedToken(t *testing.T) {
+	claims := &service.UserClaims{ID: 1, SessionID: "session"}
+	token := issueTestCsrfToken(t, service.CsrfBinding(claims.SessionID, claims.ID))
+	other := issueTestCsrfToken(t, service.CsrfBinding(claims.SessionID, claims.ID))
+	for name, testRequest := range map[string]csrfTestRequest{
+		"missing header": {claims: claims, source: TokenSourceCookie, cookieToken: token},
+		"missing cookie": {claims: claims, source: TokenSourceCookie, headerToken: token},
+		"mismatch":       {claims: claims, source: TokenSourceCookie, headerToken: token, cookieToken: other},
+	} {
+		if code := serveCsrf(t, &service.CsrfConfig{Enabled: true}, testRequest); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", name, code)
+		}
+	}
+}
+
+func TestCsrfMiddlewareSkipsSafeMethodsAndBearerTokens(t *testing.T) {
+	claims := &service.UserClaims{ID: 1, SessionID: "session"}
+	config := &service.CsrfConfig{Enabled: true}
+	if code := serveCsrf(t, config, csrfTestRequest{claims: claims, source: TokenSourceCookie, method: http.MethodGet}); code != http.StatusOK {
+		t.Errorf("safe method: expected 200, got %d", code)
+	}
+	if code := serveCsrf(t, config, csrfTestRequest{claims: claims, source: TokenSourceBearer}); code != http.StatusOK {
+		t.Errorf("bearer token: expected 200, got %d", code)
+	}
+}
+
+func TestCsrfMiddlewareCoversAnonymousBrowserRequests(t *testing.T) {
+	config := &service.CsrfConfig{Enabled: true}
+	login := "/api/public/login"
+	if code := serveCsrf(t, config, csrfTestRequest{path: login, origin: "https://evil.example"}); code != http.StatusForbidden {
+		t.Errorf("forged login: expected 403, got %d", code)
+	}
+	token := issueTestCsrfToken(t, service.CsrfAnonymousBinding)
+	if code := serveCsrf(t, config, csrfTestRequest{path: login, origin: "https://app.example", headerToken: token, cookieToken: token}); code != http.StatusOK {
+		t.Errorf("double submitted login: expected 200, got %d", code)
+	}
+	if code := serveCsrf(t, config, csrfTestRequest{path: login}); code != http.StatusOK {
+		t.Errorf("non-browser login: expected 200, got %d", code)
+	}
+}
+
+func TestCsrfMiddlewareHonorsExemptionsAndDisabling(t *testing.T) {
+	claims := &service.UserClaims{ID: 1, SessionID: "session"}
+	exempted := &service.CsrfConfig{Enabled: true, ExemptedRoutePrefixes: []string{"/api/private/webhooks"}}
+	if code := serveCsrf(t, exempted, csrfTestRequest{claims: claims, source: TokenSourceCookie, path: "/api/private/webhooks/x"}); code != http.StatusOK {
+		t.Errorf("exempted route: expected 200, got %d", code)
+	}
+	if code := serveCsrf(t, &service.CsrfConfig{}, csrfTestRequest{claims: claims, source: TokenSourceCookie}); code != http.StatusOK {
+		t.Errorf("disabled: expected 200, got %d", code)
+	}
+}
@@ -1,23 +1,134 @@
 package web
 
 import (
-	"fmt"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
+	"go-security/security"
+	"go-security/security/service"
+	"go-security/security/web"
 	"net/http"
 	"runtime/debug"
 )
 
+// ErrorStatusRule answers Err, or an error wrapping it, with Status.
+type ErrorStatusRule struct {
+	Err    error
+	Status int
+}
+
+// ErrorStatuses are looked up in order, an error wrapping several of them is answered with the first
+// one matching: authentication and permission failures win over the others. Any other error is a server
+// failure answered with 500.
+var ErrorStatuses = []ErrorStatusRule{
+	{security.TokenExpired, http.StatusUnauthorized},
+	{security.TokenInvalid, http.StatusUnauthorized},
+	{security.UserPasswordNotMatched, http.StatusUnauthorized},
+	{security.SessionNotFound, http.StatusUnauthorized},
+	{security.SessionExpired, http.StatusUnauthorized},
+	{security.SessionRevoked, http.StatusUnauthorized},
+	{security.ImpersonatorSessionEnded, http.StatusUnauthorized},
+	{LoginRequired, http.StatusUnauthorized},
+	{TokenNotFound, http.StatusUnauthorized},
+	{PermissionDenied, http.StatusForbidden},
+	{RequiredActionsPending, http.StatusForbidden},
+	{security.UserRoleNotAllowed, http.StatusForbidden},
+	{security.PasswordResetRequired, http.StatusForbidden},
+	{security.SessionLimitReached, http.StatusForbidden},
+	{security.ImpersonationNotAllowed, http.StatusForbidden},
+	{security.ImpersonationActionForbidden, http.StatusForbidden},
+	{security.MagicLinkDisabled, http.StatusForbidden},
+	{security.CsrfTokenInvalid, http.StatusForbidden},
+	{security.MfaChallengePending, http.StatusForbidden},
+	{CsrfTokenRequired, http.StatusForbidden},
+	{CsrfTokenMismatch, http.StatusForbidden},
+	{security.UserNotFound, http.StatusNotFound},
+	{security.UserRoleNotFound, http.StatusNotFound},
+	{security.EmailOutboxMessageNotFound, http.StatusNotFound},
+	{security.UserAlreadyExists, http.StatusConflict},
+	{security.UserAlreadyVerified, http.StatusConflict},
+	{security.PhoneNumberAlreadyUsed, http.StatusConflict},
+	{security.PhoneNumberAlreadyVerified, http.StatusConflict},
+	{security.EmailOutboxMessageNotRetryable, http.StatusConflict},
+	{security.MfaAlreadyEnabled, http.StatusConflict},
+	{security.MfaNotEnabled, http.StatusConflict},
+	{security.SmsRateLimitExceeded, http.StatusTooManyRequests},
+	{web.EmailRateLimitExceeded, http.StatusTooManyRequests},
+	{security.NotificationChannelUnavailable, http.StatusServiceUnavailable},
+	{security.UserPlatformEmpty, http.StatusBadRequest},
+	{security.UserNameNotAllowed, http.StatusBadRequest},
+	{security.UserEmailNotAllowed, http.StatusBadRequest},
+	{security.UserPasswordNotAllowed, http.StatusBadRequest},
+	{security.OtpNotFound, http.StatusBadRequest},
+	{security.OtpIncorrect, http.StatusBadRequest},
+	{security.OtpExpired, http.StatusBadRequest},
+	{security.OtpAttemptsExceeded, http.StatusBadRequest},
+	{security.ResetPasswordNotMatched, http.StatusBadRequest},
+	{security.SelfPlatformRequiredForPasswordReset, http.StatusBadRequest},
+	{security.PhoneNumberNotAllowed, http.StatusBadRequest},
+	{security.PhoneNumberNotVerified, http.StatusBadRequest},
+	{security.DeliveryChannelNotSupported, http.StatusBadRequest},
+	{security.MagicLinkAlreadyUsed, http.StatusBadRequest},
+	{security.MagicLinkBrowserMismatch, http.StatusBadRequest},
+	{security.TokenAlreadyUsed, http.StatusBadRequest},
+	{security.AuditExportFormatNotSupported, http.StatusBadRequest},
+	{security.AccountActionNotSupported, http.StatusBadRequest},
+	{security.SessionLimitPolicyNotSupported, http.StatusBadRequest},
+	{security.NotImpersonating, http.StatusBadRequest},
+	{security.MfaChallengeNotPending, http.StatusBadRequest},
+	{web.UnableToIdentifyUser, http.StatusBadRequest},
+}
+
+// ErrorStatus is the status an error returned by a handler is answered with.
+func ErrorStatus(err error) int {
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return httpError.Code
+	}
+	var validationError *jwt.ValidationError
+	if errors.As(err, &validationError) {
+		return http.StatusUnauthorized
+	}
+	for _, rule := range ErrorStatuses {
+		if errors.Is(err, rule.Err) {
+			return rule.Status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// errorMessage is the message the error is answered with. The cause of a server failure stays in the logs,
+// it may reveal the internals of the server.
+func errorMessage(err error, status int) string {
+	if status >= http.StatusInternalServerError {
+		return http.StatusText(status)
+	}
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		if message, ok := httpError.Message.(string); ok {
+			return message
+		}
+		return http.StatusText(status)
+	}
+	return err.Error()
+}
+
 func ErrorMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		err := next(c)
 		if err != nil {
-			stacks := string(debug.Stack())
-			c.Logger().Error(stacks)
-			fmt.Println(stacks)
+			requestContext := c.Request().Context()
+			status := ErrorStatus(err)
+			if status >= http.StatusInternalServerError {
+				service.LoggerFromContext(requestContext).Error().Err(err).Str("stack", string(debug.Stack())).Msg("Request failed")
+			} else {
+				service.LoggerFromContext(requestContext).Warn().Err(err).Msg("Request rejected")
+			}
 
-			// Return a custom error response
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"message": err.Error(),
+			// Return a custom error response, the request ID lets the client point at the logs
+			return c.JSON(status, map[string]string{
+				"message":    errorMessage(err, status),
+				"request_id": service.RequestIDFromContext(requestContext),
 			})
 		}
 		return err
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go-security/security"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{security.UserNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: invalid or missing 'id' claim", security.TokenInvalid), http.StatusUnauthorized},
+		{security.ImpersonationActionForbidden, http.StatusForbidden},
+		{echo.NewHTTPError(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+		{fmt.Errorf("%w: %w", security.UserNotFound, security.SessionRevoked), http.StatusUnauthorized},
+		{fmt.Errorf("%w: %w", security.SessionRevoked, security.UserNotFound), http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		if status := ErrorStatus(test.err); status != test.status {
+			t.Errorf("%v: expected %d, got %d", test.err, test.status, status)
+		}
+	}
+}
+
+func TestErrorMiddlewareHidesServerFailures(t *testing.T) {
+	engine := echo.New()
+	recorder := httptest.NewRecorder()
+	ctx := engine.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), recorder)
+	handler := ErrorMiddlewareFunc(func(ctx echo.Context) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	})
+	if err := handler(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, http.StatusText(http.StatusInternalServerError)) || strings.Contains(body, "10.0.0.5") {
+		t.Fatalf("the cause must not be answered, got %s", body)
+	}
+}
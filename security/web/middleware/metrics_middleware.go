@@ -0,0 +1,39 @@
+package web
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go-security/security/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MetricsMiddlewareFunc observes the duration of every request per route template, so path parameters
+// do not multiply the series. Requests matching no route are grouped under "unmatched".
+func MetricsMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		startedAt := time.Now()
+		err := next(ctx)
+		status := responseStatus(ctx, err)
+		route := ctx.Path()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+		metrics.HttpRequestDuration.WithLabelValues(ctx.Request().Method, route, strconv.Itoa(status)).Observe(time.Since(startedAt).Seconds())
+		return err
+	}
+}
+
+// responseStatus is the status of the response, or the one the error will be answered with when nothing was written yet.
+func responseStatus(ctx echo.Context, err error) int {
+	status := ctx.Response().Status
+	if err != nil && !ctx.Response().Committed {
+		status = http.StatusInternalServerError
+		var httpError *echo.HTTPError
+		if errors.As(err, &httpError) {
+			status = httpError.Code
+		}
+	}
+	return status
+}
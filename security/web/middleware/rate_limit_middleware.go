@@ -3,6 +3,7 @@ package web
 import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go-security/security/metrics"
 	"go-security/security/service"
 	"go-security/security/web"
 	"golang.org/x/time/rate"
@@ -31,6 +32,7 @@ var emailRateLimitConfig = middleware.RateLimiterConfig{
 		return context.JSON(http.StatusForbidden, map[string]string{"message": web.UnableToIdentifyUser.Error()})
 	},
 	DenyHandler: func(context echo.Context, identifier string, err error) error {
+		metrics.RateLimitDenials.WithLabelValues("email", context.Path()).Inc()
 		return context.JSON(http.StatusTooManyRequests, map[string]string{"message": web.EmailRateLimitExceeded.Error()})
 	},
 }
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const RedactedValue = "[REDACTED]"
+
+var (
+	DefaultRedactedFields = []string{
+		"password", "current_password", "new_password", "confirmed_password", "old_password",
+		"token", "access_token", "refresh_token", "id_token",
+		"otp", "otp_code", "code", "secret", "credential",
+	}
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "X-CSRF-Token"}
+	DefaultRedactedCookies = []string{"jwt", "csrf_token", "magic_link_binding"}
+)
+
+// Redactor masks the sensitive values of logged requests. Field, header and cookie names are matched
+// case-insensitively, JSON fields at any depth.
+type Redactor struct {
+	fields  map[string]bool
+	headers map[string]bool
+	cookies map[string]bool
+}
+
+func NewRedactor(fields []string, headers []string, cookies []string) *Redactor {
+	return &Redactor{
+		fields:  lowerSet(fields),
+		headers: lowerSet(headers),
+		cookies: lowerSet(cookies),
+	}
+}
+
+func lowerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
+
+func (redactor *Redactor) IsRedactedField(name string) bool {
+	return redactor.fields[strings.ToLower(name)]
+}
+
+// RedactJSON masks the redacted fields of a JSON document, ok is false when the body is not valid JSON.
+func (redactor *Redactor) RedactJSON(body []byte) (json.RawMessage, bool) {
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, false
+	}
+	redacted, err := json.Marshal(redactor.redactValue(document))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func (redactor *Redactor) redactValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			if redactor.IsRedactedField(key) {
+				typed[key] = RedactedValue
+			} else {
+				typed[key] = redactor.redactValue(child)
+			}
+		}
+	case []any:
+		for i, child := range typed {
+			typed[i] = redactor.redactValue(child)
+		}
+	}
+	return value
+}
+
+// RedactValues masks the redacted fields of a query string or form.
+func (redactor *Redactor) RedactValues(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for key, entries := range values {
+		if redactor.IsRedactedField(key) {
+			redacted[key] = []string{RedactedValue}
+			continue
+		}
+		redacted[key] = entries
+	}
+	return redacted
+}
+
+// RedactHeaders flattens the headers, masking the redacted ones and the redacted cookies of the Cookie header.
+func (redactor *Redactor) RedactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		switch {
+		case strings.EqualFold(name, "Cookie") && len(redactor.cookies) > 0 && !redactor.headers["cookie"]:
+			redacted[name] = redactor.redactCookies(strings.Join(values, "; "))
+		case redactor.headers[strings.ToLower(name)]:
+			redacted[name] = RedactedValue
+		default:
+			redacted[name] = strings.Join(values, ", ")
+		}
+	}
+	return redacted
+}
+
+func (redactor *Redactor) redactCookies(header string) string {
+	cookies, err := http.ParseCookie(header)
+	if err != nil {
+		return RedactedValue
+	}
+	parts := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		value := cookie.Value
+		if redactor.cookies[strings.ToLower(cookie.Name)] {
+			value = RedactedValue
+		}
+		parts = append(parts, cookie.Name+"="+value)
+	}
+	return strings.Join(parts, "; ")
+}
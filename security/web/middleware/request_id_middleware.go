@@ -0,0 +1,38 @@
+package web
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+)
+
+const maxRequestIDLength = 128
+
+// RequestIDMiddlewareFunc reuses the X-Request-ID of the client, or of the proxy in front, and generates
+// one otherwise. The ID is echoed in the response and carried by the request context.
+func RequestIDMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		request := ctx.Request()
+		requestID := request.Header.Get(echo.HeaderXRequestID)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		ctx.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		ctx.SetRequest(request.WithContext(service.ContextWithRequestID(request.Context(), requestID)))
+		return next(ctx)
+	}
+}
+
+// isValidRequestID keeps arbitrary client input out of the logs, only short printable tokens are reused.
+func isValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, char := range requestID {
+		isAlphanumeric := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if !isAlphanumeric && char != '-' && char != '_' && char != '.' && char != ':' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package web
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	engine := echo.New()
+	engine.Use(RequestIDMiddlewareFunc)
+	var contextID string
+	engine.GET("/", func(ctx echo.Context) error {
+		contextID = service.RequestIDFromContext(ctx.Request().Context())
+		return ctx.NoContent(http.StatusOK)
+	})
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{"valid", "req-123_abc.def:9", true},
+		{"missing", "", false},
+		{"log injection", "abc\n{\"level\":\"error\"}", false},
+		{"spaces", "abc def", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"longest accepted", strings.Repeat("a", maxRequestIDLength), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(test.incoming) > 0 {
+				request.Header[echo.HeaderXRequestID] = []string{test.incoming}
+			}
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+			responseID := recorder.Header().Get(echo.HeaderXRequestID)
+			if responseID != contextID {
+				t.Fatalf("the response must echo the ID of the context, got %q and %q", responseID, contextID)
+			}
+			if test.reused && responseID != test.incoming {
+				t.Fatalf("expected the incoming ID to be reused, got %q", responseID)
+			}
+			if !test.reused {
+				if _, err := uuid.Parse(responseID); err != nil || responseID == test.incoming {
+					t.Fatalf("expected the incoming ID to be replaced by a generated one, got %q", responseID)
+				}
+			}
+		})
+	}
+}
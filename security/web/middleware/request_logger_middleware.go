@@ -3,39 +3,159 @@ package web
 import (
 	"bytes"
 	"github.com/labstack/echo/v4"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
+	"go-security/security/service"
 	"io"
-	"strings"
+	"mime"
+	"net/url"
+	"slices"
+	"time"
 )
 
-func RequestLoggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(ctx echo.Context) error {
+const DefaultRequestLogMaxBodySize = 4096
 
-		url := ctx.Request().URL.Path
+// RequestLogConfig configures the access log. Bodies are only logged for the routes opted in, JSON and
+// form bodies only, with the redacted fields masked.
+type RequestLogConfig struct {
+	// BodyRoutes are the path prefixes whose request bodies are logged.
+	BodyRoutes []string `yaml:"body_routes"`
+	// MaxBodySize caps the logged body in bytes, a larger body is reported as truncated without its content.
+	MaxBodySize int  `yaml:"max_body_size"`
+	LogHeaders  bool `yaml:"log_headers"`
+	// RedactedFields, RedactedHeaders and RedactedCookies are redacted on top of the defaults, which cannot be lifted.
+	RedactedFields  []string `yaml:"redacted_fields"`
+	RedactedHeaders []string `yaml:"redacted_headers"`
+	RedactedCookies []string `yaml:"redacted_cookies"`
+}
 
-		if strings.Contains(url, "upload") {
-			return next(ctx)
-		}
+func (config *RequestLogConfig) GetMaxBodySize() int {
+	if config.MaxBodySize <= 0 {
+		return DefaultRequestLogMaxBodySize
+	}
+	return config.MaxBodySize
+}
 
-		// Save the original body reader
-		req := ctx.Request()
-		body := req.Body
+type RequestLogger struct {
+	Config   *RequestLogConfig
+	Redactor *Redactor
+}
 
-		// Read the body
-		var buf bytes.Buffer
-		if body != nil {
-			_, err := io.Copy(&buf, body)
+func NewRequestLogger(config *RequestLogConfig) *RequestLogger {
+	if config == nil {
+		config = &RequestLogConfig{}
+	}
+	return &RequestLogger{
+		Config: config,
+		Redactor: NewRedactor(
+			slices.Concat(DefaultRedactedFields, config.RedactedFields),
+			slices.Concat(DefaultRedactedHeaders, config.RedactedHeaders),
+			slices.Concat(DefaultRedactedCookies, config.RedactedCookies),
+		),
+	}
+}
+
+// RequestLoggerMiddlewareFunc writes one structured access log entry per request once it is handled.
+func (logger *RequestLogger) RequestLoggerMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		startedAt := time.Now()
+		request := ctx.Request()
+		var body []byte
+		truncated := false
+		if hasRoutePrefix(request.URL.Path, logger.Config.BodyRoutes) && isLoggableContentType(request.Header.Get(echo.HeaderContentType)) {
+			var err error
+			body, truncated, err = logger.captureBody(ctx)
 			if err != nil {
 				return err
 			}
 		}
 
-		// Log the body
-		log.Info().Msgf("Request Body: %s", buf.String())
+		err := next(ctx)
+		status := responseStatus(ctx, err)
+		requestLogger := service.LoggerFromContext(request.Context())
+		event := requestLogger.Info()
+		if status >= 500 {
+			event = requestLogger.Error()
+		} else if status >= 400 {
+			event = requestLogger.Warn()
+		}
+		event = event.
+			Str("method", request.Method).
+			Str("route", ctx.Path()).
+			Str("path", request.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(startedAt)).
+			Int64("bytes_out", ctx.Response().Size).
+			Str("ip", ctx.RealIP()).
+			Str("user_agent", request.UserAgent())
+		if claims, ok := ctx.Get("user").(*service.UserClaims); ok {
+			event = event.Uint("user_id", claims.ID)
+			if claims.IsImpersonated() {
+				event = event.Uint("impersonator_id", *claims.ImpersonatorID)
+			}
+		}
+		if len(request.URL.RawQuery) > 0 {
+			event = event.Str("query", logger.Redactor.RedactValues(request.URL.Query()).Encode())
+		}
+		if logger.Config.LogHeaders {
+			event = event.Interface("headers", logger.Redactor.RedactHeaders(request.Header))
+		}
+		event = logger.withBody(event, request.Header.Get(echo.HeaderContentType), body, truncated)
+		if err != nil {
+			event = event.Err(err)
+		}
+		event.Msg("request")
+		return err
+	}
+}
+
+// captureBody reads the body up to the size cap and puts it back for the handler.
+func (logger *RequestLogger) captureBody(ctx echo.Context) ([]byte, bool, error) {
+	request := ctx.Request()
+	if request.Body == nil {
+		return nil, false, nil
+	}
+	maxSize := logger.Config.GetMaxBodySize()
+	captured, err := io.ReadAll(io.LimitReader(request.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(captured), request.Body), request.Body}
+	if len(captured) > maxSize {
+		return nil, true, nil
+	}
+	return captured, false, nil
+}
 
-		// Reset the body so it can be read again
-		req.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+// withBody adds the redacted body, a body that cannot be parsed is omitted rather than logged unredacted.
+func (logger *RequestLogger) withBody(event *zerolog.Event, contentType string, body []byte, truncated bool) *zerolog.Event {
+	if truncated {
+		return event.Bool("body_truncated", true)
+	}
+	if len(body) == 0 {
+		return event
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == echo.MIMEApplicationForm {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return event.Bool("body_omitted", true)
+		}
+		return event.Str("body", logger.Redactor.RedactValues(values).Encode())
+	}
+	redacted, ok := logger.Redactor.RedactJSON(body)
+	if !ok {
+		return event.Bool("body_omitted", true)
+	}
+	return event.RawJSON("body", redacted)
+}
 
-		return next(ctx)
+func isLoggableContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
 	}
+	return mediaType == echo.MIMEApplicationJSON || mediaType == echo.MIMEApplicationForm
 }
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLoggerKeepsDefaultRedactions(t *testing.T) {
+	logger := NewRequestLogger(&RequestLogConfig{RedactedFields: []string{"ssn"}})
+	for _, field := range []string{"ssn", "password", "current_password", "otp_code"} {
+		if !logger.Redactor.IsRedactedField(field) {
+			t.Errorf("%s must be redacted", field)
+		}
+	}
+	if logger.Redactor.IsRedactedField("email") {
+		t.Error("email must not be redacted")
+	}
+}
+
+// captureLog routes the global logger, which the request logger falls back to without a request ID, to a buffer.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buffer bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buffer)
+	t.Cleanup(func() { log.Logger = previous })
+	return &buffer
+}
+
+// serveLogged posts body to /auth/login and returns the access log entry and the body the handler read.
+func serveLogged(t *testing.T, config *RequestLogConfig, contentType string, body string) (map[string]any, string) {
+	t.Helper()
+	buffer := captureLog(t)
+	engine := echo.New()
+	engine.Use(NewRequestLogger(config).RequestLoggerMiddlewareFunc)
+	var handled string
+	engine.POST("/auth/login", func(ctx echo.Context) error {
+		read, err := io.ReadAll(ctx.Request().Body)
+		if err != nil {
+			return err
+		}
+		handled = string(read)
+		return ctx.NoContent(http.StatusOK)
+	})
+	request := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, contentType)
+	engine.ServeHTTP(httptest.NewRecorder(), request)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON log entry, got %q: %v", buffer.String(), err)
+	}
+	return entry, handled
+}
+
+func TestRequestLoggerMasksNestedFields(t *testing.T) {
+	body := `{"email":"alice@example.com","credentials":{"password":"hunter2","tokens":[{"otp_code":"123456"}]}}`
+	entry, handled := serveLogged(t, &RequestLogConfig{BodyRoutes: []string{"/auth"}}, echo.MIMEApplicationJSON, body)
+	if handled != body {
+		t.Fatalf("the handler read %q", handled)
+	}
+	logged, _ := json.Marshal(entry["body"])
+	if strings.Contains(string(logged), "hunter2") || strings.Contains(string(logged), "123456") {
+		t.Fatalf("a secret was logged: %s", logged)
+	}
+	credentials, _ := entry["body"].(map[string]any)["credentials"].(map[string]any)
+	if credentials["password"] != RedactedValue {
+		t.Fatalf("expected the nested password to be masked, got %s", logged)
+	}
+	if entry["body"].(map[string]any)["email"] != "alice@example.com" {
+		t.Fatalf("expected the email to be logged, got %s", logged)
+	}
+}
+
+func TestRequestLoggerMasksFormFields(t *testing.T) {
+	entry, handled := serveLogged(t, &RequestLogConfig{BodyRoutes: []string{"/auth"}}, echo.MIMEApplicationForm, "email=alice%40example.com&password=hunter2")
+	if handled != "email=alice%40example.com&password=hunter2" {
+		t.Fatalf("the handler read %q", handled)
+	}
+	if logged, _ := entry["body"].(string); strings.Contains(logged, "hunter2") || !strings.Contains(logged, "alice") {
+		t.Fatalf("expected the password to be masked, got %q", logged)
+	}
+}
+
+func TestRequestLoggerOmitsBodies(t *testing.T) {
+	oversized := `{"password":"` + strings.Repeat("x", 64) + `"}`
+	tests := []struct {
+		name        string
+		config      *RequestLogConfig
+		contentType string
+		body        string
+		field       string
+	}{
+		{"malformed json", &RequestLogConfig{BodyRoutes: []string{"/auth"}}, echo.MIMEApplicationJSON, `{"password":"hunter2"`, "body_omitted"},
+		{"oversized", &RequestLogConfig{BodyRoutes: []string{"/auth"}, MaxBodySize: 32}, echo.MIMEApplicationJSON, oversized, "body_truncated"},
+		{"not json", &RequestLogConfig{BodyRoutes: []string{"/auth"}}, echo.MIMETextPlain, "password=hunter2", ""},
+		{"route not opted in", &RequestLogConfig{BodyRoutes: []string{"/admin"}}, echo.MIMEApplicationJSON, `{"password":"hunter2"}`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, handled := serveLogged(t, test.config, test.contentType, test.body)
+			if handled != test.body {
+				t.Fatalf("the handler read %q instead of the full body", handled)
+			}
+			if _, ok := entry["body"]; ok {
+				t.Fatalf("the body must not be logged, got %v", entry["body"])
+			}
+			if len(test.field) > 0 && entry[test.field] != true {
+				t.Fatalf("expected %s to be reported, got %v", test.field, entry)
+			}
+		})
+	}
+}
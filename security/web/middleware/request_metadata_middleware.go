@@ -0,0 +1,19 @@
+package web
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+)
+
+// RequestMetadataMiddlewareFunc exposes the client IP and user agent to services through the request context.
+func RequestMetadataMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		request := ctx.Request()
+		metadata := &service.RequestMetadata{
+			IPAddress: ctx.RealIP(),
+			UserAgent: request.UserAgent(),
+		}
+		ctx.SetRequest(request.WithContext(service.ContextWithRequestMetadata(request.Context(), metadata)))
+		return next(ctx)
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithSecurityHeadersOnGroup(t *testing.T) {
+	config := NewDefaultSecurityHeadersConfig()
+	config.ContentSecurityPolicy = "default-src 'self'"
+	engine := echo.New()
+	engine.Use(NewSecurityHeadersMiddleware(config).SecurityHeadersMiddlewareFunc)
+	var nonce string
+	group := engine.Group("/docs", WithSecurityHeaders(map[string]string{
+		echo.HeaderContentSecurityPolicy: "script-src 'nonce-{nonce}'",
+		echo.HeaderXFrameOptions:         "",
+	}))
+	group.GET("/index", func(ctx echo.Context) error {
+		nonce = GetCspNonce(ctx)
+		return ctx.NoContent(http.StatusOK)
+	})
+	engine.GET("/api/me", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs/index", nil))
+	if len(nonce) == 0 {
+		t.Fatal("expected a nonce for the override referencing {nonce}")
+	}
+	if csp := recorder.Header().Get(echo.HeaderContentSecurityPolicy); csp != "script-src 'nonce-"+nonce+"'" {
+		t.Errorf("unexpected group policy %q", csp)
+	}
+	if frameOptions := recorder.Header().Get(echo.HeaderXFrameOptions); len(frameOptions) > 0 {
+		t.Errorf("expected X-Frame-Options to be removed, got %q", frameOptions)
+	}
+
+	recorder = httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/me", nil))
+	if csp := recorder.Header().Get(echo.HeaderContentSecurityPolicy); csp != "default-src 'self'" {
+		t.Errorf("unexpected default policy %q", csp)
+	}
+	if frameOptions := recorder.Header().Get(echo.HeaderXFrameOptions); frameOptions != "DENY" {
+		t.Errorf("unexpected X-Frame-Options %q", frameOptions)
+	}
+}
+
+func TestSecurityHeadersNonceSharedAcrossPolicies(t *testing.T) {
+	engine := echo.New()
+	engine.Use(NewSecurityHeadersMiddleware(nil).SecurityHeadersMiddlewareFunc)
+	engine.GET("/page", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	}, WithSecurityHeaders(map[string]string{"Content-Security-Policy-Report-Only": "script-src 'nonce-{nonce}'"}))
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/page", nil))
+	csp := recorder.Header().Get(echo.HeaderContentSecurityPolicy)
+	reportOnly := recorder.Header().Get("Content-Security-Policy-Report-Only")
+	nonce := strings.TrimSuffix(strings.TrimPrefix(reportOnly, "script-src 'nonce-"), "'")
+	if len(nonce) == 0 || strings.Contains(reportOnly, CspNoncePlaceholder) || !strings.Contains(csp, "'nonce-"+nonce+"'") {
+		t.Errorf("expected one nonce shared by both headers, got %q and %q", csp, reportOnly)
+	}
+}
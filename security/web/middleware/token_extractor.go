@@ -0,0 +1,150 @@
+package web
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go-security/security/service"
+	"strings"
+)
+
+var (
+	TokenNotFound              = errors.New("TokenNotFound")
+	TokenExtractorNotSupported = errors.New("TokenExtractorNotSupported")
+)
+
+const (
+	TokenExtractorCookie = "cookie"
+	TokenExtractorBearer = "bearer"
+	TokenExtractorQuery  = "query"
+
+	DefaultTokenQueryParameter = "access_token"
+)
+
+// TokenSource tells which extractor produced the token of the current request.
+type TokenSource string
+
+const (
+	TokenSourceCookie TokenSource = TokenExtractorCookie
+	TokenSourceBearer TokenSource = TokenExtractorBearer
+	TokenSourceQuery  TokenSource = TokenExtractorQuery
+)
+
+type TokenExtractor interface {
+	Source() TokenSource
+	Extract(ctx echo.Context) (string, error)
+}
+
+type CookieTokenExtractor struct {
+	CookieName string
+}
+
+func NewCookieTokenExtractor(cookieName string) *CookieTokenExtractor {
+	return &CookieTokenExtractor{CookieName: cookieName}
+}
+
+func (extractor *CookieTokenExtractor) Source() TokenSource {
+	return TokenSourceCookie
+}
+
+func (extractor *CookieTokenExtractor) Extract(ctx echo.Context) (string, error) {
+	cookie, err := ctx.Cookie(extractor.CookieName)
+	if err != nil || len(cookie.Value) == 0 {
+		return "", TokenNotFound
+	}
+	return cookie.Value, nil
+}
+
+type BearerTokenExtractor struct{}
+
+func NewBearerTokenExtractor() *BearerTokenExtractor {
+	return &BearerTokenExtractor{}
+}
+
+func (extractor *BearerTokenExtractor) Source() TokenSource {
+	return TokenSourceBearer
+}
+
+func (extractor *BearerTokenExtractor) Extract(ctx echo.Context) (string, error) {
+	header := ctx.Request().Header.Get(echo.HeaderAuthorization)
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", TokenNotFound
+	}
+	token = strings.TrimSpace(token)
+	if len(token) == 0 {
+		return "", TokenNotFound
+	}
+	return token, nil
+}
+
+// QueryTokenExtractor reads the token from a query parameter. Browsers cannot set headers on
+// WebSocket handshakes, so the extractor only applies to upgrade requests to keep tokens out of
+// regular URLs and access logs.
+type QueryTokenExtractor struct {
+	Parameter string
+}
+
+func NewQueryTokenExtractor(parameter string) *QueryTokenExtractor {
+	if len(parameter) == 0 {
+		parameter = DefaultTokenQueryParameter
+	}
+	return &QueryTokenExtractor{Parameter: parameter}
+}
+
+func (extractor *QueryTokenExtractor) Source() TokenSource {
+	return TokenSourceQuery
+}
+
+func (extractor *QueryTokenExtractor) Extract(ctx echo.Context) (string, error) {
+	if !strings.EqualFold(ctx.Request().Header.Get(echo.HeaderUpgrade), "websocket") {
+		return "", TokenNotFound
+	}
+	token := ctx.QueryParam(extractor.Parameter)
+	if len(token) == 0 {
+		return "", TokenNotFound
+	}
+	return token, nil
+}
+
+// NewTokenExtractorsFromConfig builds the extractors in the order of precedence given by the config,
+// falling back to cookie then bearer header when none is configured.
+func NewTokenExtractorsFromConfig(config *service.SecurityConfig) ([]TokenExtractor, error) {
+	names := config.TokenExtractors
+	if len(names) == 0 {
+		names = []string{TokenExtractorCookie, TokenExtractorBearer}
+	}
+
+	extractors := make([]TokenExtractor, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case TokenExtractorCookie:
+			extractors = append(extractors, NewCookieTokenExtractor(service.CookieName))
+		case TokenExtractorBearer:
+			extractors = append(extractors, NewBearerTokenExtractor())
+		case TokenExtractorQuery:
+			extractors = append(extractors, NewQueryTokenExtractor(config.TokenQueryParameter))
+		default:
+			return nil, TokenExtractorNotSupported
+		}
+	}
+	return extractors, nil
+}
+
+func MustNewTokenExtractorsFromConfig(config *service.SecurityConfig) []TokenExtractor {
+	extractors, err := NewTokenExtractorsFromConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	return extractors
+}
+
+// ExtractToken returns the first token found by the given extractors together with its source.
+func ExtractToken(ctx echo.Context, extractors []TokenExtractor) (string, TokenSource, error) {
+	for _, extractor := range extractors {
+		token, err := extractor.Extract(ctx)
+		if err == nil {
+			return token, extractor.Source(), nil
+		}
+	}
+	return "", "", TokenNotFound
+}
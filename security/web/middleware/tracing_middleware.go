@@ -0,0 +1,48 @@
+package web
+
+import (
+	"github.com/labstack/echo/v4"
+	"go-security/security/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// TracingMiddlewareFunc starts the server span of every request, continuing the trace of an incoming
+// traceparent header. The span is named after the route template so path parameters stay out of its name.
+func TracingMiddlewareFunc(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		request := ctx.Request()
+		parent := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		route := ctx.Path()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+		spanCtx, span := tracing.Tracer().Start(parent, request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(request.URL.Path),
+				semconv.ClientAddress(ctx.RealIP()),
+				semconv.UserAgentOriginal(request.UserAgent()),
+			),
+		)
+		defer span.End()
+		ctx.SetRequest(request.WithContext(spanCtx))
+
+		err := next(ctx)
+		status := responseStatus(ctx, err)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	}
+}